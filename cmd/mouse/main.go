@@ -6,24 +6,49 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"mouse/internal/auth"
 	"mouse/internal/config"
+	"mouse/internal/fakellm"
 	"mouse/internal/gateway"
 	"mouse/internal/logging"
+	"mouse/internal/replay"
+	"mouse/internal/sessions"
+	"mouse/internal/sqlite"
 	"mouse/internal/telegram"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fake-llm" {
+		runFakeLLM(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		runToken(os.Args[2:])
+		return
+	}
+
 	var (
 		configPath string
 		addr       string
+		adminAddr  string
 		checkOnly  bool
+		recordPath string
+		replayPath string
+		drainFor   time.Duration
 	)
 
 	flag.StringVar(&configPath, "config", "./config/mouse.yaml", "path to config file")
 	flag.StringVar(&addr, "addr", ":8080", "listen address")
+	flag.StringVar(&adminAddr, "admin-addr", "", "separate listen address for admin routes, host:port or unix:///path.sock (overrides admin.addr)")
 	flag.BoolVar(&checkOnly, "check", false, "validate config and exit")
+	flag.StringVar(&recordPath, "record", "", "record conversations to this fixture file while serving")
+	flag.DurationVar(&drainFor, "shutdown-timeout", 8*time.Second, "how long to drain in-flight requests and workers on SIGINT/SIGTERM")
+	flag.StringVar(&replayPath, "replay", "", "replay a fixture file against this build, print a diff report and exit")
 	flag.Parse()
 
 	logger := logging.New("mouse")
@@ -39,11 +64,38 @@ func main() {
 		os.Exit(1)
 	}
 
+	if adminAddr != "" {
+		cfg.Admin.Addr = adminAddr
+	}
+
 	if checkOnly {
 		fmt.Println("config ok")
 		return
 	}
 
+	if replayPath != "" {
+		fixture, err := replay.Load(replayPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "replay error: %v\n", err)
+			os.Exit(1)
+		}
+		report, err := replay.Run(context.Background(), cfg, fixture, logger)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "replay error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(report.Format())
+		if !report.OK() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := gateway.CheckExposure(cfg, addr); err != nil {
+		fmt.Fprintf(os.Stderr, "auth error: %v\n", err)
+		os.Exit(1)
+	}
+
 	if cfg.App.Workspace != "" {
 		logPath := cfg.App.Workspace + "/logs/mouse.log"
 		if err := logging.SetFile(logPath); err != nil {
@@ -52,27 +104,163 @@ func main() {
 		}
 	}
 
+	var hooks gateway.Hooks
+	if recordPath != "" {
+		store, err := sessions.NewStore(cfg.Sessions.Dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "record error: %v\n", err)
+			os.Exit(1)
+		}
+		recorder, err := replay.NewRecorder(recordPath, store, logging.New("replay-record"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "record error: %v\n", err)
+			os.Exit(1)
+		}
+		proxyURL, err := recorder.ListenProxy(telegram.APIBase(cfg.Telegram.APIBase))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "record error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.Telegram.APIBase = proxyURL
+		hooks = gateway.Hooks{WrapLLM: recorder.WrapLLM, WrapProcessor: recorder.WrapProcessor}
+		logger.Info("recording conversations", map[string]string{
+			"fixture": recordPath,
+		})
+	}
+
 	if cfg.Telegram.Enabled && cfg.Telegram.Webhook.Enabled {
-		if err := telegram.SetWebhook(context.Background(), cfg.Telegram.BotToken, cfg.Telegram.Webhook.PublicURL, cfg.Telegram.Webhook.Path, cfg.Telegram.Webhook.Secret, logging.New("telegram-webhook")); err != nil {
+		if err := telegram.SetWebhook(context.Background(), cfg.Telegram.APIBase, cfg.Telegram.BotToken, cfg.Telegram.Webhook.PublicURL, cfg.Telegram.Webhook.Path, cfg.Telegram.Webhook.Secret, logging.New("telegram-webhook")); err != nil {
 			fmt.Fprintf(os.Stderr, "webhook error: %v\n", err)
 			os.Exit(1)
 		}
 	}
 
-	server, err := gateway.NewServer(cfg, logger)
+	server, err := gateway.NewServerWithHooks(cfg, logger, hooks)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "gateway error: %v\n", err)
 		os.Exit(1)
 	}
 
+	tlsConfig, err := auth.TLSConfig(cfg.Auth.TLS)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tls error: %v\n", err)
+		os.Exit(1)
+	}
+
+	handler := server.Handler()
+	if cfg.Admin.Addr != "" {
+		handler = server.PublicHandler()
+	}
 	httpServer := &http.Server{
 		Addr:              addr,
-		Handler:           server.Handler(),
+		Handler:           handler,
 		ReadHeaderTimeout: 5 * time.Second,
+		TLSConfig:         tlsConfig,
 	}
 
+	errs := make(chan error, 2)
+	var adminServer *http.Server
+	if cfg.Admin.Addr != "" {
+		listener, err := gateway.Listen(cfg.Admin.Addr, cfg.Admin.SocketMode)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "admin listener error: %v\n", err)
+			os.Exit(1)
+		}
+		adminServer = &http.Server{
+			Handler:           server.AdminHandler(),
+			ReadHeaderTimeout: 5 * time.Second,
+			TLSConfig:         tlsConfig,
+		}
+		logger.Info("admin listener starting", map[string]string{
+			"addr": cfg.Admin.Addr,
+		})
+		go func() {
+			if tlsConfig != nil && !gateway.IsUnixAddr(cfg.Admin.Addr) {
+				errs <- adminServer.ServeTLS(listener, "", "")
+				return
+			}
+			errs <- adminServer.Serve(listener)
+		}()
+	}
+
+	server.Start(context.Background())
 	logger.Info("gateway starting", map[string]string{
 		"addr": addr,
+		"tls":  fmt.Sprintf("%t", tlsConfig != nil),
+	})
+
+	go func() {
+		if tlsConfig != nil {
+			errs <- httpServer.ListenAndServeTLS("", "")
+			return
+		}
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	exitCode := 0
+	select {
+	case err := <-errs:
+		if err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "server error: %v\n", err)
+			exitCode = 1
+		}
+	case <-signals.Done():
+		logger.Info("shutdown signal received", map[string]string{
+			"timeout": drainFor.String(),
+		})
+	}
+	stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainFor)
+	defer cancel()
+	for _, srv := range []*http.Server{httpServer, adminServer} {
+		if srv == nil {
+			continue
+		}
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Warn("listener shutdown incomplete", map[string]string{
+				"error": err.Error(),
+			})
+		}
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "shutdown error: %v\n", err)
+		exitCode = 1
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+func runFakeLLM(args []string) {
+	fs := flag.NewFlagSet("fake-llm", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:9090", "listen address")
+	scriptPath := fs.String("script", "", "path to a YAML script of canned responses")
+	_ = fs.Parse(args)
+
+	logger := logging.New("fake-llm")
+	fake := fakellm.New()
+	if *scriptPath != "" {
+		loaded, err := fakellm.Load(*scriptPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fake-llm error: %v\n", err)
+			os.Exit(1)
+		}
+		fake = loaded
+	}
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           fake,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	logger.Info("fake llm starting", map[string]string{
+		"addr":   *addr,
+		"script": *scriptPath,
+		"steps":  fmt.Sprintf("%d", fake.Remaining()),
 	})
 
 	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -80,3 +268,93 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+func runToken(args []string) {
+	usage := "mouse token <create|list|revoke|hash>"
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if args[0] == "hash" {
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "token hash requires a token")
+			os.Exit(2)
+		}
+		fmt.Println(auth.HashToken(args[1]))
+		return
+	}
+
+	fs := flag.NewFlagSet("token "+args[0], flag.ExitOnError)
+	configPath := fs.String("config", "./config/mouse.yaml", "path to config file")
+	name := fs.String("name", "", "token name")
+	scopes := fs.String("scopes", "", "comma-separated scopes ("+strings.Join(auth.Scopes, ", ")+"; append :read for read-only)")
+	_ = fs.Parse(args[1:])
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		os.Exit(1)
+	}
+	db, err := sqlite.Open(cfg.Index.SQLitePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sqlite error: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "create":
+		var list []string
+		for _, scope := range strings.Split(*scopes, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				list = append(list, scope)
+			}
+		}
+		if strings.TrimSpace(*name) == "" {
+			fmt.Fprintln(os.Stderr, "token create requires -name")
+			os.Exit(2)
+		}
+		if err := auth.ValidateScopes(list); err != nil {
+			fmt.Fprintf(os.Stderr, "token create: %v\n", err)
+			os.Exit(2)
+		}
+		token, err := auth.GenerateToken()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "token create: %v\n", err)
+			os.Exit(1)
+		}
+		if err := db.CreateAPIToken(ctx, *name, auth.HashToken(token), list); err != nil {
+			fmt.Fprintf(os.Stderr, "token create: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(token)
+	case "list":
+		tokens, err := db.ListAPITokens(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "token list: %v\n", err)
+			os.Exit(1)
+		}
+		for _, token := range tokens {
+			lastUsed := token.LastUsedAt
+			if lastUsed == "" {
+				lastUsed = "never"
+			}
+			fmt.Printf("%s\t%s\tcreated %s\tlast used %s\n", token.Name, strings.Join(token.Scopes, ","), token.CreatedAt, lastUsed)
+		}
+	case "revoke":
+		removed, err := db.DeleteAPIToken(ctx, *name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "token revoke: %v\n", err)
+			os.Exit(1)
+		}
+		if !removed {
+			fmt.Fprintf(os.Stderr, "token revoke: no token named %q\n", *name)
+			os.Exit(1)
+		}
+		fmt.Println("ok")
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -28,6 +32,77 @@ type searchResponse struct {
 	} `json:"matches"`
 }
 
+type memoryEntry struct {
+	Key       string `json:"key"`
+	Content   string `json:"content"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type memoryListResponse struct {
+	Entries []memoryEntry `json:"entries"`
+}
+
+type sessionInfo struct {
+	ID        string `json:"id"`
+	Entries   int    `json:"entries"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type sessionListResponse struct {
+	Sessions []sessionInfo `json:"sessions"`
+}
+
+type sessionEntry struct {
+	Index     int    `json:"index"`
+	Timestamp string `json:"timestamp"`
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+}
+
+type sessionEntriesResponse struct {
+	ID      string         `json:"id"`
+	Total   int            `json:"total"`
+	Entries []sessionEntry `json:"entries"`
+}
+
+type reconcileResponse struct {
+	DryRun   bool `json:"dry_run"`
+	Checked  int  `json:"checked"`
+	Drifted  int  `json:"drifted"`
+	Sessions []struct {
+		ID       string `json:"id"`
+		Markdown int    `json:"markdown"`
+		SQLite   int    `json:"sqlite"`
+		Status   string `json:"status"`
+	} `json:"sessions"`
+}
+
+type lifecycleResponse struct {
+	ArchivedID string `json:"archived_id"`
+	ForkID     string `json:"fork_id"`
+}
+
+type usageRow struct {
+	Key                 string  `json:"key"`
+	Calls               int64   `json:"calls"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	CostUSD             float64 `json:"cost_usd"`
+}
+
+type usageResponse struct {
+	Since string     `json:"since"`
+	By    string     `json:"by"`
+	Rows  []usageRow `json:"rows"`
+	Total usageRow   `json:"total"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
 type logEntry struct {
 	Timestamp string            `json:"ts"`
 	Level     string            `json:"level"`
@@ -37,24 +112,42 @@ type logEntry struct {
 }
 
 func main() {
-	if len(os.Args) < 2 {
+	token := flag.String("token", os.Getenv("MOUSE_TOKEN"), "API bearer token (default $MOUSE_TOKEN)")
+	caCert := flag.String("cacert", os.Getenv("MOUSE_CA_CERT"), "CA bundle for verifying the gateway (default $MOUSE_CA_CERT)")
+	clientCert := flag.String("cert", os.Getenv("MOUSE_CLIENT_CERT"), "client certificate for mTLS (default $MOUSE_CLIENT_CERT)")
+	clientKey := flag.String("key", os.Getenv("MOUSE_CLIENT_KEY"), "client key for mTLS (default $MOUSE_CLIENT_KEY)")
+	socket := flag.String("socket", os.Getenv("MOUSE_SOCKET"), "Unix socket of the admin listener; -addr then only sets the Host header (default $MOUSE_SOCKET)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
 		usage()
 		os.Exit(2)
 	}
+	if err := configureClient(*token, *caCert, *clientCert, *clientKey, *socket); err != nil {
+		fmt.Fprintf(os.Stderr, "client error: %v\n", err)
+		os.Exit(1)
+	}
 
-	switch os.Args[1] {
+	args := flag.Args()
+	switch args[0] {
 	case "status":
-		statusCmd(os.Args[2:])
+		statusCmd(args[1:])
 	case "run":
-		runCmd(os.Args[2:])
+		runCmd(args[1:])
 	case "reindex":
-		reindexCmd(os.Args[2:])
+		reindexCmd(args[1:])
 	case "search":
-		searchCmd(os.Args[2:])
+		searchCmd(args[1:])
 	case "approve":
-		approveCmd(os.Args[2:])
+		approveCmd(args[1:])
 	case "logs":
-		logsCmd(os.Args[2:])
+		logsCmd(args[1:])
+	case "memory":
+		memoryCmd(args[1:])
+	case "sessions":
+		sessionsCmd(args[1:])
+	case "usage":
+		usageCmd(args[1:])
 	default:
 		usage()
 		os.Exit(2)
@@ -62,7 +155,7 @@ func main() {
 }
 
 func usage() {
-	fmt.Fprintln(os.Stderr, "mousectl <status|run|reindex|search|approve|logs>")
+	fmt.Fprintln(os.Stderr, "mousectl [-token T] [-socket PATH] [-cacert F] [-cert F -key F] <status|run|reindex|search|approve|logs|memory|sessions|usage>")
 }
 
 func statusCmd(args []string) {
@@ -222,3 +315,391 @@ func logsCmd(args []string) {
 		fmt.Printf("%s [%s] %s: %s\n", entry.Timestamp, entry.Level, entry.Service, entry.Message)
 	}
 }
+
+func memoryCmd(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "mousectl memory <get|set|ls|rm>")
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "get":
+		memoryGetCmd(args[1:])
+	case "set":
+		memorySetCmd(args[1:])
+	case "ls":
+		memoryListCmd(args[1:])
+	case "rm":
+		memoryRemoveCmd(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, "mousectl memory <get|set|ls|rm>")
+		os.Exit(2)
+	}
+}
+
+func memoryGetCmd(args []string) {
+	fs := flag.NewFlagSet("memory get", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "memory get requires key")
+		os.Exit(2)
+	}
+	endpoint := fmt.Sprintf("%s/memory/get?key=%s", strings.TrimRight(*addr, "/"), url.QueryEscape(fs.Arg(0)))
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "memory get error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "memory get failed: %s\n", errorMessage(data))
+		os.Exit(1)
+	}
+	var entry memoryEntry
+	_ = json.Unmarshal(data, &entry)
+	fmt.Println(entry.Content)
+}
+
+func memorySetCmd(args []string) {
+	fs := flag.NewFlagSet("memory set", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	_ = fs.Parse(args)
+	if fs.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "memory set requires key and content")
+		os.Exit(2)
+	}
+	payload := map[string]string{"key": fs.Arg(0), "content": strings.Join(fs.Args()[1:], " ")}
+	body, _ := json.Marshal(payload)
+	endpoint := strings.TrimRight(*addr, "/") + "/memory/set"
+	resp, err := http.Post(endpoint, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "memory set error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "memory set failed: %s\n", errorMessage(data))
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+}
+
+func memoryListCmd(args []string) {
+	fs := flag.NewFlagSet("memory ls", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	_ = fs.Parse(args)
+	resp, err := http.Get(strings.TrimRight(*addr, "/") + "/memory/list")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "memory ls error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "memory ls failed: %s\n", errorMessage(data))
+		os.Exit(1)
+	}
+	var parsed memoryListResponse
+	_ = json.Unmarshal(data, &parsed)
+	for _, entry := range parsed.Entries {
+		fmt.Printf("%s\t%s\n", entry.UpdatedAt, entry.Key)
+	}
+}
+
+func memoryRemoveCmd(args []string) {
+	fs := flag.NewFlagSet("memory rm", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "memory rm requires key")
+		os.Exit(2)
+	}
+	payload := map[string]string{"key": fs.Arg(0)}
+	body, _ := json.Marshal(payload)
+	endpoint := strings.TrimRight(*addr, "/") + "/memory/delete"
+	resp, err := http.Post(endpoint, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "memory rm error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "memory rm failed: %s\n", errorMessage(data))
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+}
+
+func sessionsCmd(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "mousectl sessions <ls|show|tail|reconcile|export|reset|fork>")
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "ls":
+		sessionsListCmd(args[1:])
+	case "show":
+		sessionsShowCmd(args[1:])
+	case "tail":
+		sessionsTailCmd(args[1:])
+	case "reconcile":
+		sessionsReconcileCmd(args[1:])
+	case "export":
+		sessionsExportCmd(args[1:])
+	case "reset":
+		sessionsResetCmd(args[1:])
+	case "fork":
+		sessionsForkCmd(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, "mousectl sessions <ls|show|tail|reconcile|export|reset|fork>")
+		os.Exit(2)
+	}
+}
+
+func sessionsListCmd(args []string) {
+	fs := flag.NewFlagSet("sessions ls", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	archived := fs.Bool("archived", false, "list archived sessions")
+	_ = fs.Parse(args)
+	endpoint := fmt.Sprintf("%s/sessions/list?archived=%t", strings.TrimRight(*addr, "/"), *archived)
+	data := getBody(endpoint, "sessions ls")
+	var parsed sessionListResponse
+	_ = json.Unmarshal(data, &parsed)
+	for _, info := range parsed.Sessions {
+		fmt.Printf("%s\t%d\t%s\n", info.UpdatedAt, info.Entries, info.ID)
+	}
+}
+
+func sessionsShowCmd(args []string) {
+	fs := flag.NewFlagSet("sessions show", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	offset := fs.Int("offset", 0, "first entry to show")
+	limit := fs.Int("limit", 0, "max entries (0 for all)")
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "sessions show requires id")
+		os.Exit(2)
+	}
+	endpoint := fmt.Sprintf("%s/sessions/show?id=%s&offset=%d&limit=%d", strings.TrimRight(*addr, "/"), url.QueryEscape(fs.Arg(0)), *offset, *limit)
+	data := getBody(endpoint, "sessions show")
+	var parsed sessionEntriesResponse
+	_ = json.Unmarshal(data, &parsed)
+	printSessionEntries(parsed.Entries)
+}
+
+func sessionsTailCmd(args []string) {
+	fs := flag.NewFlagSet("sessions tail", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	lines := fs.Int("n", 10, "entries to show")
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "sessions tail requires id")
+		os.Exit(2)
+	}
+	endpoint := fmt.Sprintf("%s/sessions/tail?id=%s&n=%d", strings.TrimRight(*addr, "/"), url.QueryEscape(fs.Arg(0)), *lines)
+	data := getBody(endpoint, "sessions tail")
+	var parsed sessionEntriesResponse
+	_ = json.Unmarshal(data, &parsed)
+	printSessionEntries(parsed.Entries)
+}
+
+func sessionsReconcileCmd(args []string) {
+	fs := flag.NewFlagSet("sessions reconcile", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	dryRun := fs.Bool("dry-run", false, "report drift without rebuilding")
+	_ = fs.Parse(args)
+	endpoint := fmt.Sprintf("%s/sessions/reconcile?dry_run=%t", strings.TrimRight(*addr, "/"), *dryRun)
+	resp, err := http.Post(endpoint, "application/json", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sessions reconcile error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "sessions reconcile failed: %s\n", errorMessage(data))
+		os.Exit(1)
+	}
+	var parsed reconcileResponse
+	_ = json.Unmarshal(data, &parsed)
+	for _, drift := range parsed.Sessions {
+		if drift.Status == "in_sync" {
+			continue
+		}
+		fmt.Printf("%s\tmarkdown=%d sqlite=%d\t%s\n", drift.Status, drift.Markdown, drift.SQLite, drift.ID)
+	}
+	fmt.Printf("checked %d, drifted %d\n", parsed.Checked, parsed.Drifted)
+}
+
+func sessionsExportCmd(args []string) {
+	fs := flag.NewFlagSet("sessions export", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	format := fs.String("format", "jsonl", "export format (jsonl|html|anthropic)")
+	output := fs.String("o", "", "output file (default stdout)")
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "sessions export requires id")
+		os.Exit(2)
+	}
+	endpoint := fmt.Sprintf("%s/sessions/export?id=%s&format=%s", strings.TrimRight(*addr, "/"), url.QueryEscape(fs.Arg(0)), url.QueryEscape(*format))
+	data := getBody(endpoint, "sessions export")
+	if *output == "" {
+		_, _ = os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "sessions export error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func sessionsResetCmd(args []string) {
+	fs := flag.NewFlagSet("sessions reset", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "sessions reset requires id")
+		os.Exit(2)
+	}
+	payload := map[string]string{"id": fs.Arg(0)}
+	data := postBody(strings.TrimRight(*addr, "/")+"/sessions/reset", payload, "sessions reset")
+	var parsed lifecycleResponse
+	_ = json.Unmarshal(data, &parsed)
+	fmt.Printf("archived as %s\n", parsed.ArchivedID)
+}
+
+func sessionsForkCmd(args []string) {
+	fs := flag.NewFlagSet("sessions fork", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	at := fs.Int("at", 0, "last entry index to keep")
+	newID := fs.String("new", "", "id for the forked session")
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "sessions fork requires id")
+		os.Exit(2)
+	}
+	payload := map[string]any{"id": fs.Arg(0), "index": *at, "new_id": *newID}
+	data := postBody(strings.TrimRight(*addr, "/")+"/sessions/fork", payload, "sessions fork")
+	var parsed lifecycleResponse
+	_ = json.Unmarshal(data, &parsed)
+	fmt.Println(parsed.ForkID)
+}
+
+func usageCmd(args []string) {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8080", "gateway address")
+	since := fs.String("since", "today", "start of the window (today, month, 7d, 24h, YYYY-MM-DD or RFC3339)")
+	by := fs.String("by", "model", "group by model, session, user, job or day")
+	_ = fs.Parse(args)
+	endpoint := fmt.Sprintf("%s/usage?since=%s&by=%s", strings.TrimRight(*addr, "/"), url.QueryEscape(*since), url.QueryEscape(*by))
+	data := getBody(endpoint, "usage")
+	var parsed usageResponse
+	_ = json.Unmarshal(data, &parsed)
+	printUsageRow := func(row usageRow) {
+		key := row.Key
+		if key == "" {
+			key = "(none)"
+		}
+		fmt.Printf("%s\t%d\t%d\t%d\t%d\t%d\t$%.4f\n", key, row.Calls, row.InputTokens, row.OutputTokens, row.CacheCreationTokens, row.CacheReadTokens, row.CostUSD)
+	}
+	fmt.Printf("%s\tcalls\tinput\toutput\tcache_write\tcache_read\tcost\n", parsed.By)
+	for _, row := range parsed.Rows {
+		printUsageRow(row)
+	}
+	printUsageRow(parsed.Total)
+}
+
+func postBody(endpoint string, payload any, name string) []byte {
+	body, _ := json.Marshal(payload)
+	resp, err := http.Post(endpoint, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s error: %v\n", name, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "%s failed: %s\n", name, errorMessage(data))
+		os.Exit(1)
+	}
+	return data
+}
+
+func printSessionEntries(entries []sessionEntry) {
+	for _, entry := range entries {
+		fmt.Printf("[%d] %s %s\n%s\n\n", entry.Index, entry.Timestamp, entry.Role, entry.Content)
+	}
+}
+
+func getBody(endpoint, name string) []byte {
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s error: %v\n", name, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "%s failed: %s\n", name, errorMessage(data))
+		os.Exit(1)
+	}
+	return data
+}
+
+func errorMessage(data []byte) string {
+	var parsed errorResponse
+	if err := json.Unmarshal(data, &parsed); err == nil && parsed.Error != "" {
+		return parsed.Error
+	}
+	return strings.TrimSpace(string(data))
+}
+
+type authTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.token != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func configureClient(token, caCert, clientCert, clientKey, socket string) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if socket = strings.TrimPrefix(strings.TrimPrefix(socket, "unix:"), "//"); socket != "" {
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	}
+	if caCert != "" || clientCert != "" {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if caCert != "" {
+			pem, err := os.ReadFile(caCert)
+			if err != nil {
+				return fmt.Errorf("read ca cert: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("ca cert %s contains no certificates", caCert)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if clientCert != "" {
+			cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+			if err != nil {
+				return fmt.Errorf("load client cert: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	http.DefaultClient.Transport = &authTransport{token: strings.TrimSpace(token), base: transport}
+	return nil
+}
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"mouse/internal/config"
+	"mouse/internal/logging"
+	"mouse/internal/sqlite"
+)
+
+const (
+	ScopeAll       = "*"
+	ScopeTools     = "tools"
+	ScopeApprovals = "approvals"
+	ScopeIndex     = "index"
+	ScopeMemory    = "memory"
+	ScopeSessions  = "sessions"
+	ScopeUsage     = "usage"
+)
+
+var Scopes = []string{ScopeAll, ScopeTools, ScopeApprovals, ScopeIndex, ScopeMemory, ScopeSessions, ScopeUsage}
+
+var (
+	ErrMissingCredentials = errors.New("auth: missing credentials")
+	ErrInvalidToken       = errors.New("auth: invalid token")
+)
+
+type Principal struct {
+	Name   string
+	Method string
+	Scopes []string
+}
+
+type Authenticator struct {
+	tokens  []config.AuthToken
+	clients []config.AuthClientCert
+	db      *sqlite.DB
+	logger  *logging.Logger
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func New(cfg config.AuthConfig, db *sqlite.DB, logger *logging.Logger) (*Authenticator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	tokens := make([]config.AuthToken, 0, len(cfg.Tokens))
+	for _, token := range cfg.Tokens {
+		hash, err := normalizeHash(token.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("auth: token %s: %w", token.Name, err)
+		}
+		if err := ValidateScopes(token.Scopes); err != nil {
+			return nil, fmt.Errorf("auth: token %s: %w", token.Name, err)
+		}
+		token.Hash = hash
+		tokens = append(tokens, token)
+	}
+	for _, client := range cfg.Clients {
+		if err := ValidateScopes(client.Scopes); err != nil {
+			return nil, fmt.Errorf("auth: client %s: %w", client.CommonName, err)
+		}
+	}
+	return &Authenticator{tokens: tokens, clients: cfg.Clients, db: db, logger: logger}, nil
+}
+
+func GenerateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("auth: generate token: %w", err)
+	}
+	return "mouse_" + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		name := strings.TrimSuffix(strings.TrimSpace(scope), ":read")
+		known := false
+		for _, candidate := range Scopes {
+			if name == candidate {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+func Allows(scopes []string, scope, method string) bool {
+	readOnly := method == http.MethodGet || method == http.MethodHead
+	for _, granted := range scopes {
+		granted = strings.TrimSpace(granted)
+		if granted == ScopeAll || granted == scope {
+			return true
+		}
+		if readOnly && (granted == ScopeAll+":read" || granted == scope+":read") {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Authenticator) Require(scope string, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			if a.logger != nil {
+				a.logger.Warn("api request rejected", map[string]string{
+					"path":   r.URL.Path,
+					"remote": r.RemoteAddr,
+					"error":  err.Error(),
+				})
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mouse"`)
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		if !Allows(principal.Scopes, scope, r.Method) {
+			if a.logger != nil {
+				a.logger.Warn("api request forbidden", map[string]string{
+					"path":      r.URL.Path,
+					"principal": principal.Name,
+					"scope":     scope,
+				})
+			}
+			writeError(w, http.StatusForbidden, fmt.Sprintf("token lacks %s scope", scope))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if token, ok := bearerToken(r); ok {
+		return a.authenticateToken(r.Context(), token)
+	}
+	if principal, ok := a.authenticateCert(r); ok {
+		return principal, nil
+	}
+	return Principal{}, ErrMissingCredentials
+}
+
+func (a *Authenticator) authenticateToken(ctx context.Context, token string) (Principal, error) {
+	hash := HashToken(token)
+	for _, configured := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(configured.Hash), []byte(hash)) == 1 {
+			return Principal{Name: configured.Name, Method: "token", Scopes: configured.Scopes}, nil
+		}
+	}
+	if a.db == nil {
+		return Principal{}, ErrInvalidToken
+	}
+	stored, ok, err := a.db.APITokenByHash(ctx, hash)
+	if err != nil {
+		return Principal{}, err
+	}
+	if !ok {
+		return Principal{}, ErrInvalidToken
+	}
+	if err := a.db.TouchAPIToken(ctx, stored.Name, time.Now()); err != nil && a.logger != nil {
+		a.logger.Warn("api token touch failed", map[string]string{
+			"name":  stored.Name,
+			"error": err.Error(),
+		})
+	}
+	return Principal{Name: stored.Name, Method: "token", Scopes: stored.Scopes}, nil
+}
+
+func (a *Authenticator) authenticateCert(r *http.Request) (Principal, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return Principal{}, false
+	}
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	for _, client := range a.clients {
+		if client.CommonName == name || client.CommonName == "*" {
+			return Principal{Name: name, Method: "mtls", Scopes: client.Scopes}, true
+		}
+	}
+	return Principal{}, false
+}
+
+func TLSConfig(cfg config.AuthTLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("auth: load certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: read client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("auth: client ca contains no certificates")
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func normalizeHash(hash string) (string, error) {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	hash = strings.TrimPrefix(hash, "sha256:")
+	if len(hash) != sha256.Size*2 {
+		return "", errors.New("hash must be a hex sha256 digest")
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", errors.New("hash must be a hex sha256 digest")
+	}
+	return "sha256:" + hash, nil
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: msg})
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"mouse/internal/config"
+	"mouse/internal/sqlite"
+)
+
+func TestRequireEnforcesTokensAndScopes(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.CreateAPIToken(context.Background(), "reader", HashToken("stored-secret"), []string{"sessions:read"}); err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	a, err := New(config.AuthConfig{Enabled: true, Tokens: []config.AuthToken{{Name: "ops", Hash: HashToken("config-secret"), Scopes: []string{"tools"}}}}, db, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	cases := []struct {
+		scope, method, token string
+		want                 int
+	}{
+		{ScopeTools, http.MethodPost, "", http.StatusUnauthorized},
+		{ScopeTools, http.MethodPost, "wrong", http.StatusUnauthorized},
+		{ScopeTools, http.MethodPost, "config-secret", http.StatusNoContent},
+		{ScopeIndex, http.MethodGet, "config-secret", http.StatusForbidden},
+		{ScopeSessions, http.MethodGet, "stored-secret", http.StatusNoContent},
+		{ScopeSessions, http.MethodPost, "stored-secret", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, "/x", nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		rec := httptest.NewRecorder()
+		a.Require(tc.scope, ok).ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s %s with %q: want %d, got %d", tc.method, tc.scope, tc.token, tc.want, rec.Code)
+		}
+	}
+	tokens, err := db.ListAPITokens(context.Background())
+	if err != nil || len(tokens) != 1 || tokens[0].LastUsedAt == "" {
+		t.Fatalf("expected last use recorded, got %+v (%v)", tokens, err)
+	}
+}
+
+func TestClientCertificateScopes(t *testing.T) {
+	a, err := New(config.AuthConfig{Enabled: true, Clients: []config.AuthClientCert{{CommonName: "ops-laptop", Scopes: []string{"*"}}}}, nil, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/tools/run", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "ops-laptop"}}}}}
+	principal, err := a.Authenticate(req)
+	if err != nil || principal.Method != "mtls" || !Allows(principal.Scopes, ScopeTools, http.MethodPost) {
+		t.Fatalf("unexpected principal %+v (%v)", principal, err)
+	}
+	req.TLS.VerifiedChains[0][0].Subject.CommonName = "stranger"
+	if _, err := a.Authenticate(req); err != ErrMissingCredentials {
+		t.Fatalf("expected unknown cert rejected, got %v", err)
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	if a, err := New(config.AuthConfig{}, nil, nil); a != nil || err != nil {
+		t.Fatalf("disabled auth should yield nil authenticator")
+	}
+	if _, err := New(config.AuthConfig{Enabled: true, Tokens: []config.AuthToken{{Name: "x", Hash: "plaintext", Scopes: []string{"tools"}}}}, nil, nil); err == nil {
+		t.Fatalf("expected plaintext hash rejected")
+	}
+	if _, err := New(config.AuthConfig{Enabled: true, Tokens: []config.AuthToken{{Name: "x", Hash: HashToken("t"), Scopes: []string{"root"}}}}, nil, nil); err == nil {
+		t.Fatalf("expected unknown scope rejected")
+	}
+	var disabled *Authenticator
+	rec := httptest.NewRecorder()
+	disabled.Require(ScopeTools, http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("nil authenticator should pass through")
+	}
+}
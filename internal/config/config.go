@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -19,6 +20,9 @@ type Config struct {
 	Index    IndexConfig    `yaml:"index"`
 	Sandbox  SandboxConfig  `yaml:"sandbox"`
 	Cron     CronConfig     `yaml:"cron"`
+	Budgets  BudgetsConfig  `yaml:"budgets"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Admin    AdminConfig    `yaml:"admin"`
 }
 
 type AppConfig struct {
@@ -28,11 +32,19 @@ type AppConfig struct {
 }
 
 type TelegramConfig struct {
-	Enabled   bool            `yaml:"enabled"`
-	Webhook   WebhookConfig   `yaml:"webhook"`
-	BotToken  string          `yaml:"bot_token"`
-	AllowFrom []string        `yaml:"allow_from"`
-	Groups    TelegramGroups  `yaml:"groups"`
+	Enabled   bool                `yaml:"enabled"`
+	Webhook   WebhookConfig       `yaml:"webhook"`
+	BotToken  string              `yaml:"bot_token"`
+	APIBase   string              `yaml:"api_base"`
+	AllowFrom []string            `yaml:"allow_from"`
+	Groups    TelegramGroups      `yaml:"groups"`
+	Commands  map[string][]string `yaml:"commands"`
+	Uploads   UploadsConfig       `yaml:"uploads"`
+}
+
+type UploadsConfig struct {
+	Dir      string `yaml:"dir"`
+	MaxBytes int64  `yaml:"max_bytes"`
 }
 
 type WebhookConfig struct {
@@ -48,22 +60,91 @@ type TelegramGroups struct {
 }
 
 type LLMConfig struct {
+	Provider  string                `yaml:"provider"`
+	APIKey    string                `yaml:"api_key"`
+	Model     string                `yaml:"model"`
+	MaxTokens int                   `yaml:"max_tokens"`
+	BaseURL   string                `yaml:"base_url"`
+	Retry     RetryConfig           `yaml:"retry"`
+	Fallback  []LLMFallback         `yaml:"fallback"`
+	Prices    map[string]ModelPrice `yaml:"prices"`
+	Cache     CacheConfig           `yaml:"cache"`
+	Models    map[string]string     `yaml:"models"`
+	Routes    []LLMRoute            `yaml:"routes"`
+}
+
+type LLMRoute struct {
+	Model       string `yaml:"model"`
+	Job         string `yaml:"job"`
+	Chat        string `yaml:"chat"`
+	MinChars    int    `yaml:"min_chars"`
+	MaxChars    int    `yaml:"max_chars"`
+	Tools       *bool  `yaml:"tools"`
+	Attachments *bool  `yaml:"attachments"`
+}
+
+type CacheConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	TTL     string `yaml:"ttl"`
+}
+
+type ModelPrice struct {
+	InputPerMTok      float64 `yaml:"input_per_mtok"`
+	OutputPerMTok     float64 `yaml:"output_per_mtok"`
+	CacheWritePerMTok float64 `yaml:"cache_write_per_mtok"`
+	CacheReadPerMTok  float64 `yaml:"cache_read_per_mtok"`
+}
+
+type RetryConfig struct {
+	MaxAttempts int `yaml:"max_attempts"`
+	BaseDelayMS int `yaml:"base_delay_ms"`
+	MaxDelayMS  int `yaml:"max_delay_ms"`
+}
+
+type LLMFallback struct {
 	Provider  string `yaml:"provider"`
 	APIKey    string `yaml:"api_key"`
 	Model     string `yaml:"model"`
 	MaxTokens int    `yaml:"max_tokens"`
+	BaseURL   string `yaml:"base_url"`
 }
 
 type SessionsConfig struct {
-	Store              string `yaml:"store"`
-	Dir                string `yaml:"dir"`
-	MaxHistoryMessages int    `yaml:"max_history_messages"`
+	Store              string          `yaml:"store"`
+	Dir                string          `yaml:"dir"`
+	MaxHistoryMessages int             `yaml:"max_history_messages"`
+	Summarize          SummarizeConfig `yaml:"summarize"`
+	Reconcile          ReconcileConfig `yaml:"reconcile"`
+	Retention          RetentionConfig `yaml:"retention"`
+}
+
+type RetentionConfig struct {
+	ArchiveAfterDays int `yaml:"archive_after_days"`
+	DeleteAfterDays  int `yaml:"delete_after_days"`
+}
+
+type ReconcileConfig struct {
+	OnStartup       bool `yaml:"on_startup"`
+	IntervalMinutes int  `yaml:"interval_minutes"`
+}
+
+type SummarizeConfig struct {
+	Enabled         bool `yaml:"enabled"`
+	ThresholdTokens int  `yaml:"threshold_tokens"`
+	KeepRecent      int  `yaml:"keep_recent"`
 }
 
 type MemoryConfig struct {
-	Store    string `yaml:"store"`
-	Dir      string `yaml:"dir"`
-	AutoSync bool   `yaml:"auto_sync"`
+	Store    string              `yaml:"store"`
+	Dir      string              `yaml:"dir"`
+	AutoSync bool                `yaml:"auto_sync"`
+	Extract  MemoryExtractConfig `yaml:"extract"`
+}
+
+type MemoryExtractConfig struct {
+	Enabled     bool `yaml:"enabled"`
+	IdleMinutes int  `yaml:"idle_minutes"`
+	MaxFacts    int  `yaml:"max_facts"`
 }
 
 type IndexConfig struct {
@@ -82,9 +163,9 @@ type WatchConfig struct {
 }
 
 type SandboxConfig struct {
-	Enabled bool           `yaml:"enabled"`
-	Docker  DockerConfig   `yaml:"docker"`
-	Tools   ToolPolicy     `yaml:"tools"`
+	Enabled bool         `yaml:"enabled"`
+	Docker  DockerConfig `yaml:"docker"`
+	Tools   ToolPolicy   `yaml:"tools"`
 }
 
 type DockerConfig struct {
@@ -113,6 +194,52 @@ type CronJob struct {
 	Prompt   string `yaml:"prompt"`
 }
 
+type BudgetsConfig struct {
+	Enabled bool         `yaml:"enabled"`
+	User    BudgetLimits `yaml:"user"`
+	Chat    BudgetLimits `yaml:"chat"`
+	Job     BudgetLimits `yaml:"job"`
+	Global  BudgetLimits `yaml:"global"`
+	Admins  []string     `yaml:"admins"`
+}
+
+type BudgetLimits struct {
+	DailyTokens   int64   `yaml:"daily_tokens"`
+	DailyUSD      float64 `yaml:"daily_usd"`
+	MonthlyTokens int64   `yaml:"monthly_tokens"`
+	MonthlyUSD    float64 `yaml:"monthly_usd"`
+}
+
+type AdminConfig struct {
+	Addr       string `yaml:"addr"`
+	SocketMode string `yaml:"socket_mode"`
+}
+
+type AuthConfig struct {
+	Enabled              bool             `yaml:"enabled"`
+	AllowUnauthenticated bool             `yaml:"allow_unauthenticated"`
+	Tokens               []AuthToken      `yaml:"tokens"`
+	TLS                  AuthTLSConfig    `yaml:"tls"`
+	Clients              []AuthClientCert `yaml:"clients"`
+}
+
+type AuthToken struct {
+	Name   string   `yaml:"name"`
+	Hash   string   `yaml:"hash"`
+	Scopes []string `yaml:"scopes"`
+}
+
+type AuthTLSConfig struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+}
+
+type AuthClientCert struct {
+	CommonName string   `yaml:"common_name"`
+	Scopes     []string `yaml:"scopes"`
+}
+
 func Load(path string) (*Config, error) {
 	raw, err := os.ReadFile(path)
 	if err != nil {
@@ -134,6 +261,9 @@ func (c *Config) expandEnv() {
 	c.Telegram.BotToken = expandEnvValue(c.Telegram.BotToken)
 	c.Telegram.Webhook.Secret = expandEnvValue(c.Telegram.Webhook.Secret)
 	c.LLM.APIKey = expandEnvValue(c.LLM.APIKey)
+	for i := range c.LLM.Fallback {
+		c.LLM.Fallback[i].APIKey = expandEnvValue(c.LLM.Fallback[i].APIKey)
+	}
 }
 
 func expandEnvValue(value string) string {
@@ -153,8 +283,12 @@ func (c *Config) expandWorkspaceRefs() {
 	if workspace == "" {
 		return
 	}
+	if c.Memory.Dir == "" {
+		c.Memory.Dir = filepath.Join(workspace, "memory")
+	}
 	c.Sessions.Dir = expandWorkspace(c.Sessions.Dir, workspace)
 	c.Memory.Dir = expandWorkspace(c.Memory.Dir, workspace)
+	c.Telegram.Uploads.Dir = expandWorkspace(c.Telegram.Uploads.Dir, workspace)
 	c.Index.SQLitePath = expandWorkspace(c.Index.SQLitePath, workspace)
 	for i := range c.Index.Watch.Paths {
 		c.Index.Watch.Paths[i] = expandWorkspace(c.Index.Watch.Paths[i], workspace)
@@ -183,6 +317,14 @@ func (c *Config) Validate() error {
 		if len(c.Telegram.AllowFrom) == 0 {
 			return errors.New("config: telegram.allow_from must include at least one sender")
 		}
+		for name := range c.Telegram.Commands {
+			if strings.TrimSpace(strings.TrimPrefix(name, "/")) == "" {
+				return errors.New("config: telegram.commands keys must name a command")
+			}
+		}
+		if c.Telegram.Uploads.MaxBytes < 0 {
+			return errors.New("config: telegram.uploads.max_bytes must not be negative")
+		}
 		if c.Telegram.Webhook.Enabled {
 			if c.Telegram.Webhook.Path == "" {
 				return errors.New("config: telegram.webhook.path is required when webhook is enabled")
@@ -192,12 +334,92 @@ func (c *Config) Validate() error {
 			}
 		}
 	}
+	if c.LLM.Retry.MaxAttempts < 0 || c.LLM.Retry.BaseDelayMS < 0 || c.LLM.Retry.MaxDelayMS < 0 {
+		return errors.New("config: llm.retry values must not be negative")
+	}
+	if ttl := c.LLM.Cache.TTL; ttl != "" && ttl != "5m" && ttl != "1h" {
+		return fmt.Errorf("config: llm.cache.ttl must be 5m or 1h, got %q", ttl)
+	}
+	for i, route := range c.LLM.Routes {
+		if strings.TrimSpace(route.Model) == "" {
+			return fmt.Errorf("config: llm.routes[%d].model is required", i)
+		}
+		if route.MinChars < 0 || route.MaxChars < 0 {
+			return fmt.Errorf("config: llm.routes[%d] char limits must not be negative", i)
+		}
+	}
+	for model, price := range c.LLM.Prices {
+		if price.InputPerMTok < 0 || price.OutputPerMTok < 0 || price.CacheWritePerMTok < 0 || price.CacheReadPerMTok < 0 {
+			return fmt.Errorf("config: llm.prices.%s must not be negative", model)
+		}
+	}
+	for i, fallback := range c.LLM.Fallback {
+		if strings.TrimSpace(fallback.Provider) == "" && strings.TrimSpace(fallback.Model) == "" {
+			return fmt.Errorf("config: llm.fallback[%d] must set a provider or model", i)
+		}
+	}
+	if c.Budgets.Enabled {
+		for name, limits := range map[string]BudgetLimits{"user": c.Budgets.User, "chat": c.Budgets.Chat, "job": c.Budgets.Job, "global": c.Budgets.Global} {
+			if limits.DailyTokens < 0 || limits.DailyUSD < 0 || limits.MonthlyTokens < 0 || limits.MonthlyUSD < 0 {
+				return fmt.Errorf("config: budgets.%s limits must not be negative", name)
+			}
+		}
+	}
+	for i, token := range c.Auth.Tokens {
+		if strings.TrimSpace(token.Name) == "" || strings.TrimSpace(token.Hash) == "" {
+			return fmt.Errorf("config: auth.tokens[%d] needs a name and hash", i)
+		}
+		if len(token.Scopes) == 0 {
+			return fmt.Errorf("config: auth.tokens[%d] needs at least one scope", i)
+		}
+	}
+	if (c.Auth.TLS.CertFile == "") != (c.Auth.TLS.KeyFile == "") {
+		return errors.New("config: auth.tls.cert_file and key_file must be set together")
+	}
+	if c.Auth.TLS.ClientCAFile != "" && c.Auth.TLS.CertFile == "" {
+		return errors.New("config: auth.tls.client_ca_file requires cert_file and key_file")
+	}
+	for i, client := range c.Auth.Clients {
+		if strings.TrimSpace(client.CommonName) == "" || len(client.Scopes) == 0 {
+			return fmt.Errorf("config: auth.clients[%d] needs a common_name and scopes", i)
+		}
+	}
+	if mode := c.Admin.SocketMode; mode != "" {
+		if _, err := strconv.ParseUint(mode, 8, 32); err != nil {
+			return fmt.Errorf("config: admin.socket_mode must be octal, got %q", mode)
+		}
+	}
 	if c.Sessions.Store != "markdown" {
 		return fmt.Errorf("config: sessions.store must be markdown, got %q", c.Sessions.Store)
 	}
+	if c.Sessions.Summarize.Enabled {
+		if c.Sessions.Summarize.ThresholdTokens <= 0 {
+			return errors.New("config: sessions.summarize.threshold_tokens must be positive when summarize is enabled")
+		}
+		if c.Sessions.Summarize.KeepRecent < 0 {
+			return errors.New("config: sessions.summarize.keep_recent must not be negative")
+		}
+	}
+	if c.Sessions.Reconcile.IntervalMinutes < 0 {
+		return errors.New("config: sessions.reconcile.interval_minutes must not be negative")
+	}
+	if c.Sessions.Retention.ArchiveAfterDays < 0 || c.Sessions.Retention.DeleteAfterDays < 0 {
+		return errors.New("config: sessions.retention days must not be negative")
+	}
 	if c.Memory.Store != "markdown" {
 		return fmt.Errorf("config: memory.store must be markdown, got %q", c.Memory.Store)
 	}
+	if strings.TrimSpace(c.Memory.Dir) == "" {
+		return errors.New("config: memory.dir is required")
+	}
+	if c.Memory.Extract.Enabled {
+		if c.Memory.Extract.IdleMinutes < 0 {
+			return errors.New("config: memory.extract.idle_minutes must not be negative")
+		}
+		if c.Memory.Extract.MaxFacts < 0 {
+			return errors.New("config: memory.extract.max_facts must not be negative")
+		}
+	}
 	if c.Sandbox.Enabled {
 		if c.Sandbox.Docker.Image == "" {
 			return errors.New("config: sandbox.docker.image is required when sandbox is enabled")
@@ -209,6 +431,39 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+func (c LLMConfig) ResolveModel(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", false
+	}
+	if model, ok := c.Models[name]; ok {
+		return model, true
+	}
+	if name == c.Model {
+		return name, true
+	}
+	for _, model := range c.Models {
+		if model == name {
+			return name, true
+		}
+	}
+	if len(c.Models) == 0 {
+		for _, route := range c.Routes {
+			if route.Model == name {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (c *Config) UploadsDir() string {
+	if c.Telegram.Uploads.Dir != "" {
+		return c.Telegram.Uploads.Dir
+	}
+	return filepath.Join(c.App.Workspace, "uploads")
+}
+
 func (c *Config) EnsureRuntimeDirs() error {
 	dirs := []string{
 		c.App.Workspace,
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -102,3 +103,32 @@ func TestLoadConfig(t *testing.T) {
 		t.Fatalf("expected workspace expansion for sessions.dir, got %q", cfg.Sessions.Dir)
 	}
 }
+
+func TestLoadDefaultsMemoryDir(t *testing.T) {
+	raw := strings.Replace(sampleConfig, "  dir: \"${app.workspace}/memory\"\n", "", 1)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("TEST_TG_TOKEN", "token")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.Memory.Dir != filepath.Join("runtime", "memory") {
+		t.Fatalf("expected memory.dir to default under workspace, got %q", cfg.Memory.Dir)
+	}
+}
+
+func TestResolveModelRejectsUnconfiguredNames(t *testing.T) {
+	cfg := LLMConfig{Model: "claude-opus-4-5", Routes: []LLMRoute{{Model: "claude-haiku-4-5", MaxChars: 80}}}
+	for _, name := range []string{"claude-opus-4-5", "claude-haiku-4-5"} {
+		if got, ok := cfg.ResolveModel(name); !ok || got != name {
+			t.Fatalf("expected %s to resolve, got %q %v", name, got, ok)
+		}
+	}
+	if _, ok := cfg.ResolveModel("gpt-9"); ok {
+		t.Fatalf("expected arbitrary model to be rejected")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,6 +24,7 @@ type Scheduler struct {
 	sessions *sessions.Store
 	logger   *logging.Logger
 	mu       sync.Mutex
+	running  sync.WaitGroup
 	jobs     map[string]*job
 }
 
@@ -34,6 +36,13 @@ type job struct {
 	next     time.Time
 }
 
+type JobInfo struct {
+	ID       string    `json:"id"`
+	Schedule string    `json:"schedule"`
+	Session  string    `json:"session"`
+	Next     time.Time `json:"next"`
+}
+
 func New(cfg config.CronConfig, db *sqlite.DB, llmClient llm.Client, sessionsStore *sessions.Store, logger *logging.Logger) (*Scheduler, error) {
 	if !cfg.Enabled {
 		return nil, errors.New("cron: disabled")
@@ -65,7 +74,9 @@ func (s *Scheduler) Start(ctx context.Context) {
 	if s == nil {
 		return
 	}
+	s.running.Add(1)
 	go func() {
+		defer s.running.Done()
 		ticker := time.NewTicker(30 * time.Second)
 		defer ticker.Stop()
 		for {
@@ -73,12 +84,35 @@ func (s *Scheduler) Start(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				s.tick(ctx)
+				s.tick(context.WithoutCancel(ctx))
 			}
 		}
 	}()
 }
 
+func (s *Scheduler) Wait() {
+	if s == nil {
+		return
+	}
+	s.running.Wait()
+}
+
+func (s *Scheduler) Jobs() []JobInfo {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		infos = append(infos, JobInfo{ID: job.id, Schedule: job.schedule, Session: job.session, Next: job.next})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
 func (s *Scheduler) loadJobs() error {
 	for _, jobCfg := range s.cfg.Jobs {
 		parsed, err := parseSchedule(jobCfg.Schedule)
@@ -100,17 +134,20 @@ func (s *Scheduler) loadJobs() error {
 }
 
 func (s *Scheduler) tick(ctx context.Context) {
+	for _, job := range s.due(time.Now().UTC()) {
+		s.runJob(ctx, job)
+	}
+}
+
+func (s *Scheduler) due(now time.Time) []*job {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if len(s.jobs) == 0 {
-		return
-	}
-	now := time.Now().UTC()
+	var due []*job
 	for _, job := range s.jobs {
 		if job.next.IsZero() || job.next.After(now) {
 			continue
 		}
-		s.runJob(ctx, job)
+		due = append(due, job)
 		parsed, err := parseSchedule(job.schedule)
 		if err != nil {
 			if s.logger != nil {
@@ -123,6 +160,7 @@ func (s *Scheduler) tick(ctx context.Context) {
 		}
 		job.next = parsed.next(now)
 	}
+	return due
 }
 
 func (s *Scheduler) runJob(ctx context.Context, job *job) {
@@ -148,7 +186,9 @@ func (s *Scheduler) runJob(ctx context.Context, job *job) {
 		}
 		return
 	}
-	response, err := s.llm.Complete(ctx, prompt)
+	resp, err := s.llm.Chat(llm.WithTags(ctx, llm.Tags{Session: job.session, Job: job.id}), llm.Request{
+		Messages: []llm.Message{{Role: "user", Content: prompt}},
+	})
 	if err != nil {
 		if s.logger != nil {
 			s.logger.Error("cron llm failed", map[string]string{
@@ -158,7 +198,8 @@ func (s *Scheduler) runJob(ctx context.Context, job *job) {
 		}
 		return
 	}
-	if _, err := s.sessions.Append(job.session, "assistant", response); err != nil {
+	response := resp.Text
+	if _, err := s.sessions.AppendModel(job.session, "assistant", response, resp.Model); err != nil {
 		if s.logger != nil {
 			s.logger.Error("cron append response failed", map[string]string{
 				"id":    job.id,
@@ -167,7 +208,7 @@ func (s *Scheduler) runJob(ctx context.Context, job *job) {
 		}
 		return
 	}
-	if _, err := s.db.AppendSessionMessage(ctx, job.session, "assistant", response); err != nil {
+	if _, err := s.db.AppendSessionMessageModel(ctx, job.session, "assistant", response, resp.Model); err != nil {
 		if s.logger != nil {
 			s.logger.Error("cron sqlite append response failed", map[string]string{
 				"id":    job.id,
@@ -0,0 +1,121 @@
+package cron
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mouse/internal/config"
+	"mouse/internal/fakellm"
+	"mouse/internal/llm"
+	"mouse/internal/sessions"
+	"mouse/internal/sqlite"
+)
+
+func TestRunJobAgainstFakeLLM(t *testing.T) {
+	fake := fakellm.New(
+		fakellm.Step{Status: 529, ErrorType: "overloaded_error"},
+		fakellm.Step{Match: "digest", Text: "Nothing new today."},
+	)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, err := sessions.NewStore(filepath.Join(dir, "sessions"))
+	if err != nil {
+		t.Fatalf("session store: %v", err)
+	}
+	client, err := llm.New(llm.Config{
+		Provider:  "anthropic",
+		APIKey:    "test-key",
+		Model:     "claude-test",
+		MaxTokens: 128,
+		BaseURL:   server.URL,
+		Retry:     llm.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+	}, nil)
+	if err != nil {
+		t.Fatalf("llm client: %v", err)
+	}
+	s, err := New(config.CronConfig{Enabled: true, Jobs: []config.CronJob{{ID: "daily", Schedule: "0 8 * * *", Session: "cron-daily", Prompt: "Send the daily digest."}}}, db, client, store, nil)
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	s.jobs["daily"].next = time.Now().Add(-time.Minute)
+	s.tick(context.Background())
+
+	if len(fake.Requests()) != 2 || fake.Remaining() != 0 {
+		t.Fatalf("expected retry after overload, got %d requests", len(fake.Requests()))
+	}
+	transcript, err := store.Load("cron-daily")
+	if err != nil || len(transcript.Entries) != 2 {
+		t.Fatalf("unexpected transcript %+v (%v)", transcript, err)
+	}
+	if got := transcript.Entries[1]; got.Role != "assistant" || got.Content != "Nothing new today." {
+		t.Fatalf("unexpected reply entry %+v", got)
+	}
+	if s.jobs["daily"].next.Before(time.Now()) {
+		t.Fatalf("expected next run rescheduled")
+	}
+}
+
+type blockingLLM struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	return "", nil
+}
+
+func (b *blockingLLM) Chat(ctx context.Context, req llm.Request) (llm.Response, error) {
+	close(b.entered)
+	<-b.release
+	return llm.Response{Text: "done"}, nil
+}
+
+func TestJobsDoesNotWaitForRunningJob(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, err := sessions.NewStore(filepath.Join(dir, "sessions"))
+	if err != nil {
+		t.Fatalf("session store: %v", err)
+	}
+	client := &blockingLLM{entered: make(chan struct{}), release: make(chan struct{})}
+	s, err := New(config.CronConfig{Enabled: true, Jobs: []config.CronJob{{ID: "daily", Schedule: "0 8 * * *", Session: "cron-daily", Prompt: "Send the daily digest."}}}, db, client, store, nil)
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	s.jobs["daily"].next = time.Now().Add(-time.Minute)
+	ticked := make(chan struct{})
+	go func() {
+		s.tick(context.Background())
+		close(ticked)
+	}()
+	<-client.entered
+
+	listed := make(chan []JobInfo, 1)
+	go func() {
+		listed <- s.Jobs()
+	}()
+	select {
+	case jobs := <-listed:
+		if len(jobs) != 1 || jobs[0].Next.Before(time.Now()) {
+			t.Fatalf("expected rescheduled job while running, got %+v", jobs)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Jobs blocked while a job was running")
+	}
+	close(client.release)
+	<-ticked
+}
@@ -0,0 +1,328 @@
+package fakellm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ToolUse struct {
+	ID    string         `yaml:"id" json:"id"`
+	Name  string         `yaml:"name" json:"name"`
+	Input map[string]any `yaml:"input" json:"input"`
+}
+
+type Usage struct {
+	InputTokens  int `yaml:"input_tokens" json:"input_tokens"`
+	OutputTokens int `yaml:"output_tokens" json:"output_tokens"`
+}
+
+type Step struct {
+	Match       string    `yaml:"match" json:"match"`
+	Text        string    `yaml:"text" json:"text"`
+	ToolUse     []ToolUse `yaml:"tool_use" json:"tool_use"`
+	StopReason  string    `yaml:"stop_reason" json:"stop_reason"`
+	Status      int       `yaml:"status" json:"status"`
+	ErrorType   string    `yaml:"error_type" json:"error_type"`
+	RetryAfter  int       `yaml:"retry_after" json:"retry_after"`
+	StreamError string    `yaml:"stream_error" json:"stream_error"`
+	Usage       Usage     `yaml:"usage" json:"usage"`
+}
+
+type Script struct {
+	Steps []Step `yaml:"steps" json:"steps"`
+}
+
+type Request struct {
+	Model    string
+	Stream   bool
+	System   string
+	Tools    []string
+	LastUser string
+	Body     []byte
+}
+
+type Server struct {
+	mu       sync.Mutex
+	steps    []Step
+	requests []Request
+}
+
+func New(steps ...Step) *Server {
+	return &Server{steps: steps}
+}
+
+func Load(path string) (*Server, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fakellm: read script: %w", err)
+	}
+	var script Script
+	if err := yaml.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("fakellm: parse script: %w", err)
+	}
+	return New(script.Steps...), nil
+}
+
+func (s *Server) Add(steps ...Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.steps = append(s.steps, steps...)
+}
+
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) Remaining() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.steps)
+}
+
+type messagesRequest struct {
+	Model    string          `json:"model"`
+	Stream   bool            `json:"stream"`
+	System   json.RawMessage `json:"system"`
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+	Tools []struct {
+		Name string `json:"name"`
+	} `json:"tools"`
+}
+
+type contentBlock struct {
+	Type    string          `json:"type"`
+	Text    string          `json:"text,omitempty"`
+	ID      string          `json:"id,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Input   json.RawMessage `json:"input,omitempty"`
+	Content json.RawMessage `json:"content,omitempty"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/messages") {
+		writeError(w, http.StatusNotFound, "not_found_error", "unknown endpoint "+r.URL.Path)
+		return
+	}
+	var parsed messagesRequest
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &parsed)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid json")
+		return
+	}
+	req := Request{Model: parsed.Model, Stream: parsed.Stream, System: systemText(parsed.System), Body: body}
+	for _, tool := range parsed.Tools {
+		req.Tools = append(req.Tools, tool.Name)
+	}
+	for i := len(parsed.Messages) - 1; i >= 0; i-- {
+		if parsed.Messages[i].Role != "user" {
+			continue
+		}
+		if text := contentText(parsed.Messages[i].Content); text != "" {
+			req.LastUser = text
+			break
+		}
+	}
+	step := s.next(req)
+
+	if step.Status >= 400 {
+		if step.RetryAfter > 0 {
+			w.Header().Set("retry-after", strconv.Itoa(step.RetryAfter))
+		}
+		kind := step.ErrorType
+		if kind == "" {
+			kind = "api_error"
+		}
+		message := step.Text
+		if message == "" {
+			message = http.StatusText(step.Status)
+		}
+		writeError(w, step.Status, kind, message)
+		return
+	}
+	if req.Stream {
+		s.stream(w, req, step)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"id":          "msg_fake",
+		"type":        "message",
+		"role":        "assistant",
+		"model":       req.Model,
+		"content":     blocks(step),
+		"stop_reason": stopReason(step),
+		"usage":       usage(step, req),
+	})
+}
+
+func (s *Server) next(req Request) Step {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	for i, step := range s.steps {
+		if step.Match != "" && !strings.Contains(req.LastUser, step.Match) {
+			continue
+		}
+		s.steps = append(s.steps[:i:i], s.steps[i+1:]...)
+		return step
+	}
+	return Step{Text: "echo: " + req.LastUser}
+}
+
+func (s *Server) stream(w http.ResponseWriter, req Request, step Step) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("content-type", "text/event-stream")
+	send := func(event string, payload map[string]any) {
+		payload["type"] = event
+		data, _ := json.Marshal(payload)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	u := usage(step, req)
+	send("message_start", map[string]any{"message": map[string]any{
+		"id": "msg_fake", "type": "message", "role": "assistant", "model": req.Model, "content": []any{},
+		"usage": map[string]int{"input_tokens": u.InputTokens, "output_tokens": 1},
+	}})
+	content := blocks(step)
+	if len(content) == 0 && step.StreamError != "" {
+		send("error", map[string]any{"error": map[string]any{"type": step.StreamError, "message": "injected stream error"}})
+		return
+	}
+	for i, block := range content {
+		switch block.Type {
+		case "text":
+			send("content_block_start", map[string]any{"index": i, "content_block": map[string]any{"type": "text", "text": ""}})
+			for _, chunk := range chunks(block.Text) {
+				send("content_block_delta", map[string]any{"index": i, "delta": map[string]any{"type": "text_delta", "text": chunk}})
+			}
+		case "tool_use":
+			send("content_block_start", map[string]any{"index": i, "content_block": map[string]any{"type": "tool_use", "id": block.ID, "name": block.Name, "input": map[string]any{}}})
+			input := string(block.Input)
+			half := len(input) / 2
+			for half > 0 && !utf8.RuneStart(input[half]) {
+				half--
+			}
+			for _, part := range []string{input[:half], input[half:]} {
+				send("content_block_delta", map[string]any{"index": i, "delta": map[string]any{"type": "input_json_delta", "partial_json": part}})
+			}
+		}
+		if step.StreamError != "" {
+			send("error", map[string]any{"error": map[string]any{"type": step.StreamError, "message": "injected stream error"}})
+			return
+		}
+		send("content_block_stop", map[string]any{"index": i})
+	}
+	send("message_delta", map[string]any{"delta": map[string]any{"stop_reason": stopReason(step)}, "usage": map[string]int{"output_tokens": u.OutputTokens}})
+	send("message_stop", map[string]any{})
+}
+
+func blocks(step Step) []contentBlock {
+	var out []contentBlock
+	if step.Text != "" {
+		out = append(out, contentBlock{Type: "text", Text: step.Text})
+	}
+	for i, tool := range step.ToolUse {
+		id := tool.ID
+		if id == "" {
+			id = fmt.Sprintf("toolu_fake_%d", i+1)
+		}
+		input, _ := json.Marshal(tool.Input)
+		if tool.Input == nil {
+			input = []byte(`{}`)
+		}
+		out = append(out, contentBlock{Type: "tool_use", ID: id, Name: tool.Name, Input: input})
+	}
+	return out
+}
+
+func stopReason(step Step) string {
+	switch {
+	case step.StopReason != "":
+		return step.StopReason
+	case len(step.ToolUse) > 0:
+		return "tool_use"
+	}
+	return "end_turn"
+}
+
+func usage(step Step, req Request) Usage {
+	u := step.Usage
+	if u.InputTokens == 0 {
+		u.InputTokens = len(req.Body) / 4
+	}
+	if u.OutputTokens == 0 {
+		u.OutputTokens = len(strings.Fields(step.Text)) + 10*len(step.ToolUse)
+	}
+	return u
+}
+
+func chunks(text string) []string {
+	var out []string
+	for len(text) > 0 {
+		i := strings.IndexByte(text[1:], ' ')
+		if i < 0 {
+			out = append(out, text)
+			break
+		}
+		out = append(out, text[:i+1])
+		text = text[i+1:]
+	}
+	return out
+}
+
+func systemText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	return contentText(raw)
+}
+
+func contentText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return strings.TrimSpace(text)
+	}
+	var parsed []contentBlock
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return ""
+	}
+	var parts []string
+	for _, block := range parsed {
+		if block.Type == "text" && strings.TrimSpace(block.Text) != "" {
+			parts = append(parts, strings.TrimSpace(block.Text))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func writeError(w http.ResponseWriter, status int, kind, message string) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"type":  "error",
+		"error": map[string]string{"type": kind, "message": message},
+	})
+}
@@ -0,0 +1,113 @@
+package fakellm
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mouse/internal/llm"
+)
+
+func TestStreamReplaysToolUseAndText(t *testing.T) {
+	fake := New(
+		Step{Text: "Checking now.", ToolUse: []ToolUse{{ID: "toolu_9", Name: "search", Input: map[string]any{"q": "mouse"}}}, Usage: Usage{InputTokens: 40, OutputTokens: 12}},
+	)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client, err := llm.New(llm.Config{Provider: "anthropic", APIKey: "k", Model: "m", MaxTokens: 64, BaseURL: server.URL}, nil)
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+
+	var texts []string
+	resp, err := llm.Stream(context.Background(), client, llm.Request{Messages: []llm.Message{{Role: "user", Content: "find mouse"}}}, func(event llm.StreamEvent) {
+		if event.Type == llm.EventText {
+			texts = append(texts, event.Text)
+		}
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if resp.Text != "Checking now." || len(texts) != 2 || resp.StopReason != "tool_use" {
+		t.Fatalf("unexpected text %+v (%q)", resp, texts)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_9" || string(resp.ToolCalls[0].Input) != `{"q":"mouse"}` {
+		t.Fatalf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+	if resp.Usage.InputTokens != 40 || resp.Usage.OutputTokens != 12 {
+		t.Fatalf("unexpected usage %+v", resp.Usage)
+	}
+	if got := fake.Requests(); len(got) != 1 || !got[0].Stream || got[0].LastUser != "find mouse" {
+		t.Fatalf("unexpected recorded requests %+v", got)
+	}
+}
+
+func TestErrorStepsAndEchoFallback(t *testing.T) {
+	fake := New(Step{Status: 400, ErrorType: "invalid_request_error", Text: "bad prompt"})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client, err := llm.New(llm.Config{Provider: "anthropic", APIKey: "k", Model: "m", MaxTokens: 64, BaseURL: server.URL + "/v1", Retry: llm.RetryPolicy{MaxAttempts: 1}}, nil)
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	req := llm.Request{Messages: []llm.Message{{Role: "user", Content: "hi"}}}
+
+	_, err = client.Chat(context.Background(), req)
+	var apiErr *llm.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != 400 {
+		t.Fatalf("expected 400 api error, got %v", err)
+	}
+	resp, err := client.Chat(context.Background(), req)
+	if err != nil || resp.Text != "echo: hi" {
+		t.Fatalf("expected echo fallback, got %+v (%v)", resp, err)
+	}
+}
+
+func TestStreamErrorWithoutContent(t *testing.T) {
+	fake := New(Step{StreamError: "overloaded_error"})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client, err := llm.New(llm.Config{Provider: "anthropic", APIKey: "k", Model: "m", MaxTokens: 64, BaseURL: server.URL, Retry: llm.RetryPolicy{MaxAttempts: 1}}, nil)
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	_, err = llm.Stream(context.Background(), client, llm.Request{Messages: []llm.Message{{Role: "user", Content: "hi"}}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Fatalf("expected injected stream error, got %v", err)
+	}
+}
+
+func TestLoadScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	script := "steps:\n  - match: hello\n    text: hi there\n  - status: 529\n    error_type: overloaded_error\n    retry_after: 2\n"
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	fake, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if fake.Remaining() != 2 || fake.steps[1].RetryAfter != 2 || fake.steps[0].Match != "hello" {
+		t.Fatalf("unexpected steps %+v", fake.steps)
+	}
+}
+
+func TestStreamSplitsToolInputOnRuneBoundary(t *testing.T) {
+	server := httptest.NewServer(New(Step{ToolUse: []ToolUse{{ID: "toolu_1", Name: "send_file", Input: map[string]any{"path": "résumé.pdf"}}}}))
+	defer server.Close()
+	client, err := llm.New(llm.Config{Provider: "anthropic", APIKey: "k", Model: "m", MaxTokens: 64, BaseURL: server.URL}, nil)
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	resp, err := llm.Stream(context.Background(), client, llm.Request{Messages: []llm.Message{{Role: "user", Content: "send it"}}}, nil)
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if len(resp.ToolCalls) != 1 || string(resp.ToolCalls[0].Input) != `{"path":"résumé.pdf"}` {
+		t.Fatalf("unexpected tool input %+v", resp.ToolCalls)
+	}
+}
@@ -0,0 +1,230 @@
+package faketelegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"mouse/internal/telegram"
+)
+
+type Call struct {
+	Method    string
+	Token     string
+	Params    map[string]any
+	Body      []byte
+	MessageID int64
+}
+
+type Message struct {
+	ID     int64
+	ChatID int64
+	Text   string
+	Edits  int
+}
+
+type Server struct {
+	mu           sync.Mutex
+	calls        []Call
+	messages     []*Message
+	updates      []telegram.Update
+	nextUpdateID int64
+	webhookURL   string
+	secret       string
+	client       *http.Client
+}
+
+func New() *Server {
+	return &Server{nextUpdateID: 1, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	token, method, ok := strings.Cut(strings.TrimPrefix(path, "bot"), "/")
+	if !strings.HasPrefix(path, "bot") || !ok || token == "" || method == "" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: unreadable body")
+		return
+	}
+	params := map[string]any{}
+	switch contentType := r.Header.Get("content-type"); {
+	case strings.HasPrefix(contentType, "application/json") && len(body) > 0:
+		if err := json.Unmarshal(body, &params); err != nil {
+			writeError(w, http.StatusBadRequest, "Bad Request: invalid json")
+			return
+		}
+	case strings.HasPrefix(contentType, "multipart/form-data"):
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeError(w, http.StatusBadRequest, "Bad Request: invalid form")
+			return
+		}
+		for key, values := range r.MultipartForm.Value {
+			if len(values) > 0 {
+				params[key] = values[0]
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, Call{Method: method, Token: token, Params: params, Body: body})
+	switch method {
+	case "sendMessage":
+		msg := &Message{ID: int64(len(s.messages) + 1), ChatID: int64Param(params, "chat_id"), Text: stringParam(params, "text")}
+		s.messages = append(s.messages, msg)
+		s.calls[len(s.calls)-1].MessageID = msg.ID
+		writeResult(w, map[string]any{"message_id": msg.ID, "chat": map[string]any{"id": msg.ChatID}, "text": msg.Text})
+	case "editMessageText":
+		id := int64Param(params, "message_id")
+		if id <= 0 || id > int64(len(s.messages)) {
+			writeError(w, http.StatusBadRequest, "Bad Request: message to edit not found")
+			return
+		}
+		msg := s.messages[id-1]
+		text := stringParam(params, "text")
+		if text == msg.Text {
+			writeError(w, http.StatusBadRequest, "Bad Request: message is not modified")
+			return
+		}
+		msg.Text = text
+		msg.Edits++
+		writeResult(w, map[string]any{"message_id": msg.ID, "text": msg.Text})
+	case "setWebhook":
+		s.webhookURL = stringParam(params, "url")
+		s.secret = stringParam(params, "secret_token")
+		writeResult(w, true)
+	case "deleteWebhook":
+		s.webhookURL = ""
+		s.secret = ""
+		writeResult(w, true)
+	case "getUpdates":
+		offset := int64Param(params, "offset")
+		pending := s.updates[:0]
+		for _, update := range s.updates {
+			if update.UpdateID >= offset {
+				pending = append(pending, update)
+			}
+		}
+		s.updates = pending
+		writeResult(w, append([]telegram.Update{}, pending...))
+	default:
+		writeResult(w, true)
+	}
+}
+
+func (s *Server) Inject(update telegram.Update) telegram.Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update = s.assignID(update)
+	s.updates = append(s.updates, update)
+	return update
+}
+
+func (s *Server) Deliver(ctx context.Context, update telegram.Update) (int, error) {
+	s.mu.Lock()
+	url, secret := s.webhookURL, s.secret
+	update = s.assignID(update)
+	s.mu.Unlock()
+	if url == "" {
+		return 0, errors.New("faketelegram: no webhook registered")
+	}
+	body, err := json.Marshal(update)
+	if err != nil {
+		return 0, fmt.Errorf("faketelegram: marshal update: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("faketelegram: build request: %w", err)
+	}
+	req.Header.Set("content-type", "application/json")
+	if secret != "" {
+		req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("faketelegram: deliver: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+func (s *Server) Webhook() (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.webhookURL, s.secret
+}
+
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Call
+	for _, call := range s.calls {
+		if method == "" || call.Method == method {
+			out = append(out, call)
+		}
+	}
+	return out
+}
+
+func (s *Server) Messages(chatID int64) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Message
+	for _, msg := range s.messages {
+		if chatID == 0 || msg.ChatID == chatID {
+			out = append(out, *msg)
+		}
+	}
+	return out
+}
+
+func (s *Server) assignID(update telegram.Update) telegram.Update {
+	if update.UpdateID == 0 {
+		update.UpdateID = s.nextUpdateID
+	}
+	if update.UpdateID >= s.nextUpdateID {
+		s.nextUpdateID = update.UpdateID + 1
+	}
+	return update
+}
+
+func int64Param(params map[string]any, key string) int64 {
+	switch v := params[key].(type) {
+	case float64:
+		return int64(v)
+	case string:
+		var n int64
+		_, _ = fmt.Sscan(v, &n)
+		return n
+	}
+	return 0
+}
+
+func stringParam(params map[string]any, key string) string {
+	v, _ := params[key].(string)
+	return v
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	w.Header().Set("content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func writeError(w http.ResponseWriter, status int, description string) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "error_code": status, "description": description})
+}
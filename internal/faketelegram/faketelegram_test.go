@@ -0,0 +1,89 @@
+package faketelegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mouse/internal/telegram"
+)
+
+func TestRecordsSenderCalls(t *testing.T) {
+	fake := New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	sender, err := telegram.NewSender(telegram.SenderConfig{APIBase: server.URL, BotToken: "t0ken", AllowFrom: []string{"7"}}, nil)
+	if err != nil {
+		t.Fatalf("sender: %v", err)
+	}
+	ctx := context.Background()
+	user := &telegram.User{ID: 7}
+
+	id, err := sender.PostMessage(ctx, 7, user, "Working on it…")
+	if err != nil || id != 1 {
+		t.Fatalf("post: id=%d err=%v", id, err)
+	}
+	if err := sender.EditMessageText(ctx, 7, id, "Done."); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if err := sender.EditMessageText(ctx, 7, id, "Done."); err != nil {
+		t.Fatalf("unchanged edit should be ignored: %v", err)
+	}
+	if err := sender.SendChatAction(ctx, 7, telegram.ActionTyping); err != nil {
+		t.Fatalf("chat action: %v", err)
+	}
+	messages := fake.Messages(7)
+	if len(messages) != 1 || messages[0].Text != "Done." || messages[0].Edits != 1 {
+		t.Fatalf("unexpected messages %+v", messages)
+	}
+	calls := fake.Calls("sendMessage")
+	if len(calls) != 1 || calls[0].Token != "t0ken" {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+}
+
+func TestWebhookAndUpdates(t *testing.T) {
+	fake := New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	received := make(chan *http.Request, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer target.Close()
+
+	if err := telegram.SetWebhook(context.Background(), server.URL, "t0ken", target.URL, "/hook", "s3cret", nil); err != nil {
+		t.Fatalf("set webhook: %v", err)
+	}
+	if url, secret := fake.Webhook(); url != target.URL+"/hook" || secret != "s3cret" {
+		t.Fatalf("unexpected webhook %q %q", url, secret)
+	}
+	status, err := fake.Deliver(context.Background(), telegram.Update{Message: &telegram.Message{Text: "hi"}})
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("deliver: %d %v", status, err)
+	}
+	if r := <-received; r.Header.Get("X-Telegram-Bot-Api-Secret-Token") != "s3cret" {
+		t.Fatalf("missing secret header")
+	}
+
+	fake.Inject(telegram.Update{Message: &telegram.Message{Text: "queued"}})
+	resp, err := http.Post(server.URL+"/bott0ken/getUpdates", "application/json", nil)
+	if err != nil {
+		t.Fatalf("get updates: %v", err)
+	}
+	defer resp.Body.Close()
+	var parsed struct {
+		Result []telegram.Update `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(parsed.Result) != 1 || parsed.Result[0].UpdateID != 2 || parsed.Result[0].Message.Text != "queued" {
+		t.Fatalf("unexpected updates %+v", parsed.Result)
+	}
+	if len(fake.Calls("getUpdates")) != 1 {
+		t.Fatalf("expected getUpdates recorded")
+	}
+}
@@ -3,29 +3,63 @@ package gateway
 import (
 	"context"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"mouse/internal/approvals"
+	"mouse/internal/auth"
 	"mouse/internal/config"
 	"mouse/internal/cron"
 	"mouse/internal/indexer"
 	"mouse/internal/llm"
 	"mouse/internal/logging"
+	"mouse/internal/memory"
 	"mouse/internal/orchestrator"
 	"mouse/internal/sandbox"
 	"mouse/internal/sessions"
 	"mouse/internal/sqlite"
 	"mouse/internal/telegram"
 	"mouse/internal/tools"
+	"mouse/internal/usage"
 )
 
 type Server struct {
-	cfg    *config.Config
-	logger *logging.Logger
-	mux    *http.ServeMux
-	db     *sqlite.DB
+	cfg        *config.Config
+	logger     *logging.Logger
+	mux        *http.ServeMux
+	public     *http.ServeMux
+	admin      *http.ServeMux
+	auth       *auth.Authenticator
+	trustAdmin bool
+	db         *sqlite.DB
+	workers    []worker
+	cancel     context.CancelFunc
+	mu         sync.Mutex
+	active     int
+	draining   bool
+	idle       chan struct{}
+	stopOnce   sync.Once
+	stopErr    error
+}
+
+type worker struct {
+	name  string
+	start func(context.Context)
+	wait  func()
+}
+
+type Hooks struct {
+	WrapLLM       func(llm.Client) llm.Client
+	WrapTools     func(orchestrator.ToolRunner) orchestrator.ToolRunner
+	WrapProcessor func(telegram.Processor) telegram.Processor
 }
 
 func NewServer(cfg *config.Config, logger *logging.Logger) (*Server, error) {
+	return NewServerWithHooks(cfg, logger, Hooks{})
+}
+
+func NewServerWithHooks(cfg *config.Config, logger *logging.Logger, hooks Hooks) (*Server, error) {
 	mux := http.NewServeMux()
 	db, err := sqlite.Open(cfg.Index.SQLitePath)
 	if err != nil {
@@ -34,87 +68,354 @@ func NewServer(cfg *config.Config, logger *logging.Logger) (*Server, error) {
 		})
 		return nil, err
 	}
-	server := &Server{cfg: cfg, logger: logger, mux: mux, db: db}
+	server := &Server{
+		cfg:        cfg,
+		logger:     logger,
+		mux:        mux,
+		public:     http.NewServeMux(),
+		admin:      http.NewServeMux(),
+		trustAdmin: IsUnixAddr(cfg.Admin.Addr),
+		db:         db,
+	}
+	ready := false
+	defer func() {
+		if !ready {
+			db.Close()
+		}
+	}()
 
-	mux.HandleFunc("/health", server.handleHealth)
-	mux.Handle("/approvals/submit", approvals.NewHandler(logging.New("approvals")))
+	authenticator, err := auth.New(cfg.Auth, db, logging.New("auth"))
+	if err != nil {
+		logger.Error("auth init failed", map[string]string{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	server.auth = authenticator
+	if authenticator == nil {
+		logger.Warn("api authentication disabled", nil)
+	}
 
-	if cfg.Telegram.Enabled && cfg.Telegram.Webhook.Path != "" {
-		orch, err := orchestrator.New(cfg, db, logging.New("orchestrator"))
+	for _, m := range []*http.ServeMux{mux, server.public, server.admin} {
+		m.HandleFunc("/health", server.handleHealth)
+	}
+	server.handleAdmin("/approvals/submit", auth.ScopeApprovals, approvals.NewHandler(logging.New("approvals")))
+
+	tracker, err := usage.NewTracker(cfg.LLM.Prices, cfg.Budgets, db, logging.New("usage"))
+	if err != nil {
+		logger.Error("usage tracker init failed", map[string]string{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	server.handleAdmin("/usage", auth.ScopeUsage, usage.NewHandler(tracker, logging.New("usage-http")))
+
+	sessionStore, err := sessions.NewStore(cfg.Sessions.Dir)
+	if err != nil {
+		logger.Error("session store init failed", map[string]string{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	migrated, err := sessionStore.Migrate()
+	if err != nil {
+		logger.Error("session migration failed", map[string]string{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	if migrated > 0 {
+		logger.Info("sessions migrated", map[string]string{
+			"count":   strconv.Itoa(migrated),
+			"version": strconv.Itoa(sessions.FormatVersion),
+		})
+	}
+	reconciler, err := sessions.NewReconciler(sessionStore, db, logging.New("sessions-reconcile"))
+	if err != nil {
+		logger.Error("session reconciler init failed", map[string]string{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	if cfg.Sessions.Reconcile.OnStartup {
+		reconciler.RunOnce(context.Background())
+	}
+	server.addWorker("sessions-reconcile", func(ctx context.Context) {
+		reconciler.Start(ctx, time.Duration(cfg.Sessions.Reconcile.IntervalMinutes)*time.Minute)
+	}, reconciler.Wait)
+	lifecycle, err := sessions.NewLifecycle(cfg.Sessions.Retention, sessionStore, db, logging.New("sessions-lifecycle"))
+	if err != nil {
+		logger.Error("session lifecycle init failed", map[string]string{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	server.addWorker("sessions-lifecycle", lifecycle.Start, lifecycle.Wait)
+	server.handleAdmin("/sessions/", auth.ScopeSessions, sessions.NewHandler(sessionStore, db, reconciler, lifecycle, logging.New("sessions-http")))
+
+	memoryStore, err := memory.New(cfg.Memory, db, logging.New("memory"))
+	if err != nil {
+		logger.Error("memory init failed", map[string]string{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	server.addWorker("memory-sync", memoryStore.Start, memoryStore.Wait)
+	server.handleAdmin("/memory/", auth.ScopeMemory, memory.NewHandler(memoryStore, logging.New("memory-http")))
+
+	var extractor *memory.Extractor
+	if cfg.Memory.Extract.Enabled {
+		extractor, err = newExtractor(cfg, db, memoryStore, sessionStore, tracker, logger)
 		if err != nil {
-			logger.Error("orchestrator init failed", map[string]string{
-				"error": err.Error(),
-			})
 			return nil, err
 		}
-		tgHandler := telegram.NewHandler(telegram.Config{
-			AllowFrom:      cfg.Telegram.AllowFrom,
-			SecretToken:    cfg.Telegram.Webhook.Secret,
-			RequireWebhook: cfg.Telegram.Webhook.Enabled,
-		}, logging.New("telegram"), orch)
-		mux.Handle(cfg.Telegram.Webhook.Path, tgHandler)
+		server.addWorker("memory-extract", extractor.Start, extractor.Wait)
 	}
 
+	var (
+		runner    *sandbox.Runner
+		policy    *tools.Policy
+		scheduler *cron.Scheduler
+		idx       *indexer.Indexer
+	)
 	if cfg.Sandbox.Enabled {
-		runner, err := sandbox.New(cfg.Sandbox)
+		runner, err = sandbox.New(cfg.Sandbox)
 		if err != nil {
 			logger.Error("sandbox init failed", map[string]string{
 				"error": err.Error(),
 			})
 			return nil, err
 		}
-		policy := tools.NewPolicy(cfg.Sandbox.Tools.Allow, cfg.Sandbox.Tools.Deny)
+		policy = tools.NewPolicy(cfg.Sandbox.Tools.Allow, cfg.Sandbox.Tools.Deny)
 		toolHandler := tools.NewHandler(policy, runner, logging.New("tools"))
-		mux.Handle("/tools/run", toolHandler)
+		server.handleAdmin("/tools/run", auth.ScopeTools, toolHandler)
 	}
 
-	cronClient, cronErr := llm.New(llm.Config{
-		Provider:  cfg.LLM.Provider,
-		APIKey:    cfg.LLM.APIKey,
-		Model:     cfg.LLM.Model,
-		MaxTokens: cfg.LLM.MaxTokens,
-	}, logging.New("cron-llm"))
+	cronClient, cronErr := llm.New(llm.FromConfig(cfg.LLM), logging.New("cron-llm"))
 	if cronErr != nil {
 		logger.Warn("cron llm init failed", map[string]string{
 			"error": cronErr.Error(),
 		})
 	}
 	if cfg.Cron.Enabled && cronClient != nil {
-		sessionStore, err := sessions.NewStore(cfg.Sessions.Dir)
+		scheduler, err = cron.New(cfg.Cron, db, tracker.Wrap(cronClient), sessionStore, logging.New("cron"))
 		if err != nil {
-			logger.Error("cron session store init failed", map[string]string{
+			logger.Error("cron init failed", map[string]string{
 				"error": err.Error(),
 			})
 			return nil, err
 		}
-		scheduler, err := cron.New(cfg.Cron, db, cronClient, sessionStore, logging.New("cron"))
+		server.addWorker("cron", scheduler.Start, scheduler.Wait)
+	}
+
+	if len(cfg.Index.Watch.Paths) > 0 {
+		idx, err = indexer.New(cfg.Index, db, logging.New("indexer"))
 		if err != nil {
-			logger.Error("cron init failed", map[string]string{
+			logger.Error("indexer init failed", map[string]string{
 				"error": err.Error(),
 			})
 			return nil, err
 		}
-		scheduler.Start(context.Background())
+		if cfg.Telegram.Enabled {
+			idx.Watch(cfg.UploadsDir())
+		}
+		server.addWorker("indexer", idx.Start, idx.Wait)
+		server.handleAdmin("/index/search", auth.ScopeIndex, indexer.NewHandler(idx, logging.New("indexer-http")))
+		server.handleAdmin("/index/reindex", auth.ScopeIndex, indexer.NewReindexHandler(idx, logging.New("indexer-http")))
 	}
 
-	if len(cfg.Index.Watch.Paths) > 0 {
-		idx, err := indexer.New(cfg.Index, db, logging.New("indexer"))
+	if cfg.Telegram.Enabled && cfg.Telegram.Webhook.Path != "" {
+		orch, err := orchestrator.New(cfg, db, orchestrator.Deps{
+			Sessions:  sessionStore,
+			Lifecycle: lifecycle,
+			Memory:    memoryStore,
+			Extractor: extractor,
+			Indexer:   idx,
+			Runner:    runner,
+			Policy:    policy,
+			Scheduler: scheduler,
+			Usage:     tracker,
+			WrapLLM:   hooks.WrapLLM,
+			WrapTools: hooks.WrapTools,
+		}, logging.New("orchestrator"))
 		if err != nil {
-			logger.Error("indexer init failed", map[string]string{
+			logger.Error("orchestrator init failed", map[string]string{
 				"error": err.Error(),
 			})
 			return nil, err
 		}
-		idx.Start(context.Background())
-		mux.Handle("/index/search", indexer.NewHandler(idx, logging.New("indexer-http")))
-		mux.Handle("/index/reindex", indexer.NewReindexHandler(idx, logging.New("indexer-http")))
+		var proc telegram.Processor = orch
+		if hooks.WrapProcessor != nil {
+			proc = hooks.WrapProcessor(proc)
+		}
+		tgHandler := telegram.NewHandler(telegram.Config{
+			AllowFrom:      cfg.Telegram.AllowFrom,
+			SecretToken:    cfg.Telegram.Webhook.Secret,
+			RequireWebhook: cfg.Telegram.Webhook.Enabled,
+		}, logging.New("telegram"), proc)
+		server.handlePublic(cfg.Telegram.Webhook.Path, tgHandler)
+		if err := telegram.SetMyCommands(context.Background(), cfg.Telegram.APIBase, cfg.Telegram.BotToken, orch.Commands(), logging.New("telegram-commands")); err != nil {
+			logger.Warn("telegram command registration failed", map[string]string{
+				"error": err.Error(),
+			})
+		}
 	}
 
+	ready = true
 	return server, nil
 }
 
+func newExtractor(cfg *config.Config, db *sqlite.DB, store *memory.Store, sessionStore *sessions.Store, tracker *usage.Tracker, logger *logging.Logger) (*memory.Extractor, error) {
+	client, llmErr := llm.New(llm.FromConfig(cfg.LLM), logging.New("memory-llm"))
+	if llmErr != nil {
+		logger.Warn("memory llm init failed", map[string]string{
+			"error": llmErr.Error(),
+		})
+	}
+	extractor, err := memory.NewExtractor(cfg.Memory.Extract, store, db, tracker.Wrap(client), sessionStore, logging.New("memory-extract"))
+	if err != nil {
+		logger.Error("memory extractor init failed", map[string]string{
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+	return extractor, nil
+}
+
 func (s *Server) Handler() http.Handler {
-	return s.mux
+	return s.track(s.mux)
+}
+
+func (s *Server) PublicHandler() http.Handler {
+	return s.track(s.public)
+}
+
+func (s *Server) AdminHandler() http.Handler {
+	return s.track(s.admin)
+}
+
+func (s *Server) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil || s.draining {
+		return
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	for _, w := range s.workers {
+		w.start(ctx)
+	}
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		err := s.drain(ctx)
+		stalled := err != nil
+		s.mu.Lock()
+		cancel := s.cancel
+		s.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+		for _, w := range s.workers {
+			done := make(chan struct{})
+			go func() {
+				w.wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-ctx.Done():
+				s.logger.Warn("background worker did not stop in time", map[string]string{
+					"worker": w.name,
+				})
+				stalled = true
+				if err == nil {
+					err = ctx.Err()
+				}
+			}
+		}
+		if stalled {
+			s.logger.Warn("leaving database open, requests or workers still running", nil)
+		} else if closeErr := s.db.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		s.logger.Info("gateway stopped", nil)
+		s.stopErr = err
+	})
+	return s.stopErr
+}
+
+func (s *Server) addWorker(name string, start func(context.Context), wait func()) {
+	s.workers = append(s.workers, worker{name: name, start: start, wait: wait})
+}
+
+func (s *Server) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.enter() {
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		defer s.leave()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) enter() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return false
+	}
+	s.active++
+	return true
+}
+
+func (s *Server) leave() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	if s.active == 0 && s.idle != nil {
+		close(s.idle)
+		s.idle = nil
+	}
+}
+
+func (s *Server) drain(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	if s.active == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	s.logger.Info("draining in-flight requests", map[string]string{
+		"active": strconv.Itoa(s.active),
+	})
+	idle := make(chan struct{})
+	s.idle = idle
+	s.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) handlePublic(path string, handler http.Handler) {
+	s.mux.Handle(path, handler)
+	s.public.Handle(path, handler)
+}
+
+func (s *Server) handleAdmin(path, scope string, handler http.Handler) {
+	protected := s.auth.Require(scope, handler)
+	s.mux.Handle(path, protected)
+	if s.trustAdmin {
+		s.admin.Handle(path, handler)
+		return
+	}
+	s.admin.Handle(path, protected)
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,253 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mouse/internal/auth"
+	"mouse/internal/config"
+	"mouse/internal/fakellm"
+	"mouse/internal/faketelegram"
+	"mouse/internal/logging"
+	"mouse/internal/telegram"
+)
+
+func TestWebhookConversationEndToEnd(t *testing.T) {
+	llmFake := fakellm.New(fakellm.Step{Match: "ping", Text: "pong from the fake model"})
+	llmServer := httptest.NewServer(llmFake)
+	defer llmServer.Close()
+	tgFake := faketelegram.New()
+	tgServer := httptest.NewServer(tgFake)
+	defer tgServer.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.App.Workspace = dir
+	cfg.Index.SQLitePath = filepath.Join(dir, "mouse.db")
+	cfg.Sessions.Dir = filepath.Join(dir, "sessions")
+	cfg.Memory.Dir = filepath.Join(dir, "memory")
+	cfg.Telegram = config.TelegramConfig{
+		Enabled:   true,
+		BotToken:  "t0ken",
+		APIBase:   tgServer.URL,
+		AllowFrom: []string{"42"},
+		Webhook:   config.WebhookConfig{Enabled: true, Path: "/telegram-webhook", Secret: "s3cret"},
+	}
+	cfg.LLM = config.LLMConfig{Provider: "anthropic", APIKey: "k", Model: "claude-test", MaxTokens: 128, BaseURL: llmServer.URL}
+
+	server, err := NewServer(cfg, logging.New("gateway-test"))
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	defer server.Shutdown(context.Background())
+	gw := httptest.NewServer(server.Handler())
+	defer gw.Close()
+
+	ctx := context.Background()
+	if err := telegram.SetWebhook(ctx, cfg.Telegram.APIBase, cfg.Telegram.BotToken, gw.URL, cfg.Telegram.Webhook.Path, cfg.Telegram.Webhook.Secret, nil); err != nil {
+		t.Fatalf("set webhook: %v", err)
+	}
+	if len(tgFake.Calls("setMyCommands")) != 1 {
+		t.Fatalf("expected commands registered at startup")
+	}
+
+	update := telegram.Update{Message: &telegram.Message{MessageID: 1, From: &telegram.User{ID: 42}, Chat: &telegram.Chat{ID: 42, Type: "private"}, Text: "ping"}}
+	status, err := tgFake.Deliver(ctx, update)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("deliver: %d %v", status, err)
+	}
+	messages := tgFake.Messages(42)
+	if len(messages) != 1 || messages[0].Text != "pong from the fake model" {
+		t.Fatalf("unexpected outbound messages %+v", messages)
+	}
+	if len(tgFake.Calls("sendChatAction")) == 0 {
+		t.Fatalf("expected typing indicator")
+	}
+
+	stranger := telegram.Update{Message: &telegram.Message{From: &telegram.User{ID: 7}, Chat: &telegram.Chat{ID: 7}, Text: "ping"}}
+	if status, _ := tgFake.Deliver(ctx, stranger); status != http.StatusForbidden {
+		t.Fatalf("expected stranger rejected, got %d", status)
+	}
+
+	status, err = tgFake.Deliver(ctx, telegram.Update{Message: &telegram.Message{From: &telegram.User{ID: 42}, Chat: &telegram.Chat{ID: 42}, Text: "/status"}})
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("deliver command: %d %v", status, err)
+	}
+	messages = tgFake.Messages(42)
+	if len(messages) != 2 || !strings.Contains(messages[1].Text, "claude-test") {
+		t.Fatalf("unexpected status reply %+v", messages)
+	}
+	if len(llmFake.Requests()) != 1 {
+		t.Fatalf("commands should not reach the llm, got %d requests", len(llmFake.Requests()))
+	}
+}
+
+func TestAdminRoutesRequireToken(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.App.Workspace = dir
+	cfg.Index.SQLitePath = filepath.Join(dir, "mouse.db")
+	cfg.Sessions.Dir = filepath.Join(dir, "sessions")
+	cfg.Memory.Dir = filepath.Join(dir, "memory")
+	cfg.Auth = config.AuthConfig{Enabled: true, Tokens: []config.AuthToken{{Name: "ops", Hash: auth.HashToken("s3cret"), Scopes: []string{"usage:read"}}}}
+
+	server, err := NewServer(cfg, logging.New("gateway-test"))
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	defer server.Shutdown(context.Background())
+	for _, tc := range []struct {
+		path, token string
+		want        int
+	}{
+		{"/health", "", http.StatusOK},
+		{"/usage", "", http.StatusUnauthorized},
+		{"/usage", "s3cret", http.StatusOK},
+		{"/sessions/list", "s3cret", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("GET %s: want %d, got %d", tc.path, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestSeparateAdminListener(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.App.Workspace = dir
+	cfg.Index.SQLitePath = filepath.Join(dir, "mouse.db")
+	cfg.Sessions.Dir = filepath.Join(dir, "sessions")
+	cfg.Memory.Dir = filepath.Join(dir, "memory")
+	cfg.Admin.Addr = "unix://" + filepath.Join(dir, "admin.sock")
+	cfg.Auth = config.AuthConfig{Enabled: true}
+
+	server, err := NewServer(cfg, logging.New("gateway-test"))
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	defer server.Shutdown(context.Background())
+	for _, tc := range []struct {
+		name    string
+		handler http.Handler
+		path    string
+		want    int
+	}{
+		{"public health", server.PublicHandler(), "/health", http.StatusOK},
+		{"public usage", server.PublicHandler(), "/usage", http.StatusNotFound},
+		{"socket usage", server.AdminHandler(), "/usage", http.StatusOK},
+		{"combined usage", server.Handler(), "/usage", http.StatusUnauthorized},
+	} {
+		rec := httptest.NewRecorder()
+		tc.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != tc.want {
+			t.Fatalf("%s: want %d, got %d", tc.name, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestShutdownDrainsRequestsAndStopsWorkers(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.App.Workspace = dir
+	cfg.Index.SQLitePath = filepath.Join(dir, "mouse.db")
+	cfg.Sessions.Dir = filepath.Join(dir, "sessions")
+	cfg.Memory.Dir = filepath.Join(dir, "memory")
+	server, err := NewServer(cfg, logging.New("gateway-test"))
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entered, release := make(chan struct{}), make(chan struct{})
+	server.handlePublic("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+	var stopped bool
+	workerDone := make(chan struct{})
+	server.addWorker("test", func(ctx context.Context) {
+		go func() {
+			<-ctx.Done()
+			stopped = true
+			close(workerDone)
+		}()
+	}, func() { <-workerDone })
+	server.Start(context.Background())
+
+	handler := server.Handler()
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-entered
+	result := make(chan error, 1)
+	go func() {
+		result <- server.Shutdown(context.Background())
+	}()
+	for {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if rec.Code == http.StatusServiceUnavailable {
+			break
+		}
+	}
+	select {
+	case err := <-result:
+		t.Fatalf("shutdown returned before in-flight request finished: %v", err)
+	default:
+	}
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if !stopped {
+		t.Fatalf("expected worker context cancelled")
+	}
+	if _, err := server.db.ListAPITokens(context.Background()); err == nil {
+		t.Fatalf("expected db closed")
+	}
+}
+
+func TestShutdownKeepsDBOpenWhenDrainTimesOut(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.App.Workspace = dir
+	cfg.Index.SQLitePath = filepath.Join(dir, "mouse.db")
+	cfg.Sessions.Dir = filepath.Join(dir, "sessions")
+	cfg.Memory.Dir = filepath.Join(dir, "memory")
+	server, err := NewServer(cfg, logging.New("gateway-test"))
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entered, release := make(chan struct{}), make(chan struct{})
+	server.handlePublic("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+	server.Start(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+		close(done)
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected drain timeout, got %v", err)
+	}
+	if _, err := server.db.ListAPITokens(context.Background()); err != nil {
+		t.Fatalf("expected db still open for the running handler: %v", err)
+	}
+	close(release)
+	<-done
+	server.db.Close()
+}
@@ -0,0 +1,113 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"mouse/internal/config"
+)
+
+const defaultSocketMode = 0o600
+
+func IsUnixAddr(addr string) bool {
+	return strings.HasPrefix(addr, "unix:")
+}
+
+func IsLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func CheckExposure(cfg *config.Config, addr string) error {
+	if cfg.Auth.Enabled || cfg.Auth.AllowUnauthenticated {
+		return nil
+	}
+	apiAddr := addr
+	if cfg.Admin.Addr != "" {
+		apiAddr = cfg.Admin.Addr
+	}
+	if IsUnixAddr(apiAddr) || IsLoopbackAddr(apiAddr) {
+		return nil
+	}
+	return fmt.Errorf("gateway: auth is disabled but admin routes would listen on %q; enable auth, move admin.addr to loopback or a unix socket, or set auth.allow_unauthenticated", apiAddr)
+}
+
+func Listen(addr, socketMode string) (net.Listener, error) {
+	if !IsUnixAddr(addr) {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("gateway: listen %s: %w", addr, err)
+		}
+		return listener, nil
+	}
+	path := strings.TrimPrefix(strings.TrimPrefix(addr, "unix:"), "//")
+	if path == "" {
+		return nil, errors.New("gateway: unix socket path is required")
+	}
+	mode := os.FileMode(defaultSocketMode)
+	if socketMode != "" {
+		parsed, err := strconv.ParseUint(socketMode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("gateway: invalid socket mode %q", socketMode)
+		}
+		mode = os.FileMode(parsed)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("gateway: socket dir: %w", err)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("gateway: %s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("gateway: remove stale socket: %w", err)
+		}
+	}
+	private, err := os.MkdirTemp(filepath.Dir(path), ".sock")
+	if err != nil {
+		return nil, fmt.Errorf("gateway: socket dir: %w", err)
+	}
+	defer os.RemoveAll(private)
+	tmp := filepath.Join(private, "s")
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, fmt.Errorf("gateway: listen %s: %w", path, err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("gateway: chmod socket: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("gateway: place socket: %w", err)
+	}
+	return &socketListener{Listener: listener, path: path}, nil
+}
+
+type socketListener struct {
+	net.Listener
+	path string
+	once sync.Once
+}
+
+func (l *socketListener) Close() error {
+	err := l.Listener.Close()
+	l.once.Do(func() {
+		os.Remove(l.path)
+	})
+	return err
+}
@@ -0,0 +1,70 @@
+package gateway
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mouse/internal/config"
+)
+
+func TestCheckExposureFailsClosed(t *testing.T) {
+	cases := []struct {
+		name  string
+		cfg   config.Config
+		addr  string
+		allow bool
+	}{
+		{"all interfaces", config.Config{}, ":8080", false},
+		{"public ip", config.Config{}, "10.0.0.5:8080", false},
+		{"loopback", config.Config{}, "127.0.0.1:8080", true},
+		{"localhost", config.Config{}, "localhost:8080", true},
+		{"ipv6 loopback", config.Config{}, "[::1]:8080", true},
+		{"auth enabled", config.Config{Auth: config.AuthConfig{Enabled: true}}, ":8080", true},
+		{"opt out", config.Config{Auth: config.AuthConfig{AllowUnauthenticated: true}}, ":8080", true},
+		{"loopback admin", config.Config{Admin: config.AdminConfig{Addr: "127.0.0.1:8081"}}, ":8080", true},
+		{"unix admin", config.Config{Admin: config.AdminConfig{Addr: "unix:///run/mouse/admin.sock"}}, ":8080", true},
+		{"public admin", config.Config{Admin: config.AdminConfig{Addr: ":8081"}}, "127.0.0.1:8080", false},
+	}
+	for _, tc := range cases {
+		err := CheckExposure(&tc.cfg, tc.addr)
+		if (err == nil) != tc.allow {
+			t.Fatalf("%s: allow=%v, got err %v", tc.name, tc.allow, err)
+		}
+	}
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	listener, err := Listen("unix://"+path, "")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 socket, got %v (%v)", info.Mode(), err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Fatalf("expected only the socket in its directory, got %d entries", len(entries))
+	}
+	listener.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected socket removed on close, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := Listen("unix://"+path, "0660"); err == nil {
+		t.Fatalf("expected regular file to be left alone")
+	}
+	os.Remove(path)
+	listener, err = Listen("unix:"+path, "0660")
+	if err != nil {
+		t.Fatalf("relisten: %v", err)
+	}
+	defer listener.Close()
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o660 {
+		t.Fatalf("expected 0660 socket, got %v", info.Mode())
+	}
+}
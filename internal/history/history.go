@@ -0,0 +1,175 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"mouse/internal/config"
+	"mouse/internal/llm"
+	"mouse/internal/logging"
+	"mouse/internal/sessions"
+	"mouse/internal/sqlite"
+)
+
+const SummaryRole = "summary"
+
+type Manager struct {
+	db          *sqlite.DB
+	sessions    *sessions.Store
+	llm         llm.Client
+	logger      *logging.Logger
+	maxMessages int
+	summarize   bool
+	threshold   int
+	keepRecent  int
+}
+
+func New(cfg config.SessionsConfig, db *sqlite.DB, store *sessions.Store, client llm.Client, logger *logging.Logger) (*Manager, error) {
+	if db == nil {
+		return nil, errors.New("history: db is required")
+	}
+	if store == nil {
+		return nil, errors.New("history: sessions store is required")
+	}
+	if client == nil {
+		return nil, errors.New("history: llm is required")
+	}
+	maxMessages := cfg.MaxHistoryMessages
+	if maxMessages <= 0 {
+		maxMessages = 50
+	}
+	keepRecent := cfg.Summarize.KeepRecent
+	if keepRecent <= 0 {
+		keepRecent = 10
+	}
+	return &Manager{
+		db:          db,
+		sessions:    store,
+		llm:         client,
+		logger:      logger,
+		maxMessages: maxMessages,
+		summarize:   cfg.Summarize.Enabled,
+		threshold:   cfg.Summarize.ThresholdTokens,
+		keepRecent:  keepRecent,
+	}, nil
+}
+
+func (m *Manager) Build(ctx context.Context, sessionID string) (llm.Request, error) {
+	summary, err := m.db.LatestSessionSummary(ctx, sessionID)
+	if err != nil {
+		return llm.Request{}, err
+	}
+	var after int64
+	if summary != nil {
+		after = summary.ThroughID
+	}
+	turns, err := m.db.ListRecentSessionMessages(ctx, sessionID, after, SummaryRole, m.maxMessages)
+	if err != nil {
+		return llm.Request{}, err
+	}
+	req := llm.Request{Messages: make([]llm.Message, 0, len(turns))}
+	if summary != nil {
+		req.Summary = summary.Content
+	}
+	for _, turn := range turns {
+		req.Messages = append(req.Messages, llm.Message{Role: turn.Role, Content: turn.Content})
+	}
+	return req, nil
+}
+
+func (m *Manager) Compact(ctx context.Context, sessionID string) (bool, error) {
+	if !m.summarize {
+		return false, nil
+	}
+	summary, turns, err := m.load(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	previous := ""
+	if summary != nil {
+		previous = summary.Content
+	}
+	total := EstimateTokens(previous)
+	for _, turn := range turns {
+		total += EstimateTokens(turn.Content)
+	}
+	if total <= m.threshold || len(turns) <= m.keepRecent {
+		return false, nil
+	}
+	older := turns[:len(turns)-m.keepRecent]
+	updated, err := m.llm.Complete(ctx, summaryPrompt(previous, older))
+	if err != nil {
+		return false, fmt.Errorf("history: summarize: %w", err)
+	}
+	updated = strings.TrimSpace(updated)
+	if updated == "" {
+		return false, errors.New("history: empty summary")
+	}
+	throughID := older[len(older)-1].ID
+	if _, err := m.sessions.Append(sessionID, SummaryRole, updated); err != nil {
+		return false, fmt.Errorf("history: append summary: %w", err)
+	}
+	if _, err := m.db.AppendSessionMessage(ctx, sessionID, SummaryRole, updated); err != nil {
+		return false, err
+	}
+	if _, err := m.db.InsertSessionSummary(ctx, sessionID, throughID, updated); err != nil {
+		return false, err
+	}
+	if m.logger != nil {
+		m.logger.Info("session compacted", map[string]string{
+			"session_id":     sessionID,
+			"summarized":     strconv.Itoa(len(older)),
+			"kept":           strconv.Itoa(m.keepRecent),
+			"tokens_before":  strconv.Itoa(total),
+			"summary_tokens": strconv.Itoa(EstimateTokens(updated)),
+		})
+	}
+	return true, nil
+}
+
+func (m *Manager) load(ctx context.Context, sessionID string) (*sqlite.SessionSummary, []sqlite.SessionMessage, error) {
+	summary, err := m.db.LatestSessionSummary(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var after int64
+	if summary != nil {
+		after = summary.ThroughID
+	}
+	messages, err := m.db.ListSessionMessagesAfter(ctx, sessionID, after)
+	if err != nil {
+		return nil, nil, err
+	}
+	turns := messages[:0]
+	for _, msg := range messages {
+		if msg.Role == SummaryRole {
+			continue
+		}
+		turns = append(turns, msg)
+	}
+	return summary, turns, nil
+}
+
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+func summaryPrompt(previous string, turns []sqlite.SessionMessage) string {
+	var b strings.Builder
+	b.WriteString("You are compacting a long conversation so it fits in a limited context window. ")
+	b.WriteString("Write a concise summary that preserves facts, decisions, open questions, user preferences and any commitments made. ")
+	b.WriteString("Respond with the summary only.\n\n")
+	if strings.TrimSpace(previous) != "" {
+		b.WriteString("Existing summary:\n")
+		b.WriteString(previous)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Turns to fold into the summary:\n")
+	for _, turn := range turns {
+		fmt.Fprintf(&b, "%s: %s\n", turn.Role, strings.TrimSpace(turn.Content))
+	}
+	return b.String()
+}
@@ -0,0 +1,184 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mouse/internal/config"
+	"mouse/internal/llm"
+	"mouse/internal/sessions"
+	"mouse/internal/sqlite"
+)
+
+type stubLLM struct {
+	prompts []string
+}
+
+func (s *stubLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	return "user asked about deploys", nil
+}
+
+func (s *stubLLM) Chat(ctx context.Context, req llm.Request) (llm.Response, error) {
+	return llm.Response{Text: "ok"}, nil
+}
+
+func TestCompactSummarizesOlderTurns(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, _ := sessions.NewStore(filepath.Join(dir, "sessions"))
+	client := &stubLLM{}
+	manager, err := New(config.SessionsConfig{
+		MaxHistoryMessages: 50,
+		Summarize:          config.SummarizeConfig{Enabled: true, ThresholdTokens: 20, KeepRecent: 2},
+	}, db, store, client, nil)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 6; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		if _, err := db.AppendSessionMessage(ctx, "7", role, strings.Repeat("word ", 10)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	compacted, err := manager.Compact(ctx, "7")
+	if err != nil || !compacted {
+		t.Fatalf("expected compaction, got %v (%v)", compacted, err)
+	}
+	req, err := manager.Build(ctx, "7")
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if req.Summary != "user asked about deploys" {
+		t.Fatalf("unexpected summary: %q", req.Summary)
+	}
+	if len(req.Messages) != 2 {
+		t.Fatalf("expected 2 recent turns, got %d", len(req.Messages))
+	}
+	raw, err := os.ReadFile(store.Path("7"))
+	if err != nil {
+		t.Fatalf("read session: %v", err)
+	}
+	if !strings.Contains(string(raw), " summary\n") {
+		t.Fatalf("expected summary entry in session file")
+	}
+
+	compacted, err = manager.Compact(ctx, "7")
+	if err != nil || compacted {
+		t.Fatalf("expected no second compaction, got %v (%v)", compacted, err)
+	}
+}
+
+func TestExportAfterCompactKeepsRecentTurns(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, _ := sessions.NewStore(filepath.Join(dir, "sessions"))
+	manager, err := New(config.SessionsConfig{
+		MaxHistoryMessages: 50,
+		Summarize:          config.SummarizeConfig{Enabled: true, ThresholdTokens: 20, KeepRecent: 2},
+	}, db, store, &stubLLM{}, nil)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 6; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		content := fmt.Sprintf("turn %d %s", i, strings.Repeat("word ", 10))
+		if _, err := store.Append("7", role, content); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		if _, err := db.AppendSessionMessage(ctx, "7", role, content); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if compacted, err := manager.Compact(ctx, "7"); err != nil || !compacted {
+		t.Fatalf("expected compaction, got %v (%v)", compacted, err)
+	}
+
+	transcript, err := store.Load("7")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	summarized, err := sessions.SummarizedEntries(ctx, db, "7")
+	if err != nil || summarized != 4 {
+		t.Fatalf("expected 4 summarized entries, got %d (%v)", summarized, err)
+	}
+	var buf bytes.Buffer
+	if err := sessions.Export(&buf, transcript, sessions.ExportAnthropic, summarized); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	var out struct {
+		System   string `json:"system"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.Contains(out.System, "user asked about deploys") {
+		t.Fatalf("expected summary as system prompt, got %q", out.System)
+	}
+	if len(out.Messages) != 2 || !strings.HasPrefix(out.Messages[0].Content, "turn 4") || !strings.HasPrefix(out.Messages[1].Content, "turn 5") {
+		t.Fatalf("expected the two recent turns, got %+v", out.Messages)
+	}
+}
+
+func TestBuildLoadsOnlyNewestTurns(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, _ := sessions.NewStore(filepath.Join(dir, "sessions"))
+	manager, err := New(config.SessionsConfig{MaxHistoryMessages: 3}, db, store, &stubLLM{}, nil)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 6; i++ {
+		if _, err := db.AppendSessionMessage(ctx, "7", "user", fmt.Sprintf("turn %d", i)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		if i == 4 {
+			if _, err := db.AppendSessionMessage(ctx, "7", SummaryRole, "stale summary"); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+	}
+	req, err := manager.Build(ctx, "7")
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	var got []string
+	for _, msg := range req.Messages {
+		got = append(got, msg.Content)
+	}
+	if strings.Join(got, ",") != "turn 3,turn 4,turn 5" {
+		t.Fatalf("expected newest three turns in order, got %v", got)
+	}
+}
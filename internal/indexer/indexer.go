@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -25,6 +26,20 @@ type Indexer struct {
 	logger   *logging.Logger
 	interval time.Duration
 	started  atomic.Bool
+	running  sync.WaitGroup
+	mu       sync.Mutex
+	extra    []string
+}
+
+var textExtensions = map[string]struct{}{
+	".md":       {},
+	".markdown": {},
+	".txt":      {},
+	".csv":      {},
+	".json":     {},
+	".log":      {},
+	".yaml":     {},
+	".yml":      {},
 }
 
 type Match struct {
@@ -47,7 +62,9 @@ func (i *Indexer) Start(ctx context.Context) {
 	if !i.started.CompareAndSwap(false, true) {
 		return
 	}
+	i.running.Add(1)
 	go func() {
+		defer i.running.Done()
 		_ = i.ScanOnce(ctx)
 		ticker := time.NewTicker(i.interval)
 		defer ticker.Stop()
@@ -66,40 +83,94 @@ func (i *Indexer) Start(ctx context.Context) {
 	}()
 }
 
+func (i *Indexer) Wait() {
+	if i == nil {
+		return
+	}
+	i.running.Wait()
+}
+
 func (i *Indexer) ScanOnce(ctx context.Context) error {
 	if i == nil {
 		return errors.New("indexer: nil")
 	}
-	paths := i.cfg.Watch.Paths
-	if len(paths) == 0 {
+	i.mu.Lock()
+	extra := append([]string(nil), i.extra...)
+	i.mu.Unlock()
+	if len(i.cfg.Watch.Paths) == 0 && len(extra) == 0 {
 		return nil
 	}
 	seen := make(map[string]struct{})
-	for _, root := range paths {
-		root = strings.TrimSpace(root)
-		if root == "" {
+	for _, root := range i.cfg.Watch.Paths {
+		if err := i.walk(ctx, root, isMarkdown, seen); err != nil {
+			return err
+		}
+	}
+	for _, root := range extra {
+		if _, err := os.Stat(root); os.IsNotExist(err) {
 			continue
 		}
-		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if entry.IsDir() {
-				return nil
-			}
-			if !strings.HasSuffix(strings.ToLower(entry.Name()), ".md") {
-				return nil
-			}
-			seen[path] = struct{}{}
-			return i.indexFile(ctx, path)
-		})
-		if err != nil {
-			return fmt.Errorf("indexer: walk %s: %w", root, err)
+		if err := i.walk(ctx, root, IsText, seen); err != nil {
+			return err
 		}
 	}
 	return i.removeMissing(ctx, seen)
 }
 
+func (i *Indexer) Watch(root string) {
+	if i == nil || strings.TrimSpace(root) == "" {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, existing := range i.extra {
+		if existing == root {
+			return
+		}
+	}
+	i.extra = append(i.extra, root)
+}
+
+func (i *Indexer) IndexFile(ctx context.Context, path string) error {
+	if i == nil {
+		return errors.New("indexer: nil")
+	}
+	return i.indexFile(ctx, path)
+}
+
+func IsText(name string) bool {
+	_, ok := textExtensions[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+func isMarkdown(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".md")
+}
+
+func (i *Indexer) walk(ctx context.Context, root string, match func(string) bool, seen map[string]struct{}) error {
+	root = strings.TrimSpace(root)
+	if root == "" {
+		return nil
+	}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if !match(entry.Name()) {
+			return nil
+		}
+		seen[path] = struct{}{}
+		return i.indexFile(ctx, path)
+	})
+	if err != nil {
+		return fmt.Errorf("indexer: walk %s: %w", root, err)
+	}
+	return nil
+}
+
 func (i *Indexer) Search(ctx context.Context, query string, limit int) ([]Match, error) {
 	if i == nil {
 		return nil, errors.New("indexer: nil")
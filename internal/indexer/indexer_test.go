@@ -1,6 +1,14 @@
 package indexer
 
-import "testing"
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mouse/internal/config"
+	"mouse/internal/sqlite"
+)
 
 func TestTokenize(t *testing.T) {
 	got := tokenize("Hello, world! hello 123")
@@ -18,3 +26,37 @@ func TestSimilarity(t *testing.T) {
 		t.Fatalf("expected positive score")
 	}
 }
+
+func TestScanOnceIndexesWatchedTextFiles(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	uploads := filepath.Join(dir, "uploads")
+	if err := os.MkdirAll(uploads, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(uploads, "notes.txt"), []byte("quarterly budget review"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(uploads, "photo.jpg"), []byte("binary budget"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	idx, err := New(config.IndexConfig{}, db, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	idx.Watch(uploads)
+	if err := idx.ScanOnce(context.Background()); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	matches, err := idx.Search(context.Background(), "budget", 5)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(matches) != 1 || filepath.Base(matches[0].Path) != "notes.txt" {
+		t.Fatalf("expected only notes.txt, got %+v", matches)
+	}
+}
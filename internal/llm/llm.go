@@ -3,6 +3,7 @@ package llm
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,7 +11,9 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"mouse/internal/config"
 	"mouse/internal/logging"
 )
 
@@ -21,6 +24,55 @@ const (
 
 type Client interface {
 	Complete(ctx context.Context, prompt string) (string, error)
+	Chat(ctx context.Context, req Request) (Response, error)
+}
+
+type Message struct {
+	Role        string
+	Content     string
+	Attachments []Attachment
+	ToolCalls   []ToolCall
+	ToolResults []ToolResult
+}
+
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema json.RawMessage
+}
+
+type ToolCall struct {
+	ID    string
+	Name  string
+	Input json.RawMessage
+}
+
+type ToolResult struct {
+	ToolUseID string
+	Content   string
+	IsError   bool
+}
+
+type Attachment struct {
+	Name      string
+	MediaType string
+	Data      []byte
+}
+
+type Request struct {
+	Model    string
+	System   string
+	Summary  string
+	Messages []Message
+	Tools    []Tool
+}
+
+type Response struct {
+	Text       string
+	StopReason string
+	ToolCalls  []ToolCall
+	Model      string
+	Usage      Usage
 }
 
 type Config struct {
@@ -28,9 +80,104 @@ type Config struct {
 	APIKey    string
 	Model     string
 	MaxTokens int
+	BaseURL   string
+	Retry     RetryPolicy
+	Cache     CachePolicy
+	Routes    []Route
+	Fallbacks []Config
+}
+
+type CachePolicy struct {
+	Enabled bool
+	TTL     string
+}
+
+func FromConfig(cfg config.LLMConfig) Config {
+	out := Config{
+		Provider:  cfg.Provider,
+		APIKey:    cfg.APIKey,
+		Model:     cfg.Model,
+		MaxTokens: cfg.MaxTokens,
+		BaseURL:   cfg.BaseURL,
+		Retry: RetryPolicy{
+			MaxAttempts: cfg.Retry.MaxAttempts,
+			BaseDelay:   time.Duration(cfg.Retry.BaseDelayMS) * time.Millisecond,
+			MaxDelay:    time.Duration(cfg.Retry.MaxDelayMS) * time.Millisecond,
+		},
+		Cache: CachePolicy{Enabled: cfg.Cache.Enabled, TTL: cfg.Cache.TTL},
+	}
+	for _, route := range cfg.Routes {
+		model, ok := cfg.ResolveModel(route.Model)
+		if !ok {
+			model = route.Model
+		}
+		out.Routes = append(out.Routes, Route{
+			Model:       model,
+			Job:         route.Job,
+			Chat:        route.Chat,
+			MinChars:    route.MinChars,
+			MaxChars:    route.MaxChars,
+			Tools:       route.Tools,
+			Attachments: route.Attachments,
+		})
+	}
+	for _, fallback := range cfg.Fallback {
+		next := Config{
+			Provider:  fallback.Provider,
+			APIKey:    fallback.APIKey,
+			Model:     fallback.Model,
+			MaxTokens: fallback.MaxTokens,
+			BaseURL:   fallback.BaseURL,
+			Cache:     out.Cache,
+		}
+		sameProvider := strings.TrimSpace(next.Provider) == "" || strings.EqualFold(next.Provider, cfg.Provider)
+		if strings.TrimSpace(next.Provider) == "" {
+			next.Provider = cfg.Provider
+		}
+		if sameProvider && next.APIKey == "" {
+			next.APIKey = cfg.APIKey
+		}
+		if sameProvider && next.BaseURL == "" {
+			next.BaseURL = cfg.BaseURL
+		}
+		if next.Model == "" {
+			next.Model = cfg.Model
+		}
+		if next.MaxTokens <= 0 {
+			next.MaxTokens = cfg.MaxTokens
+		}
+		out.Fallbacks = append(out.Fallbacks, next)
+	}
+	return out
 }
 
 func New(cfg Config, logger *logging.Logger) (Client, error) {
+	primary, err := newClient(cfg, logger)
+	var targets []target
+	if err == nil {
+		targets = append(targets, target{provider: cfg.Provider, model: cfg.Model, client: primary})
+	}
+	for _, fallback := range cfg.Fallbacks {
+		client, fallbackErr := newClient(fallback, logger)
+		if fallbackErr != nil {
+			if logger != nil {
+				logger.Warn("llm fallback skipped", map[string]string{
+					"provider": fallback.Provider,
+					"model":    fallback.Model,
+					"error":    fallbackErr.Error(),
+				})
+			}
+			continue
+		}
+		targets = append(targets, target{provider: fallback.Provider, model: fallback.Model, client: client, fallback: true})
+	}
+	if len(targets) == 0 {
+		return primary, err
+	}
+	return WithRoutes(newResilient(targets, cfg.Retry, logger), cfg.Routes), err
+}
+
+func newClient(cfg Config, logger *logging.Logger) (Client, error) {
 	provider := strings.ToLower(strings.TrimSpace(cfg.Provider))
 	switch provider {
 	case "claude", "anthropic":
@@ -45,14 +192,18 @@ func New(cfg Config, logger *logging.Logger) (Client, error) {
 			maxTokens = 1024
 		}
 		return &anthropicClient{
-			apiKey:     cfg.APIKey,
-			model:      cfg.Model,
-			maxTokens:  maxTokens,
-			baseURL:    anthropicURL,
-			version:    anthropicVersion,
-			httpClient: &http.Client{Timeout: 30 * time.Second},
-			logger:     logger,
+			apiKey:       cfg.APIKey,
+			model:        cfg.Model,
+			maxTokens:    maxTokens,
+			baseURL:      anthropicEndpoint(cfg.BaseURL),
+			version:      anthropicVersion,
+			httpClient:   &http.Client{Timeout: 30 * time.Second},
+			streamClient: &http.Client{Timeout: 5 * time.Minute},
+			cache:        cfg.Cache,
+			logger:       logger,
 		}, nil
+	case "openai", "openai-compatible", "vllm", "ollama", "llamacpp":
+		return newOpenAI(cfg, logger)
 	default:
 		if provider == "" {
 			provider = "unknown"
@@ -65,89 +216,354 @@ type Noop struct {
 	reason string
 }
 
+func anthropicEndpoint(base string) string {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	switch {
+	case base == "":
+		return anthropicURL
+	case strings.HasSuffix(base, "/v1/messages"):
+		return base
+	case strings.HasSuffix(base, "/v1"):
+		return base + "/messages"
+	}
+	return base + "/v1/messages"
+}
+
 func (n *Noop) Complete(ctx context.Context, prompt string) (string, error) {
 	return "", fmt.Errorf("llm disabled: %s", n.reason)
 }
 
+func (n *Noop) Chat(ctx context.Context, req Request) (Response, error) {
+	return Response{}, fmt.Errorf("llm disabled: %s", n.reason)
+}
+
 type anthropicClient struct {
-	apiKey     string
-	model      string
-	maxTokens  int
-	baseURL    string
-	version    string
-	httpClient *http.Client
-	logger     *logging.Logger
+	apiKey       string
+	model        string
+	maxTokens    int
+	baseURL      string
+	version      string
+	httpClient   *http.Client
+	streamClient *http.Client
+	cache        CachePolicy
+	logger       *logging.Logger
 }
 
 type message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string         `json:"role"`
+	Content []requestBlock `json:"content"`
+}
+
+type requestBlock struct {
+	Type         string          `json:"type"`
+	Text         string          `json:"text,omitempty"`
+	Title        string          `json:"title,omitempty"`
+	Source       *blockSource    `json:"source,omitempty"`
+	ID           string          `json:"id,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	Input        json.RawMessage `json:"input,omitempty"`
+	ToolUseID    string          `json:"tool_use_id,omitempty"`
+	Content      string          `json:"content,omitempty"`
+	IsError      bool            `json:"is_error,omitempty"`
+	CacheControl *cacheControl   `json:"cache_control,omitempty"`
+}
+
+type toolSpec struct {
+	Name         string          `json:"name"`
+	Description  string          `json:"description,omitempty"`
+	InputSchema  json.RawMessage `json:"input_schema"`
+	CacheControl *cacheControl   `json:"cache_control,omitempty"`
+}
+
+type cacheControl struct {
+	Type string `json:"type"`
+	TTL  string `json:"ttl,omitempty"`
+}
+
+type blockSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
 }
 
 type messagesRequest struct {
-	Model     string    `json:"model"`
-	MaxTokens int       `json:"max_tokens"`
-	Messages  []message `json:"messages"`
+	Model     string     `json:"model"`
+	MaxTokens int        `json:"max_tokens"`
+	System    any        `json:"system,omitempty"`
+	Messages  []message  `json:"messages"`
+	Tools     []toolSpec `json:"tools,omitempty"`
+	Stream    bool       `json:"stream,omitempty"`
 }
 
 type contentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type  string          `json:"type"`
+	Text  string          `json:"text"`
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
 }
 
 type messagesResponse struct {
 	Content    []contentBlock `json:"content"`
 	StopReason string         `json:"stop_reason"`
+	Usage      anthropicUsage `json:"usage"`
 }
 
-func (c *anthropicClient) Complete(ctx context.Context, prompt string) (string, error) {
-	payload := messagesRequest{
-		Model:     c.model,
-		MaxTokens: c.maxTokens,
-		Messages: []message{
-			{Role: "user", Content: prompt},
-		},
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+func (u anthropicUsage) usage() Usage {
+	return Usage{
+		InputTokens:         u.InputTokens,
+		OutputTokens:        u.OutputTokens,
+		CacheCreationTokens: u.CacheCreationInputTokens,
+		CacheReadTokens:     u.CacheReadInputTokens,
 	}
-	body, err := json.Marshal(payload)
+}
+
+func (c *anthropicClient) Complete(ctx context.Context, prompt string) (string, error) {
+	resp, err := c.Chat(ctx, Request{
+		Messages: []Message{{Role: "user", Content: prompt}},
+	})
 	if err != nil {
-		return "", fmt.Errorf("llm: marshal: %w", err)
+		return "", err
 	}
+	return resp.Text, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL, bytes.NewReader(body))
+func (c *anthropicClient) Chat(ctx context.Context, req Request) (Response, error) {
+	httpReq, err := c.newRequest(ctx, req, false)
 	if err != nil {
-		return "", fmt.Errorf("llm: request: %w", err)
+		return Response{}, err
 	}
-	req.Header.Set("x-api-key", c.apiKey)
-	req.Header.Set("anthropic-version", c.version)
-	req.Header.Set("content-type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("llm: post: %w", err)
+		return Response{}, fmt.Errorf("llm: post: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("llm: read response: %w", err)
+		return Response{}, fmt.Errorf("llm: read response: %w", err)
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", fmt.Errorf("llm: http %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		return Response{}, newAPIError(resp, respBody)
 	}
 
 	var parsed messagesResponse
 	if err := json.Unmarshal(respBody, &parsed); err != nil {
-		return "", fmt.Errorf("llm: decode response: %w", err)
+		return Response{}, fmt.Errorf("llm: decode response: %w", err)
 	}
+	out := Response{StopReason: parsed.StopReason, Model: modelFor(req, c.model), Usage: parsed.Usage.usage()}
+	var texts []string
 	for _, block := range parsed.Content {
-		if block.Type == "text" && strings.TrimSpace(block.Text) != "" {
-			return block.Text, nil
+		switch block.Type {
+		case "text":
+			if strings.TrimSpace(block.Text) != "" {
+				texts = append(texts, block.Text)
+			}
+		case "tool_use":
+			out.ToolCalls = append(out.ToolCalls, ToolCall{ID: block.ID, Name: block.Name, Input: block.Input})
 		}
 	}
+	out.Text = strings.Join(texts, "\n\n")
+	if out.Text != "" || len(out.ToolCalls) > 0 {
+		return out, nil
+	}
 	if c.logger != nil {
 		c.logger.Warn("llm response contained no text", map[string]string{
 			"stop_reason": parsed.StopReason,
 		})
 	}
-	return "", errors.New("llm: empty response")
+	return Response{}, errors.New("llm: empty response")
+}
+
+func (c *anthropicClient) newRequest(ctx context.Context, req Request, stream bool) (*http.Request, error) {
+	messages := normalizeMessages(req.Messages)
+	if len(messages) == 0 {
+		return nil, errors.New("llm: no messages")
+	}
+	payload := messagesRequest{
+		Model:     modelFor(req, c.model),
+		MaxTokens: c.maxTokens,
+		System:    systemPrompt(req),
+		Messages:  messages,
+		Tools:     toolSpecs(req.Tools),
+		Stream:    stream,
+	}
+	if payload.System == "" {
+		payload.System = nil
+	}
+	if c.cache.Enabled {
+		c.applyCache(&payload, req)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("llm: marshal: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("llm: request: %w", err)
+	}
+	httpReq.Header.Set("x-api-key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", c.version)
+	httpReq.Header.Set("content-type", "application/json")
+	if stream {
+		httpReq.Header.Set("accept", "text/event-stream")
+	}
+	return httpReq, nil
+}
+
+func (c *anthropicClient) applyCache(payload *messagesRequest, req Request) {
+	breakpoint := &cacheControl{Type: "ephemeral", TTL: c.cache.TTL}
+	if n := len(payload.Tools); n > 0 {
+		payload.Tools[n-1].CacheControl = breakpoint
+	}
+	var system []requestBlock
+	if text := strings.TrimSpace(req.System); text != "" {
+		system = append(system, requestBlock{Type: "text", Text: text, CacheControl: breakpoint})
+	}
+	if text := summaryBlock(req); text != "" {
+		system = append(system, requestBlock{Type: "text", Text: text, CacheControl: breakpoint})
+	}
+	if len(system) > 0 {
+		payload.System = system
+	}
+	if n := len(payload.Messages); n > 0 {
+		last := payload.Messages[n-1].Content
+		last[len(last)-1].CacheControl = breakpoint
+	}
+}
+
+func modelFor(req Request, fallback string) string {
+	if model := strings.TrimSpace(req.Model); model != "" {
+		return model
+	}
+	return fallback
+}
+
+func systemPrompt(req Request) string {
+	system := strings.TrimSpace(req.System)
+	block := summaryBlock(req)
+	if block == "" {
+		return system
+	}
+	if system == "" {
+		return block
+	}
+	return system + "\n\n" + block
+}
+
+func summaryBlock(req Request) string {
+	summary := strings.TrimSpace(req.Summary)
+	if summary == "" {
+		return ""
+	}
+	return "Summary of the earlier conversation:\n" + summary
+}
+
+func normalizeMessages(history []Message) []message {
+	var out []message
+	for _, msg := range history {
+		content := strings.TrimSpace(msg.Content)
+		blocks := toolResultBlocks(msg.ToolResults)
+		blocks = append(blocks, attachmentBlocks(msg.Attachments)...)
+		role := strings.ToLower(strings.TrimSpace(msg.Role))
+		switch role {
+		case "assistant":
+		case "user", "system":
+			role = "user"
+		default:
+			continue
+		}
+		if len(out) == 0 && role != "user" {
+			continue
+		}
+		if content != "" {
+			blocks = append(blocks, requestBlock{Type: "text", Text: content})
+		}
+		for _, call := range msg.ToolCalls {
+			blocks = append(blocks, requestBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: toolInput(call.Input)})
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = mergeBlocks(out[n-1].Content, blocks)
+			continue
+		}
+		out = append(out, message{Role: role, Content: blocks})
+	}
+	return out
+}
+
+func toolResultBlocks(results []ToolResult) []requestBlock {
+	blocks := make([]requestBlock, 0, len(results))
+	for _, result := range results {
+		blocks = append(blocks, requestBlock{Type: "tool_result", ToolUseID: result.ToolUseID, Content: result.Content, IsError: result.IsError})
+	}
+	return blocks
+}
+
+func toolSpecs(tools []Tool) []toolSpec {
+	specs := make([]toolSpec, 0, len(tools))
+	for _, tool := range tools {
+		specs = append(specs, toolSpec{Name: tool.Name, Description: tool.Description, InputSchema: toolInput(tool.InputSchema)})
+	}
+	return specs
+}
+
+func toolInput(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return json.RawMessage(`{}`)
+	}
+	return raw
+}
+
+func mergeBlocks(existing, next []requestBlock) []requestBlock {
+	for _, block := range next {
+		last := len(existing) - 1
+		if block.Type == "text" && last >= 0 && existing[last].Type == "text" {
+			existing[last].Text += "\n\n" + block.Text
+			continue
+		}
+		existing = append(existing, block)
+	}
+	return existing
+}
+
+func attachmentBlocks(attachments []Attachment) []requestBlock {
+	var blocks []requestBlock
+	for _, att := range attachments {
+		if len(att.Data) == 0 {
+			continue
+		}
+		mediaType := strings.ToLower(strings.TrimSpace(att.MediaType))
+		switch {
+		case mediaType == "image/jpeg" || mediaType == "image/png" || mediaType == "image/gif" || mediaType == "image/webp":
+			blocks = append(blocks, requestBlock{
+				Type:   "image",
+				Source: &blockSource{Type: "base64", MediaType: mediaType, Data: base64.StdEncoding.EncodeToString(att.Data)},
+			})
+		case mediaType == "application/pdf":
+			blocks = append(blocks, requestBlock{
+				Type:   "document",
+				Title:  att.Name,
+				Source: &blockSource{Type: "base64", MediaType: mediaType, Data: base64.StdEncoding.EncodeToString(att.Data)},
+			})
+		case strings.HasPrefix(mediaType, "text/") && utf8.Valid(att.Data):
+			blocks = append(blocks, requestBlock{
+				Type:   "document",
+				Title:  att.Name,
+				Source: &blockSource{Type: "text", MediaType: "text/plain", Data: string(att.Data)},
+			})
+		}
+	}
+	return blocks
 }
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeMessagesAttachments(t *testing.T) {
+	out := normalizeMessages([]Message{
+		{Role: "assistant", Content: "dropped"},
+		{Role: "user", Content: "first"},
+		{Role: "system", Content: "second", Attachments: []Attachment{
+			{Name: "chart.png", MediaType: "image/png", Data: []byte{0x89, 'P', 'N', 'G'}},
+			{Name: "notes.txt", MediaType: "text/plain", Data: []byte("hello")},
+			{Name: "blob.bin", MediaType: "application/octet-stream", Data: []byte{0}},
+		}},
+		{Role: "assistant", Content: "answer"},
+	})
+	if len(out) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(out))
+	}
+	blocks := out[0].Content
+	if len(blocks) != 4 {
+		t.Fatalf("expected text, image, document, text blocks, got %+v", blocks)
+	}
+	if blocks[1].Type != "image" || blocks[1].Source.Type != "base64" || blocks[1].Source.MediaType != "image/png" {
+		t.Fatalf("unexpected image block %+v", blocks[1])
+	}
+	if blocks[2].Type != "document" || blocks[2].Source.Type != "text" || blocks[2].Source.Data != "hello" {
+		t.Fatalf("unexpected document block %+v", blocks[2])
+	}
+	if blocks[3].Text != "second" || out[1].Content[0].Text != "answer" {
+		t.Fatalf("unexpected text blocks %+v", out)
+	}
+}
+
+func TestNormalizeMessagesMergesText(t *testing.T) {
+	out := normalizeMessages([]Message{
+		{Role: "user", Content: "one"},
+		{Role: "user", Content: "two"},
+	})
+	if len(out) != 1 || len(out[0].Content) != 1 || out[0].Content[0].Text != "one\n\ntwo" {
+		t.Fatalf("unexpected merge %+v", out)
+	}
+}
+
+func TestAnthropicCacheBreakpoints(t *testing.T) {
+	var captured struct {
+		System   []requestBlock `json:"system"`
+		Tools    []toolSpec     `json:"tools"`
+		Messages []message      `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":5,"output_tokens":2,"cache_creation_input_tokens":0,"cache_read_input_tokens":1200}}`)
+	}))
+	defer server.Close()
+	client := &anthropicClient{model: "m", maxTokens: 10, baseURL: server.URL, httpClient: server.Client(), cache: CachePolicy{Enabled: true, TTL: "1h"}}
+
+	resp, err := client.Chat(context.Background(), Request{
+		System:  "be brief",
+		Summary: "earlier",
+		Tools:   []Tool{{Name: "a"}, {Name: "b"}},
+		Messages: []Message{
+			{Role: "user", Content: "one"},
+			{Role: "assistant", Content: "two"},
+			{Role: "user", Content: "three"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	if resp.Usage.CacheReadTokens != 1200 {
+		t.Fatalf("cache read tokens not tracked: %+v", resp.Usage)
+	}
+	if len(captured.System) != 2 || captured.System[0].CacheControl == nil || captured.System[1].CacheControl.TTL != "1h" {
+		t.Fatalf("unexpected system blocks %+v", captured.System)
+	}
+	if captured.Tools[0].CacheControl != nil || captured.Tools[1].CacheControl == nil {
+		t.Fatalf("expected breakpoint on last tool only: %+v", captured.Tools)
+	}
+	if captured.Messages[0].Content[0].CacheControl != nil || captured.Messages[2].Content[0].CacheControl == nil {
+		t.Fatalf("expected breakpoint on last message only: %+v", captured.Messages)
+	}
+}
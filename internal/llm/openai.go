@@ -0,0 +1,447 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"mouse/internal/logging"
+)
+
+const openAIURL = "https://api.openai.com/v1"
+
+type openAIClient struct {
+	apiKey       string
+	model        string
+	maxTokens    int
+	baseURL      string
+	httpClient   *http.Client
+	streamClient *http.Client
+	logger       *logging.Logger
+}
+
+func newOpenAI(cfg Config, logger *logging.Logger) (Client, error) {
+	if strings.TrimSpace(cfg.Model) == "" {
+		return &Noop{reason: "missing model"}, errors.New("llm: missing model")
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = openAIURL
+	}
+	if baseURL == openAIURL && strings.TrimSpace(cfg.APIKey) == "" {
+		return &Noop{reason: "missing api key"}, errors.New("llm: missing api key")
+	}
+	maxTokens := cfg.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = 1024
+	}
+	return &openAIClient{
+		apiKey:       cfg.APIKey,
+		model:        cfg.Model,
+		maxTokens:    maxTokens,
+		baseURL:      baseURL,
+		httpClient:   &http.Client{Timeout: 120 * time.Second},
+		streamClient: &http.Client{Timeout: 5 * time.Minute},
+		logger:       logger,
+	}, nil
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    any              `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIToolCall struct {
+	ID       string         `json:"id,omitempty"`
+	Type     string         `json:"type,omitempty"`
+	Function openAIFunction `json:"function"`
+}
+
+type openAIToolDelta struct {
+	Index    int            `json:"index"`
+	ID       string         `json:"id"`
+	Function openAIFunction `json:"function"`
+}
+
+type openAIFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+type openAITool struct {
+	Type     string            `json:"type"`
+	Function openAIToolFuncDef `json:"function"`
+}
+
+type openAIToolFuncDef struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type openAIRequest struct {
+	Model         string               `json:"model"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Messages      []openAIMessage      `json:"messages"`
+	Tools         []openAITool         `json:"tools,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+}
+
+func (u *openAIUsage) usage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	cached := u.PromptTokensDetails.CachedTokens
+	return Usage{
+		InputTokens:     u.PromptTokens - cached,
+		OutputTokens:    u.CompletionTokens,
+		CacheReadTokens: cached,
+	}
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content   *string          `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
+		} `json:"message"`
+		Delta struct {
+			Content   string            `json:"content"`
+			ToolCalls []openAIToolDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+func (c *openAIClient) Complete(ctx context.Context, prompt string) (string, error) {
+	resp, err := c.Chat(ctx, Request{
+		Messages: []Message{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+func (c *openAIClient) Chat(ctx context.Context, req Request) (Response, error) {
+	httpReq, err := c.newRequest(ctx, req, false)
+	if err != nil {
+		return Response{}, err
+	}
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("llm: post: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, fmt.Errorf("llm: read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Response{}, newAPIError(resp, respBody)
+	}
+	var parsed openAIResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return Response{}, fmt.Errorf("llm: decode response: %w", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return Response{}, errors.New("llm: empty response")
+	}
+	choice := parsed.Choices[0]
+	out := Response{StopReason: openAIStopReason(choice.FinishReason), Model: modelFor(req, c.model), Usage: parsed.Usage.usage()}
+	if choice.Message.Content != nil {
+		out.Text = strings.TrimSpace(*choice.Message.Content)
+	}
+	out.ToolCalls = openAIToolCalls(choice.Message.ToolCalls)
+	if out.Text == "" && len(out.ToolCalls) == 0 {
+		if c.logger != nil {
+			c.logger.Warn("llm response contained no text", map[string]string{
+				"stop_reason": out.StopReason,
+			})
+		}
+		return Response{}, errors.New("llm: empty response")
+	}
+	return out, nil
+}
+
+func (c *openAIClient) Stream(ctx context.Context, req Request, fn func(StreamEvent)) (Response, error) {
+	httpReq, err := c.newRequest(ctx, req, true)
+	if err != nil {
+		return Response{}, err
+	}
+	resp, err := c.streamClient.Do(httpReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("llm: post: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return Response{}, newAPIError(resp, body)
+	}
+	out, err := readOpenAIStream(ctx, resp.Body, fn)
+	out.Model = modelFor(req, c.model)
+	if err != nil {
+		return out, err
+	}
+	if out.Text == "" && len(out.ToolCalls) == 0 {
+		return out, errors.New("llm: empty response")
+	}
+	return out, nil
+}
+
+func (c *openAIClient) newRequest(ctx context.Context, req Request, stream bool) (*http.Request, error) {
+	messages := openAIMessages(req)
+	if len(messages) == 0 {
+		return nil, errors.New("llm: no messages")
+	}
+	payload := openAIRequest{
+		Model:     modelFor(req, c.model),
+		MaxTokens: c.maxTokens,
+		Messages:  messages,
+		Stream:    stream,
+	}
+	if stream {
+		payload.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	for _, tool := range req.Tools {
+		payload.Tools = append(payload.Tools, openAITool{
+			Type:     "function",
+			Function: openAIToolFuncDef{Name: tool.Name, Description: tool.Description, Parameters: toolInput(tool.InputSchema)},
+		})
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("llm: marshal: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("llm: request: %w", err)
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set("authorization", "Bearer "+c.apiKey)
+	}
+	httpReq.Header.Set("content-type", "application/json")
+	if stream {
+		httpReq.Header.Set("accept", "text/event-stream")
+	}
+	return httpReq, nil
+}
+
+func openAIMessages(req Request) []openAIMessage {
+	normalized := normalizeMessages(req.Messages)
+	if len(normalized) == 0 {
+		return nil
+	}
+	var out []openAIMessage
+	if system := systemPrompt(req); system != "" {
+		out = append(out, openAIMessage{Role: "system", Content: system})
+	}
+	for _, msg := range normalized {
+		var (
+			parts     []openAIPart
+			toolCalls []openAIToolCall
+		)
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "text":
+				parts = append(parts, openAIPart{Type: "text", Text: block.Text})
+			case "image":
+				parts = append(parts, openAIPart{Type: "image_url", ImageURL: &openAIImageURL{
+					URL: "data:" + block.Source.MediaType + ";base64," + block.Source.Data,
+				}})
+			case "document":
+				if block.Source.Type == "text" {
+					parts = append(parts, openAIPart{Type: "text", Text: fmt.Sprintf("Attached file %s:\n%s", block.Title, block.Source.Data)})
+					continue
+				}
+				parts = append(parts, openAIPart{Type: "text", Text: fmt.Sprintf("Attached file %s (%s) cannot be read by this model.", block.Title, block.Source.MediaType)})
+			case "tool_use":
+				toolCalls = append(toolCalls, openAIToolCall{
+					ID:       block.ID,
+					Type:     "function",
+					Function: openAIFunction{Name: block.Name, Arguments: string(block.Input)},
+				})
+			case "tool_result":
+				content := block.Content
+				if block.IsError {
+					content = "error: " + content
+				}
+				out = append(out, openAIMessage{Role: "tool", ToolCallID: block.ToolUseID, Content: content})
+			}
+		}
+		if len(parts) == 0 && len(toolCalls) == 0 {
+			continue
+		}
+		converted := openAIMessage{Role: msg.Role, ToolCalls: toolCalls}
+		converted.Content = openAIContent(parts)
+		out = append(out, converted)
+	}
+	return out
+}
+
+func openAIContent(parts []openAIPart) any {
+	if len(parts) == 0 {
+		return nil
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type != "text" {
+			return parts
+		}
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+func openAIToolCalls(calls []openAIToolCall) []ToolCall {
+	var out []ToolCall
+	for _, call := range calls {
+		args := strings.TrimSpace(call.Function.Arguments)
+		if args == "" {
+			args = "{}"
+		}
+		out = append(out, ToolCall{ID: call.ID, Name: call.Function.Name, Input: json.RawMessage(args)})
+	}
+	return out
+}
+
+func openAIStopReason(reason *string) string {
+	if reason == nil {
+		return ""
+	}
+	switch *reason {
+	case "stop":
+		return "end_turn"
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	}
+	return *reason
+}
+
+func readOpenAIStream(ctx context.Context, body io.Reader, fn func(StreamEvent)) (Response, error) {
+	emit := func(event StreamEvent) {
+		if fn != nil {
+			fn(event)
+		}
+	}
+	var (
+		out   Response
+		text  strings.Builder
+		calls = make(map[int]*openAIToolCall)
+		done  bool
+	)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	for !done && scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return out, err
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk openAIResponse
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			return out, fmt.Errorf("llm: decode stream event: %w", err)
+		}
+		if chunk.Error != nil {
+			return out, streamAPIError(chunk.Error.Type, chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			out.Usage = chunk.Usage.usage()
+		}
+		for _, choice := range chunk.Choices {
+			if delta := choice.Delta.Content; delta != "" {
+				text.WriteString(delta)
+				emit(StreamEvent{Type: EventText, Text: delta})
+			}
+			for _, call := range choice.Delta.ToolCalls {
+				existing, ok := calls[call.Index]
+				if !ok {
+					existing = &openAIToolCall{Type: "function"}
+					calls[call.Index] = existing
+				}
+				if call.ID != "" {
+					existing.ID = call.ID
+				}
+				if call.Function.Name != "" {
+					existing.Function.Name = call.Function.Name
+				}
+				existing.Function.Arguments += call.Function.Arguments
+			}
+			if choice.FinishReason != nil {
+				out.StopReason = openAIStopReason(choice.FinishReason)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return out, ctxErr
+		}
+		return out, fmt.Errorf("llm: read stream: %w", err)
+	}
+	if !done && out.StopReason == "" {
+		return out, errors.New("llm: stream ended before completion")
+	}
+	indexes := make([]int, 0, len(calls))
+	for index := range calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	ordered := make([]openAIToolCall, 0, len(indexes))
+	for _, index := range indexes {
+		ordered = append(ordered, *calls[index])
+	}
+	out.ToolCalls = openAIToolCalls(ordered)
+	for i := range out.ToolCalls {
+		emit(StreamEvent{Type: EventToolUse, ToolCall: &out.ToolCalls[i]})
+	}
+	out.Text = strings.TrimSpace(text.String())
+	emit(StreamEvent{Type: EventStop, StopReason: out.StopReason})
+	return out, nil
+}
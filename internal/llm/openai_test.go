@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIChatWithTools(t *testing.T) {
+	var captured openAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":null,"tool_calls":[{"id":"call_2","type":"function","function":{"name":"send_file","arguments":"{\"path\":\"a.txt\"}"}}]},"finish_reason":"tool_calls"}]}`)
+	}))
+	defer server.Close()
+
+	client, err := New(Config{Provider: "ollama", Model: "llama3", BaseURL: server.URL + "/v1/"}, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	resp, err := client.Chat(context.Background(), Request{
+		System:  "be brief",
+		Summary: "earlier",
+		Tools:   []Tool{{Name: "send_file", Description: "send"}},
+		Messages: []Message{
+			{Role: "user", Content: "send it"},
+			{Role: "assistant", Content: "sending", ToolCalls: []ToolCall{{ID: "call_1", Name: "send_file", Input: json.RawMessage(`{"path":"b.txt"}`)}}},
+			{Role: "user", ToolResults: []ToolResult{{ToolUseID: "call_1", Content: "missing", IsError: true}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	if resp.StopReason != "tool_use" || len(resp.ToolCalls) != 1 || string(resp.ToolCalls[0].Input) != `{"path":"a.txt"}` {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	roles := make([]string, 0, len(captured.Messages))
+	for _, msg := range captured.Messages {
+		roles = append(roles, msg.Role)
+	}
+	if strings.Join(roles, ",") != "system,user,assistant,tool" {
+		t.Fatalf("unexpected roles %v", roles)
+	}
+	if !strings.Contains(captured.Messages[0].Content.(string), "earlier") {
+		t.Fatalf("summary missing from system prompt: %+v", captured.Messages[0])
+	}
+	if captured.Messages[2].ToolCalls[0].Function.Arguments != `{"path":"b.txt"}` || captured.Messages[3].ToolCallID != "call_1" {
+		t.Fatalf("tool round trip not encoded: %+v", captured.Messages)
+	}
+	if len(captured.Tools) != 1 || string(captured.Tools[0].Function.Parameters) != "{}" {
+		t.Fatalf("unexpected tools %+v", captured.Tools)
+	}
+}
+
+func TestReadOpenAIStream(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"choices":[{"delta":{"content":"Hel"}}]}`,
+		`data: {"choices":[{"delta":{"content":"lo"}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"send_file","arguments":"{\"pa"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"th\":\"x\"}"}}]}}]}`,
+		`data: {"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+	var text strings.Builder
+	resp, err := readOpenAIStream(context.Background(), strings.NewReader(body), func(event StreamEvent) {
+		if event.Type == EventText {
+			text.WriteString(event.Text)
+		}
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if text.String() != "Hello" || resp.Text != "Hello" || resp.StopReason != "tool_use" {
+		t.Fatalf("unexpected stream result %+v (%q)", resp, text.String())
+	}
+	if len(resp.ToolCalls) != 1 || string(resp.ToolCalls[0].Input) != `{"path":"x"}` {
+		t.Fatalf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+}
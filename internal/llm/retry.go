@@ -0,0 +1,183 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"mouse/internal/logging"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultBaseDelay   = 500 * time.Millisecond
+	defaultMaxDelay    = 8 * time.Second
+)
+
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+type target struct {
+	provider string
+	model    string
+	client   Client
+	fallback bool
+}
+
+type resilientClient struct {
+	targets []target
+	policy  RetryPolicy
+	logger  *logging.Logger
+	sleep   func(ctx context.Context, d time.Duration) error
+	jitter  func(d time.Duration) time.Duration
+}
+
+func newResilient(targets []target, policy RetryPolicy, logger *logging.Logger) *resilientClient {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultMaxAttempts
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = defaultBaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = defaultMaxDelay
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+	return &resilientClient{
+		targets: targets,
+		policy:  policy,
+		logger:  logger,
+		sleep:   sleepContext,
+		jitter:  equalJitter,
+	}
+}
+
+func (t target) request(req Request) Request {
+	if t.fallback {
+		req.Model = ""
+	}
+	return req
+}
+
+func (c *resilientClient) Complete(ctx context.Context, prompt string) (string, error) {
+	resp, err := c.Chat(ctx, Request{
+		Messages: []Message{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+func (c *resilientClient) Chat(ctx context.Context, req Request) (Response, error) {
+	return c.do(ctx, func(t target) (Response, bool, error) {
+		resp, err := t.client.Chat(ctx, t.request(req))
+		return resp, false, err
+	})
+}
+
+func (c *resilientClient) Stream(ctx context.Context, req Request, fn func(StreamEvent)) (Response, error) {
+	return c.do(ctx, func(t target) (Response, bool, error) {
+		emitted := false
+		resp, err := Stream(ctx, t.client, t.request(req), func(event StreamEvent) {
+			if event.Type != EventStop {
+				emitted = true
+			}
+			if fn != nil {
+				fn(event)
+			}
+		})
+		return resp, emitted, err
+	})
+}
+
+func (c *resilientClient) do(ctx context.Context, call func(t target) (Response, bool, error)) (Response, error) {
+	var lastErr error
+	for _, t := range c.targets {
+		for attempt := 1; attempt <= c.policy.MaxAttempts; attempt++ {
+			resp, emitted, err := call(t)
+			if err == nil {
+				return resp, nil
+			}
+			lastErr = err
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return resp, err
+			}
+			if emitted {
+				c.logAttempt(t, attempt, err, "partial stream")
+				return resp, err
+			}
+			if !Retryable(err) {
+				c.logAttempt(t, attempt, err, "fatal")
+				break
+			}
+			if attempt == c.policy.MaxAttempts {
+				c.logAttempt(t, attempt, err, "exhausted")
+				break
+			}
+			wait := c.backoff(attempt)
+			if hint := retryAfter(err); hint > 0 {
+				if hint > c.policy.MaxDelay {
+					c.logAttempt(t, attempt, err, "retry-after "+hint.String()+" exceeds max delay")
+					break
+				}
+				wait = hint
+			}
+			c.logAttempt(t, attempt, err, "retry in "+wait.String())
+			if err := c.sleep(ctx, wait); err != nil {
+				return Response{}, err
+			}
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.New("llm: no providers configured")
+	}
+	return Response{}, lastErr
+}
+
+func (c *resilientClient) backoff(attempt int) time.Duration {
+	delay := c.policy.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > c.policy.MaxDelay {
+		delay = c.policy.MaxDelay
+	}
+	return c.jitter(delay)
+}
+
+func (c *resilientClient) logAttempt(t target, attempt int, err error, outcome string) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.Warn("llm attempt failed", map[string]string{
+		"provider": t.provider,
+		"model":    t.model,
+		"attempt":  strconv.Itoa(attempt),
+		"error":    err.Error(),
+		"outcome":  outcome,
+	})
+}
+
+func equalJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mouse/internal/config"
+)
+
+type stubClient struct {
+	errs  []error
+	calls int
+	text  string
+}
+
+func (s *stubClient) Complete(ctx context.Context, prompt string) (string, error) {
+	return "", errors.New("unused")
+}
+
+func (s *stubClient) Chat(ctx context.Context, req Request) (Response, error) {
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return Response{}, err
+	}
+	return Response{Text: s.text}, nil
+}
+
+func newTestResilient(targets []target, policy RetryPolicy) (*resilientClient, *[]time.Duration) {
+	var waits []time.Duration
+	client := newResilient(targets, policy, nil)
+	client.jitter = func(d time.Duration) time.Duration { return d }
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return client, &waits
+}
+
+func TestResilientRetriesWithBackoff(t *testing.T) {
+	primary := &stubClient{text: "ok", errs: []error{
+		&APIError{Status: 529, Message: "overloaded"},
+		&APIError{Status: http.StatusTooManyRequests, RetryAfter: 2 * time.Second},
+	}}
+	client, waits := newTestResilient([]target{{provider: "anthropic", model: "a", client: primary}}, RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second})
+	resp, err := client.Chat(context.Background(), Request{})
+	if err != nil || resp.Text != "ok" {
+		t.Fatalf("chat: %+v %v", resp, err)
+	}
+	if primary.calls != 3 || len(*waits) != 2 || (*waits)[0] != 100*time.Millisecond || (*waits)[1] != 2*time.Second {
+		t.Fatalf("unexpected attempts %d waits %v", primary.calls, *waits)
+	}
+}
+
+func TestResilientFallsBackOnFatalError(t *testing.T) {
+	primary := &stubClient{errs: []error{&APIError{Status: http.StatusBadRequest, Message: "bad model"}}}
+	fallback := &stubClient{text: "from fallback"}
+	client, waits := newTestResilient([]target{
+		{provider: "anthropic", model: "a", client: primary},
+		{provider: "openai", model: "b", client: fallback},
+	}, RetryPolicy{})
+	resp, err := client.Chat(context.Background(), Request{})
+	if err != nil || resp.Text != "from fallback" {
+		t.Fatalf("chat: %+v %v", resp, err)
+	}
+	if primary.calls != 1 || fallback.calls != 1 || len(*waits) != 0 {
+		t.Fatalf("unexpected calls %d/%d waits %v", primary.calls, fallback.calls, *waits)
+	}
+}
+
+func TestResilientStopsOnCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	primary := &stubClient{errs: []error{fmt.Errorf("llm: post: %w", context.Canceled)}}
+	fallback := &stubClient{text: "unused"}
+	client, _ := newTestResilient([]target{{client: primary}, {client: fallback}}, RetryPolicy{})
+	if _, err := client.Chat(ctx, Request{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+	if fallback.calls != 0 {
+		t.Fatalf("fallback should not run after cancellation")
+	}
+}
+
+func TestAnthropicErrorCarriesRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("retry-after", "7")
+		w.WriteHeader(529)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error"}}`)
+	}))
+	defer server.Close()
+
+	client := &anthropicClient{model: "m", maxTokens: 10, baseURL: server.URL, httpClient: server.Client()}
+	_, err := client.Chat(context.Background(), Request{Messages: []Message{{Role: "user", Content: "hi"}}})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != 529 || apiErr.RetryAfter != 7*time.Second {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	if !Retryable(err) {
+		t.Fatalf("529 should be retryable")
+	}
+}
+
+func TestFromConfigInheritsFallbackFields(t *testing.T) {
+	cfg := FromConfig(configLLM())
+	if len(cfg.Fallbacks) != 2 {
+		t.Fatalf("expected 2 fallbacks, got %+v", cfg.Fallbacks)
+	}
+	same, other := cfg.Fallbacks[0], cfg.Fallbacks[1]
+	if same.Provider != "anthropic" || same.APIKey != "key" || same.MaxTokens != 512 || same.Model != "small" {
+		t.Fatalf("unexpected same-provider fallback %+v", same)
+	}
+	if other.APIKey != "" || other.BaseURL != "http://local/v1" || other.Model != "big" {
+		t.Fatalf("unexpected cross-provider fallback %+v", other)
+	}
+}
+
+func configLLM() config.LLMConfig {
+	return config.LLMConfig{
+		Provider:  "anthropic",
+		APIKey:    "key",
+		Model:     "big",
+		MaxTokens: 512,
+		Fallback: []config.LLMFallback{
+			{Model: "small"},
+			{Provider: "ollama", BaseURL: "http://local/v1"},
+		},
+	}
+}
+
+func TestResilientGivesUpOnLongRetryAfter(t *testing.T) {
+	primary := &stubClient{text: "ok", errs: []error{&APIError{Status: http.StatusTooManyRequests, RetryAfter: time.Hour}}}
+	client, waits := newTestResilient([]target{{provider: "anthropic", model: "a", client: primary}}, RetryPolicy{MaxAttempts: 3, MaxDelay: 5 * time.Second})
+	_, err := client.Chat(context.Background(), Request{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusTooManyRequests {
+		t.Fatalf("expected the rate limit error, got %v", err)
+	}
+	if primary.calls != 1 || len(*waits) != 0 {
+		t.Fatalf("expected no sleep past max delay, got %d calls waits %v", primary.calls, *waits)
+	}
+}
@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+type Route struct {
+	Model       string
+	Job         string
+	Chat        string
+	MinChars    int
+	MaxChars    int
+	Tools       *bool
+	Attachments *bool
+}
+
+type routingClient struct {
+	client Client
+	routes []Route
+}
+
+func WithRoutes(client Client, routes []Route) Client {
+	if client == nil || len(routes) == 0 {
+		return client
+	}
+	return &routingClient{client: client, routes: routes}
+}
+
+func PickModel(routes []Route, tags Tags, req Request) string {
+	text, attachments := lastUserTurn(req.Messages)
+	chars := utf8.RuneCountInString(text)
+	tools := len(req.Tools) > 0
+	for _, route := range routes {
+		if route.Job != "" && route.Job != tags.Job {
+			continue
+		}
+		if route.Chat != "" && route.Chat != tags.Session {
+			continue
+		}
+		if route.MinChars > 0 && chars < route.MinChars {
+			continue
+		}
+		if route.MaxChars > 0 && chars > route.MaxChars {
+			continue
+		}
+		if route.Tools != nil && *route.Tools != tools {
+			continue
+		}
+		if route.Attachments != nil && *route.Attachments != attachments {
+			continue
+		}
+		return route.Model
+	}
+	return ""
+}
+
+func lastUserTurn(messages []Message) (string, bool) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg.Role != "user" || (strings.TrimSpace(msg.Content) == "" && len(msg.Attachments) == 0) {
+			continue
+		}
+		return strings.TrimSpace(msg.Content), len(msg.Attachments) > 0
+	}
+	return "", false
+}
+
+func (c *routingClient) route(ctx context.Context, req Request) Request {
+	if strings.TrimSpace(req.Model) == "" {
+		req.Model = PickModel(c.routes, TagsFrom(ctx), req)
+	}
+	return req
+}
+
+func (c *routingClient) Complete(ctx context.Context, prompt string) (string, error) {
+	resp, err := c.Chat(ctx, Request{
+		Messages: []Message{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+func (c *routingClient) Chat(ctx context.Context, req Request) (Response, error) {
+	return c.client.Chat(ctx, c.route(ctx, req))
+}
+
+func (c *routingClient) Stream(ctx context.Context, req Request, fn func(StreamEvent)) (Response, error) {
+	return Stream(ctx, c.client, c.route(ctx, req), fn)
+}
@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+	"testing"
+)
+
+type modelEcho struct {
+	models []string
+}
+
+func (m *modelEcho) Complete(ctx context.Context, prompt string) (string, error) {
+	return "", nil
+}
+
+func (m *modelEcho) Chat(ctx context.Context, req Request) (Response, error) {
+	m.models = append(m.models, req.Model)
+	return Response{Text: "ok", Model: req.Model}, nil
+}
+
+func TestPickModel(t *testing.T) {
+	yes := true
+	routes := []Route{
+		{Model: "job-model", Job: "daily"},
+		{Model: "tool-model", Tools: &yes},
+		{Model: "vision-model", Attachments: &yes},
+		{Model: "small-model", MaxChars: 20},
+	}
+	cases := []struct {
+		name string
+		tags Tags
+		req  Request
+		want string
+	}{
+		{"job", Tags{Job: "daily"}, Request{Messages: []Message{{Role: "user", Content: "a long prompt that would otherwise be big"}}}, "job-model"},
+		{"short", Tags{}, Request{Messages: []Message{{Role: "user", Content: "hi"}}}, "small-model"},
+		{"long", Tags{}, Request{Messages: []Message{{Role: "user", Content: "please write a long design document"}}}, ""},
+		{"attachment", Tags{}, Request{Messages: []Message{{Role: "user", Content: "see", Attachments: []Attachment{{Name: "a.png"}}}}}, "vision-model"},
+		{"tools offered", Tags{}, Request{Tools: []Tool{{Name: "send_file"}}, Messages: []Message{{Role: "user", Content: "hi"}}}, "tool-model"},
+		{"tool follow-up", Tags{}, Request{Tools: []Tool{{Name: "send_file"}}, Messages: []Message{
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "1"}}},
+			{Role: "user", ToolResults: []ToolResult{{ToolUseID: "1"}}},
+		}}, "tool-model"},
+		{"no tools offered", Tags{}, Request{Messages: []Message{
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "1"}}},
+			{Role: "user", ToolResults: []ToolResult{{ToolUseID: "1"}}},
+		}}, "small-model"},
+	}
+	for _, tc := range cases {
+		if got := PickModel(routes, tc.tags, tc.req); got != tc.want {
+			t.Fatalf("%s: got %q want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRoutingRespectsExplicitModelAndFallbacks(t *testing.T) {
+	primary := &modelEcho{}
+	client := WithRoutes(primary, []Route{{Model: "small", MaxChars: 10}})
+	ctx := context.Background()
+	if _, err := client.Chat(ctx, Request{Messages: []Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	if _, err := client.Chat(ctx, Request{Model: "chosen", Messages: []Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	if len(primary.models) != 2 || primary.models[0] != "small" || primary.models[1] != "chosen" {
+		t.Fatalf("unexpected models %v", primary.models)
+	}
+
+	failing := &stubClient{errs: []error{&APIError{Status: 400}}}
+	fallback := &modelEcho{}
+	resilient, _ := newTestResilient([]target{{client: failing}, {client: fallback, fallback: true}}, RetryPolicy{})
+	if _, err := resilient.Chat(ctx, Request{Model: "chosen"}); err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	if len(fallback.models) != 1 || fallback.models[0] != "" {
+		t.Fatalf("fallback should use its own model, got %v", fallback.models)
+	}
+}
@@ -0,0 +1,231 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	EventText    = "text"
+	EventToolUse = "tool_use"
+	EventStop    = "stop"
+)
+
+type StreamEvent struct {
+	Type       string
+	Text       string
+	ToolCall   *ToolCall
+	StopReason string
+}
+
+type Streamer interface {
+	Stream(ctx context.Context, req Request, fn func(StreamEvent)) (Response, error)
+}
+
+func Stream(ctx context.Context, client Client, req Request, fn func(StreamEvent)) (Response, error) {
+	if streamer, ok := client.(Streamer); ok {
+		return streamer.Stream(ctx, req, fn)
+	}
+	resp, err := client.Chat(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if fn != nil {
+		if resp.Text != "" {
+			fn(StreamEvent{Type: EventText, Text: resp.Text})
+		}
+		for i := range resp.ToolCalls {
+			fn(StreamEvent{Type: EventToolUse, ToolCall: &resp.ToolCalls[i]})
+		}
+		fn(StreamEvent{Type: EventStop, StopReason: resp.StopReason})
+	}
+	return resp, nil
+}
+
+type streamEnvelope struct {
+	Type         string       `json:"type"`
+	Index        int          `json:"index"`
+	ContentBlock contentBlock `json:"content_block"`
+	Delta        streamDelta  `json:"delta"`
+	Error        *streamError `json:"error"`
+	Message      struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Usage *anthropicUsage `json:"usage"`
+}
+
+type streamDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	PartialJSON string `json:"partial_json"`
+	StopReason  string `json:"stop_reason"`
+}
+
+type streamError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type streamBlock struct {
+	kind  string
+	text  strings.Builder
+	id    string
+	name  string
+	input strings.Builder
+}
+
+func (c *anthropicClient) Stream(ctx context.Context, req Request, fn func(StreamEvent)) (Response, error) {
+	httpReq, err := c.newRequest(ctx, req, true)
+	if err != nil {
+		return Response{}, err
+	}
+	resp, err := c.streamClient.Do(httpReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("llm: post: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return Response{}, newAPIError(resp, body)
+	}
+	out, err := readStream(ctx, resp.Body, fn)
+	out.Model = modelFor(req, c.model)
+	if err != nil {
+		return out, err
+	}
+	if out.Text == "" && len(out.ToolCalls) == 0 {
+		if c.logger != nil {
+			c.logger.Warn("llm stream contained no text", map[string]string{
+				"stop_reason": out.StopReason,
+			})
+		}
+		return out, errors.New("llm: empty response")
+	}
+	return out, nil
+}
+
+func readStream(ctx context.Context, body io.Reader, fn func(StreamEvent)) (Response, error) {
+	emit := func(event StreamEvent) {
+		if fn != nil {
+			fn(event)
+		}
+	}
+	var (
+		out    Response
+		texts  []string
+		blocks = make(map[int]*streamBlock)
+		data   strings.Builder
+	)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	dispatch := func() (bool, error) {
+		payload := strings.TrimSpace(data.String())
+		data.Reset()
+		if payload == "" {
+			return false, nil
+		}
+		var env streamEnvelope
+		if err := json.Unmarshal([]byte(payload), &env); err != nil {
+			return false, fmt.Errorf("llm: decode stream event: %w", err)
+		}
+		switch env.Type {
+		case "message_start":
+			out.Usage = env.Message.Usage.usage()
+		case "content_block_start":
+			block := &streamBlock{kind: env.ContentBlock.Type, id: env.ContentBlock.ID, name: env.ContentBlock.Name}
+			block.text.WriteString(env.ContentBlock.Text)
+			blocks[env.Index] = block
+		case "content_block_delta":
+			block, ok := blocks[env.Index]
+			if !ok {
+				block = &streamBlock{kind: "text"}
+				blocks[env.Index] = block
+			}
+			switch env.Delta.Type {
+			case "text_delta":
+				block.text.WriteString(env.Delta.Text)
+				emit(StreamEvent{Type: EventText, Text: env.Delta.Text})
+			case "input_json_delta":
+				block.input.WriteString(env.Delta.PartialJSON)
+			}
+		case "content_block_stop":
+			block, ok := blocks[env.Index]
+			if !ok {
+				break
+			}
+			delete(blocks, env.Index)
+			switch block.kind {
+			case "text":
+				if text := block.text.String(); strings.TrimSpace(text) != "" {
+					texts = append(texts, text)
+				}
+			case "tool_use":
+				input := json.RawMessage(block.input.String())
+				if len(input) == 0 {
+					input = json.RawMessage(`{}`)
+				}
+				call := ToolCall{ID: block.id, Name: block.name, Input: input}
+				out.ToolCalls = append(out.ToolCalls, call)
+				emit(StreamEvent{Type: EventToolUse, ToolCall: &call})
+			}
+		case "message_delta":
+			if env.Delta.StopReason != "" {
+				out.StopReason = env.Delta.StopReason
+			}
+			if env.Usage != nil && env.Usage.OutputTokens > 0 {
+				out.Usage.OutputTokens = env.Usage.OutputTokens
+			}
+		case "message_stop":
+			return true, nil
+		case "error":
+			if env.Error != nil {
+				return false, streamAPIError(env.Error.Type, env.Error.Message)
+			}
+			return false, errors.New("llm: stream error")
+		}
+		return false, nil
+	}
+
+	done := false
+	for !done && scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return out, err
+		}
+		line := scanner.Text()
+		switch {
+		case line == "":
+			var err error
+			if done, err = dispatch(); err != nil {
+				return out, err
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return out, ctxErr
+		}
+		return out, fmt.Errorf("llm: read stream: %w", err)
+	}
+	if !done {
+		stopped, err := dispatch()
+		if err != nil {
+			return out, err
+		}
+		if !stopped {
+			return out, errors.New("llm: stream ended before message_stop")
+		}
+	}
+	out.Text = strings.Join(texts, "\n\n")
+	emit(StreamEvent{Type: EventStop, StopReason: out.StopReason})
+	return out, nil
+}
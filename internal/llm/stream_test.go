@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","role":"assistant","content":[],"usage":{"input_tokens":42,"cache_read_input_tokens":7,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"send_file","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\": \"rep"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"ort.pdf\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":18}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestAnthropicStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/event-stream")
+		fmt.Fprint(w, sampleStream)
+	}))
+	defer server.Close()
+	client := &anthropicClient{apiKey: "k", model: "m", maxTokens: 10, baseURL: server.URL, version: anthropicVersion, streamClient: server.Client()}
+
+	var deltas []string
+	var tools []string
+	resp, err := client.Stream(context.Background(), Request{Messages: []Message{{Role: "user", Content: "hi"}}}, func(event StreamEvent) {
+		switch event.Type {
+		case EventText:
+			deltas = append(deltas, event.Text)
+		case EventToolUse:
+			tools = append(tools, event.ToolCall.Name)
+		}
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if resp.Text != "Hello world" || resp.StopReason != "tool_use" || resp.Model != "m" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if resp.Usage != (Usage{InputTokens: 42, OutputTokens: 18, CacheReadTokens: 7}) {
+		t.Fatalf("unexpected usage %+v", resp.Usage)
+	}
+	if strings.Join(deltas, "|") != "Hello| world" || len(tools) != 1 {
+		t.Fatalf("unexpected events %v %v", deltas, tools)
+	}
+	if len(resp.ToolCalls) != 1 || string(resp.ToolCalls[0].Input) != `{"path": "report.pdf"}` {
+		t.Fatalf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+}
+
+func TestReadStreamErrorEvent(t *testing.T) {
+	body := "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"
+	_, err := readStream(context.Background(), strings.NewReader(body), nil)
+	if err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Fatalf("expected overloaded error, got %v", err)
+	}
+	_, err = readStream(context.Background(), strings.NewReader("data: {\"type\":\"ping\"}\n\n"), nil)
+	if err == nil {
+		t.Fatalf("expected error for truncated stream")
+	}
+}
+
+func TestStreamHonoursCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"partial\"}}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	client := &anthropicClient{apiKey: "k", model: "m", maxTokens: 10, baseURL: server.URL, version: anthropicVersion, streamClient: server.Client()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := client.Stream(ctx, Request{Messages: []Message{{Role: "user", Content: "hi"}}}, func(event StreamEvent) {
+		if event.Type == EventText {
+			cancel()
+		}
+	})
+	if err == nil {
+		t.Fatalf("expected cancellation error")
+	}
+}
@@ -0,0 +1,120 @@
+package llm
+
+import "context"
+
+type Usage struct {
+	InputTokens         int
+	OutputTokens        int
+	CacheCreationTokens int
+	CacheReadTokens     int
+}
+
+func (u Usage) Total() int {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationTokens + u.CacheReadTokens
+}
+
+type Tags struct {
+	Session string
+	User    string
+	Job     string
+}
+
+type tagsKey struct{}
+
+func WithTags(ctx context.Context, tags Tags) context.Context {
+	merged := TagsFrom(ctx)
+	if tags.Session != "" {
+		merged.Session = tags.Session
+	}
+	if tags.User != "" {
+		merged.User = tags.User
+	}
+	if tags.Job != "" {
+		merged.Job = tags.Job
+	}
+	return context.WithValue(ctx, tagsKey{}, merged)
+}
+
+func TagsFrom(ctx context.Context) Tags {
+	tags, _ := ctx.Value(tagsKey{}).(Tags)
+	return tags
+}
+
+type UsageRecorder interface {
+	RecordUsage(ctx context.Context, model string, usage Usage)
+}
+
+type recordingClient struct {
+	client   Client
+	recorder UsageRecorder
+}
+
+func WithRecorder(client Client, recorder UsageRecorder) Client {
+	if client == nil || recorder == nil {
+		return client
+	}
+	return &recordingClient{client: client, recorder: recorder}
+}
+
+func (c *recordingClient) Complete(ctx context.Context, prompt string) (string, error) {
+	resp, err := c.Chat(ctx, Request{
+		Messages: []Message{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+func (c *recordingClient) Chat(ctx context.Context, req Request) (Response, error) {
+	resp, err := c.client.Chat(ctx, req)
+	if err == nil {
+		c.recorder.RecordUsage(ctx, resp.Model, resp.Usage)
+	}
+	return resp, err
+}
+
+func (c *recordingClient) Stream(ctx context.Context, req Request, fn func(StreamEvent)) (Response, error) {
+	resp, err := Stream(ctx, c.client, req, fn)
+	if err == nil {
+		c.recorder.RecordUsage(ctx, resp.Model, resp.Usage)
+	}
+	return resp, err
+}
+
+type Guard interface {
+	Allow(ctx context.Context) error
+}
+
+type guardedClient struct {
+	client Client
+	guard  Guard
+}
+
+func WithGuard(client Client, guard Guard) Client {
+	if client == nil || guard == nil {
+		return client
+	}
+	return &guardedClient{client: client, guard: guard}
+}
+
+func (c *guardedClient) Complete(ctx context.Context, prompt string) (string, error) {
+	if err := c.guard.Allow(ctx); err != nil {
+		return "", err
+	}
+	return c.client.Complete(ctx, prompt)
+}
+
+func (c *guardedClient) Chat(ctx context.Context, req Request) (Response, error) {
+	if err := c.guard.Allow(ctx); err != nil {
+		return Response{}, err
+	}
+	return c.client.Chat(ctx, req)
+}
+
+func (c *guardedClient) Stream(ctx context.Context, req Request, fn func(StreamEvent)) (Response, error) {
+	if err := c.guard.Allow(ctx); err != nil {
+		return Response{}, err
+	}
+	return Stream(ctx, c.client, req, fn)
+}
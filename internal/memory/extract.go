@@ -0,0 +1,386 @@
+package memory
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mouse/internal/config"
+	"mouse/internal/llm"
+	"mouse/internal/logging"
+	"mouse/internal/sessions"
+	"mouse/internal/sqlite"
+)
+
+const (
+	sourcePrefix       = "Source: "
+	transcriptLimit    = 200
+	duplicateThreshold = 0.8
+	maxFactKeyLength   = maxKeyLength - 8
+)
+
+type Extractor struct {
+	store    *Store
+	db       *sqlite.DB
+	llm      llm.Client
+	sessions *sessions.Store
+	logger   *logging.Logger
+	idle     time.Duration
+	maxFacts int
+	interval time.Duration
+	started  atomic.Bool
+	running  sync.WaitGroup
+	mu       sync.Mutex
+	pending  map[string]time.Time
+}
+
+type Fact struct {
+	Key  string `json:"key"`
+	Fact string `json:"fact"`
+}
+
+func NewExtractor(cfg config.MemoryExtractConfig, store *Store, db *sqlite.DB, client llm.Client, sessionStore *sessions.Store, logger *logging.Logger) (*Extractor, error) {
+	if !cfg.Enabled {
+		return nil, errors.New("memory: extraction disabled")
+	}
+	if store == nil {
+		return nil, errors.New("memory: store is required")
+	}
+	if db == nil {
+		return nil, errors.New("memory: db is required")
+	}
+	if client == nil {
+		return nil, errors.New("memory: llm is required")
+	}
+	idle := time.Duration(cfg.IdleMinutes) * time.Minute
+	if idle <= 0 {
+		idle = 30 * time.Minute
+	}
+	maxFacts := cfg.MaxFacts
+	if maxFacts <= 0 {
+		maxFacts = 10
+	}
+	return &Extractor{
+		store:    store,
+		db:       db,
+		llm:      client,
+		sessions: sessionStore,
+		logger:   logger,
+		idle:     idle,
+		maxFacts: maxFacts,
+		interval: time.Minute,
+		pending:  make(map[string]time.Time),
+	}, nil
+}
+
+func (e *Extractor) Touch(sessionID string) {
+	if e == nil || strings.TrimSpace(sessionID) == "" {
+		return
+	}
+	now := time.Now()
+	e.mu.Lock()
+	e.pending[sessionID] = now
+	e.mu.Unlock()
+	if err := e.db.MarkMemoryExtractPending(context.Background(), sessionID, now); err != nil && e.logger != nil {
+		e.logger.Warn("memory extraction pending not saved", map[string]string{
+			"session_id": sessionID,
+			"error":      err.Error(),
+		})
+	}
+}
+
+func (e *Extractor) Start(ctx context.Context) {
+	if e == nil {
+		return
+	}
+	if !e.started.CompareAndSwap(false, true) {
+		return
+	}
+	e.restorePending(ctx)
+	e.running.Add(1)
+	go func() {
+		defer e.running.Done()
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.runIdle(ctx)
+			}
+		}
+	}()
+}
+
+func (e *Extractor) Wait() {
+	if e == nil {
+		return
+	}
+	e.running.Wait()
+}
+
+func (e *Extractor) restorePending(ctx context.Context) {
+	pending, err := e.db.ListMemoryExtractPending(ctx)
+	if err != nil {
+		if e.logger != nil {
+			e.logger.Warn("memory extraction pending not restored", map[string]string{
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for sessionID, since := range pending {
+		if current, ok := e.pending[sessionID]; !ok || since.After(current) {
+			e.pending[sessionID] = since
+		}
+	}
+}
+
+func (e *Extractor) runIdle(ctx context.Context) {
+	for _, sessionID := range e.idleSessions(time.Now()) {
+		written, err := e.Extract(ctx, sessionID)
+		e.clearPending(ctx, sessionID)
+		if e.logger == nil {
+			continue
+		}
+		if err != nil {
+			e.logger.Warn("memory extraction failed", map[string]string{
+				"session_id": sessionID,
+				"error":      err.Error(),
+			})
+			continue
+		}
+		e.logger.Info("memory extraction complete", map[string]string{
+			"session_id": sessionID,
+			"written":    strconv.Itoa(written),
+		})
+	}
+}
+
+func (e *Extractor) clearPending(ctx context.Context, sessionID string) {
+	e.mu.Lock()
+	_, touched := e.pending[sessionID]
+	e.mu.Unlock()
+	if touched {
+		return
+	}
+	if err := e.db.ClearMemoryExtractPending(ctx, sessionID); err != nil && e.logger != nil {
+		e.logger.Warn("memory extraction pending not cleared", map[string]string{
+			"session_id": sessionID,
+			"error":      err.Error(),
+		})
+	}
+}
+
+func (e *Extractor) idleSessions(now time.Time) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var ready []string
+	for sessionID, last := range e.pending {
+		if now.Sub(last) < e.idle {
+			continue
+		}
+		ready = append(ready, sessionID)
+		delete(e.pending, sessionID)
+	}
+	return ready
+}
+
+func (e *Extractor) Extract(ctx context.Context, sessionID string) (int, error) {
+	messages, err := e.db.ListSessionMessages(ctx, sessionID, transcriptLimit)
+	if err != nil {
+		return 0, err
+	}
+	after, err := e.db.MemoryExtractWatermark(ctx, sessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	var transcript strings.Builder
+	var newest int64
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg.ID > newest {
+			newest = msg.ID
+		}
+		if msg.ID <= after {
+			continue
+		}
+		fmt.Fprintf(&transcript, "[%s] %s: %s\n", msg.CreatedAt, msg.Role, strings.TrimSpace(msg.Content))
+	}
+	if transcript.Len() == 0 {
+		return 0, nil
+	}
+
+	existing, err := e.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	response, err := e.llm.Complete(llm.WithTags(ctx, llm.Tags{Session: sessionID}), e.prompt(transcript.String(), existing))
+	if err != nil {
+		return 0, fmt.Errorf("memory: extract: %w", err)
+	}
+	facts, err := parseFacts(response)
+	if err != nil {
+		return 0, err
+	}
+	if len(facts) > e.maxFacts {
+		facts = facts[:e.maxFacts]
+	}
+
+	written := 0
+	for _, fact := range facts {
+		text := strings.TrimSpace(fact.Fact)
+		if text == "" || isDuplicate(text, existing) {
+			continue
+		}
+		key := uniqueKey(factKey(fact), existing)
+		entry, err := e.store.Set(ctx, key, text+"\n\n"+provenance(e.store.dir, e.sessions, sessionID))
+		if err != nil {
+			if e.logger != nil {
+				e.logger.Warn("extracted fact skipped", map[string]string{
+					"session_id": sessionID,
+					"key":        key,
+					"error":      err.Error(),
+				})
+			}
+			continue
+		}
+		existing = append(existing, entry)
+		written++
+	}
+
+	if err := e.db.SetMemoryExtractWatermark(ctx, sessionID, newest); err != nil {
+		return written, err
+	}
+	return written, nil
+}
+
+func (e *Extractor) prompt(transcript string, existing []Entry) string {
+	var b strings.Builder
+	b.WriteString("You maintain a long-term memory for a team assistant. ")
+	b.WriteString("Extract durable facts from the conversation below: stable preferences, environment details, decisions, names and roles. ")
+	b.WriteString("Ignore small talk, one-off requests and anything already known.\n\n")
+	fmt.Fprintf(&b, "Respond with only a JSON array of at most %d objects of the form {\"key\": \"short-kebab-case-key\", \"fact\": \"one sentence\"}. ", e.maxFacts)
+	b.WriteString("Respond with [] when there is nothing worth remembering.\n\n")
+	if len(existing) > 0 {
+		b.WriteString("Already known:\n")
+		for _, entry := range existing {
+			fmt.Fprintf(&b, "- %s: %s\n", entry.Key, factText(entry.Content))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("Conversation:\n")
+	b.WriteString(transcript)
+	return b.String()
+}
+
+func provenance(dir string, sessionStore *sessions.Store, sessionID string) string {
+	stamp := time.Now().UTC().Format(time.RFC3339)
+	if sessionStore == nil {
+		return fmt.Sprintf("%ssession %s (%s)", sourcePrefix, sessionID, stamp)
+	}
+	target := sessionStore.Path(sessionID)
+	if rel, err := filepath.Rel(dir, target); err == nil {
+		target = rel
+	}
+	return fmt.Sprintf("%s[session %s](%s) (%s)", sourcePrefix, sessionID, filepath.ToSlash(target), stamp)
+}
+
+func parseFacts(response string) ([]Fact, error) {
+	start := strings.Index(response, "[")
+	end := strings.LastIndex(response, "]")
+	if start < 0 || end < start {
+		return nil, errors.New("memory: extraction response is not a json array")
+	}
+	var facts []Fact
+	if err := json.Unmarshal([]byte(response[start:end+1]), &facts); err != nil {
+		return nil, fmt.Errorf("memory: decode extracted facts: %w", err)
+	}
+	return facts, nil
+}
+
+func factText(content string) string {
+	lines := strings.Split(content, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if strings.HasPrefix(line, sourcePrefix) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+func isDuplicate(fact string, existing []Entry) bool {
+	tokens := words(fact)
+	for _, entry := range existing {
+		if overlap(tokens, words(factText(entry.Content))) >= duplicateThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+func words(text string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9')
+	}) {
+		set[field] = struct{}{}
+	}
+	return set
+}
+
+func overlap(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for token := range a {
+		if _, ok := b[token]; ok {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	return float64(shared) / float64(union)
+}
+
+func factKey(fact Fact) string {
+	key := fileName(strings.TrimSpace(fact.Key))
+	if key == "memory" {
+		sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(fact.Fact))))
+		return "fact-" + hex.EncodeToString(sum[:4])
+	}
+	if len(key) > maxFactKeyLength {
+		sum := sha256.Sum256([]byte(key))
+		key = strings.TrimRight(key[:maxFactKeyLength-9], "-_.") + "-" + hex.EncodeToString(sum[:4])
+	}
+	return key
+}
+
+func uniqueKey(key string, existing []Entry) string {
+	taken := make(map[string]struct{}, len(existing))
+	for _, entry := range existing {
+		taken[fileName(entry.Key)] = struct{}{}
+	}
+	candidate := key
+	for n := 2; ; n++ {
+		if _, ok := taken[candidate]; !ok {
+			return candidate
+		}
+		candidate = key + "-" + strconv.Itoa(n)
+	}
+}
@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mouse/internal/config"
+	"mouse/internal/llm"
+	"mouse/internal/sessions"
+	"mouse/internal/sqlite"
+)
+
+type stubLLM struct {
+	response string
+	prompts  []string
+}
+
+func (s *stubLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	return s.response, nil
+}
+
+func (s *stubLLM) Chat(ctx context.Context, req llm.Request) (llm.Response, error) {
+	return llm.Response{Text: s.response}, nil
+}
+
+func TestExtractWritesFactsWithProvenance(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, err := New(config.MemoryConfig{Store: "markdown", Dir: filepath.Join(dir, "memory"), AutoSync: true}, db, nil)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	sessionStore, _ := sessions.NewStore(filepath.Join(dir, "sessions"))
+	client := &stubLLM{response: "```json\n[{\"key\":\"staging-cluster\",\"fact\":\"Our staging cluster is eu-west-2.\"},{\"key\":\"dup\",\"fact\":\"Sam prefers terse answers\"}]\n```"}
+	extractor, err := NewExtractor(config.MemoryExtractConfig{Enabled: true}, store, db, client, sessionStore, nil)
+	if err != nil {
+		t.Fatalf("new extractor: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := store.Set(ctx, "sam", "Sam prefers terse answers."); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if _, err := db.AppendSessionMessage(ctx, "42", "user", "staging is eu-west-2 btw"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	written, err := extractor.Extract(ctx, "42")
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if written != 1 {
+		t.Fatalf("expected 1 new fact, got %d", written)
+	}
+	entry, _ := store.Get(ctx, "staging-cluster")
+	if entry == nil {
+		t.Fatalf("expected staging-cluster entry")
+	}
+	if !strings.Contains(entry.Content, "(../sessions/42.md)") {
+		t.Fatalf("expected provenance link, got %q", entry.Content)
+	}
+
+	written, err = extractor.Extract(ctx, "42")
+	if err != nil || written != 0 || len(client.prompts) != 1 {
+		t.Fatalf("expected no re-extraction of seen messages, got %d (%v)", written, err)
+	}
+}
+
+func TestExtractFitsLongKeysAndPersistsProgress(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, err := New(config.MemoryConfig{Store: "markdown", Dir: filepath.Join(dir, "memory"), AutoSync: true}, db, nil)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	longKey := strings.Repeat("deploy-", 40)
+	client := &stubLLM{response: `[{"key":"` + longKey + `","fact":"Deploys go out on Tuesdays."},{"key":"oncall","fact":"Priya is on call this week."}]`}
+	extractor, err := NewExtractor(config.MemoryExtractConfig{Enabled: true}, store, db, client, nil, nil)
+	if err != nil {
+		t.Fatalf("new extractor: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := db.AppendSessionMessage(ctx, "42", "user", "we deploy tuesdays, priya has the pager"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	extractor.Touch("42")
+
+	written, err := extractor.Extract(ctx, "42")
+	if err != nil || written != 2 {
+		t.Fatalf("expected both facts written, got %d (%v)", written, err)
+	}
+	entries, _ := store.List(ctx)
+	for _, entry := range entries {
+		if len(entry.Key) > maxKeyLength {
+			t.Fatalf("key too long: %d", len(entry.Key))
+		}
+	}
+
+	restarted, _ := NewExtractor(config.MemoryExtractConfig{Enabled: true}, store, db, client, nil, nil)
+	if written, err := restarted.Extract(ctx, "42"); err != nil || written != 0 || len(client.prompts) != 1 {
+		t.Fatalf("expected watermark to survive restart, got %d (%v)", written, err)
+	}
+	restarted.restorePending(ctx)
+	if ready := restarted.idleSessions(time.Now().Add(time.Hour)); len(ready) != 1 || ready[0] != "42" {
+		t.Fatalf("expected pending session restored, got %v", ready)
+	}
+}
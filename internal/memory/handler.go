@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"mouse/internal/logging"
+)
+
+type Handler struct {
+	store  *Store
+	logger *logging.Logger
+}
+
+type setRequest struct {
+	Key     string `json:"key"`
+	Content string `json:"content"`
+}
+
+type deleteRequest struct {
+	Key string `json:"key"`
+}
+
+type listResponse struct {
+	Entries []Entry `json:"entries"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewHandler(store *Store, logger *logging.Logger) *Handler {
+	return &Handler{store: store, logger: logger}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		writeError(w, http.StatusServiceUnavailable, "memory store not configured")
+		return
+	}
+	switch strings.TrimPrefix(r.URL.Path, "/memory/") {
+	case "list":
+		h.handleList(w, r)
+	case "get":
+		h.handleGet(w, r)
+	case "set":
+		h.handleSet(w, r)
+	case "delete":
+		h.handleDelete(w, r)
+	case "sync":
+		h.handleSync(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	entries, err := h.store.List(r.Context())
+	if err != nil {
+		h.logError("memory list failed", err)
+		writeError(w, http.StatusInternalServerError, "list failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, listResponse{Entries: entries})
+}
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if strings.TrimSpace(key) == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+	entry, err := h.store.Get(r.Context(), key)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if entry == nil {
+		writeError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
+
+func (h *Handler) handleSet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req setRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	entry, err := h.store.Set(r.Context(), req.Key, req.Content)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req deleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := h.store.Delete(r.Context(), req.Key); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (h *Handler) handleSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	report, err := h.store.Sync(r.Context())
+	if err != nil {
+		h.logError("memory sync failed", err)
+		writeError(w, http.StatusInternalServerError, "sync failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (h *Handler) logError(msg string, err error) {
+	if h.logger == nil {
+		return
+	}
+	h.logger.Error(msg, map[string]string{
+		"error": err.Error(),
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
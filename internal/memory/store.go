@@ -0,0 +1,423 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mouse/internal/config"
+	"mouse/internal/logging"
+	"mouse/internal/sessions"
+	"mouse/internal/sqlite"
+)
+
+const maxKeyLength = 128
+
+type Store struct {
+	dir      string
+	db       *sqlite.DB
+	autoSync bool
+	logger   *logging.Logger
+	interval time.Duration
+	mu       sync.Mutex
+	started  atomic.Bool
+	running  sync.WaitGroup
+}
+
+type Entry struct {
+	Key       string `json:"key"`
+	Content   string `json:"content"`
+	Path      string `json:"path"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type SyncReport struct {
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+	Removed int `json:"removed"`
+}
+
+func New(cfg config.MemoryConfig, db *sqlite.DB, logger *logging.Logger) (*Store, error) {
+	if strings.TrimSpace(cfg.Dir) == "" {
+		return nil, errors.New("memory: dir is required")
+	}
+	if cfg.AutoSync && db == nil {
+		return nil, errors.New("memory: db is required when auto_sync is enabled")
+	}
+	return &Store{
+		dir:      cfg.Dir,
+		db:       db,
+		autoSync: cfg.AutoSync,
+		logger:   logger,
+		interval: 30 * time.Second,
+	}, nil
+}
+
+func (s *Store) Start(ctx context.Context) {
+	if s == nil || !s.autoSync {
+		return
+	}
+	if !s.started.CompareAndSwap(false, true) {
+		return
+	}
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.syncAndLog(ctx)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.syncAndLog(ctx)
+			}
+		}
+	}()
+}
+
+func (s *Store) Wait() {
+	if s == nil {
+		return
+	}
+	s.running.Wait()
+}
+
+func (s *Store) Set(ctx context.Context, key, content string) (Entry, error) {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return Entry{}, err
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return Entry{}, errors.New("memory: content is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return Entry{}, fmt.Errorf("memory: create dir: %w", err)
+	}
+	path := s.pathFor(key)
+	existing, err := readEntry(path)
+	if err != nil && !os.IsNotExist(err) {
+		return Entry{}, err
+	}
+	if err == nil && existing.Key != key {
+		return Entry{}, fmt.Errorf("memory: key %q conflicts with existing key %q", key, existing.Key)
+	}
+	if err := writeFileAtomic(path, formatEntry(key, content)); err != nil {
+		return Entry{}, err
+	}
+	if s.autoSync {
+		if err := s.db.UpsertMemory(ctx, key, content); err != nil {
+			return Entry{}, err
+		}
+	}
+	entry := Entry{Key: key, Content: content, Path: path, UpdatedAt: time.Now().UTC().Format(time.RFC3339Nano)}
+	if s.logger != nil {
+		s.logger.Info("memory set", map[string]string{
+			"key":  key,
+			"path": path,
+		})
+	}
+	return entry, nil
+}
+
+func (s *Store) Get(ctx context.Context, key string) (*Entry, error) {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := readEntry(s.pathFor(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if entry.Key != key {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (s *Store) List(ctx context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listLocked()
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.pathFor(key)
+	entry, err := readEntry(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("memory: key %q not found", key)
+		}
+		return err
+	}
+	if entry.Key != key {
+		return fmt.Errorf("memory: key %q not found", key)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("memory: remove: %w", err)
+	}
+	if s.autoSync {
+		if err := s.db.DeleteMemory(ctx, key); err != nil {
+			return err
+		}
+	}
+	if s.logger != nil {
+		s.logger.Info("memory deleted", map[string]string{
+			"key": key,
+		})
+	}
+	return nil
+}
+
+func (s *Store) Remember(ctx context.Context, fact string, sessionStore *sessions.Store, sessionID string) (Entry, error) {
+	fact = strings.TrimSpace(fact)
+	if fact == "" {
+		return Entry{}, errors.New("memory: fact is required")
+	}
+	existing, err := s.List(ctx)
+	if err != nil {
+		return Entry{}, err
+	}
+	key := uniqueKey(factKey(Fact{Key: rememberKey(fact), Fact: fact}), existing)
+	return s.Set(ctx, key, fact+"\n\n"+provenance(s.dir, sessionStore, sessionID))
+}
+
+func (s *Store) Sync(ctx context.Context) (SyncReport, error) {
+	var report SyncReport
+	if s.db == nil {
+		return report, errors.New("memory: db is required for sync")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.listLocked()
+	if err != nil {
+		return report, err
+	}
+	rows, err := s.db.ListAllMemory(ctx)
+	if err != nil {
+		return report, err
+	}
+	stored := make(map[string]string, len(rows))
+	for _, row := range rows {
+		stored[row.Key] = row.Content
+	}
+	for _, entry := range entries {
+		prev, ok := stored[entry.Key]
+		delete(stored, entry.Key)
+		if ok && prev == entry.Content {
+			continue
+		}
+		if err := s.db.UpsertMemory(ctx, entry.Key, entry.Content); err != nil {
+			return report, err
+		}
+		if ok {
+			report.Updated++
+		} else {
+			report.Added++
+		}
+	}
+	for key := range stored {
+		if err := s.db.DeleteMemory(ctx, key); err != nil {
+			return report, err
+		}
+		report.Removed++
+	}
+	return report, nil
+}
+
+func (s *Store) syncAndLog(ctx context.Context) {
+	report, err := s.Sync(ctx)
+	if s.logger == nil {
+		return
+	}
+	if err != nil {
+		s.logger.Warn("memory sync failed", map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	if report.Added+report.Updated+report.Removed == 0 {
+		return
+	}
+	s.logger.Info("memory synced", map[string]string{
+		"added":   strconv.Itoa(report.Added),
+		"updated": strconv.Itoa(report.Updated),
+		"removed": strconv.Itoa(report.Removed),
+	})
+}
+
+func (s *Store) listLocked() ([]Entry, error) {
+	items, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("memory: read dir: %w", err)
+	}
+	seen := make(map[string]string)
+	var entries []Entry
+	for _, item := range items {
+		if item.IsDir() || !strings.HasSuffix(strings.ToLower(item.Name()), ".md") {
+			continue
+		}
+		path := filepath.Join(s.dir, item.Name())
+		entry, err := readEntry(path)
+		if err != nil {
+			if s.logger != nil {
+				s.logger.Warn("memory file skipped", map[string]string{
+					"path":  path,
+					"error": err.Error(),
+				})
+			}
+			continue
+		}
+		if other, ok := seen[entry.Key]; ok {
+			if s.logger != nil {
+				s.logger.Warn("memory duplicate key", map[string]string{
+					"key":   entry.Key,
+					"path":  path,
+					"other": other,
+				})
+			}
+			continue
+		}
+		seen[entry.Key] = path
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Key < entries[b].Key
+	})
+	return entries, nil
+}
+
+func (s *Store) pathFor(key string) string {
+	return filepath.Join(s.dir, fileName(key)+".md")
+}
+
+func readEntry(path string) (Entry, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	key, content := parseEntry(string(raw))
+	if key == "" {
+		key = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return Entry{
+		Key:       key,
+		Content:   content,
+		Path:      path,
+		UpdatedAt: info.ModTime().UTC().Format(time.RFC3339Nano),
+	}, nil
+}
+
+func parseEntry(raw string) (string, string) {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	first, rest, _ := strings.Cut(raw, "\n")
+	if !strings.HasPrefix(first, "# ") {
+		return "", strings.TrimSpace(raw)
+	}
+	return strings.TrimSpace(strings.TrimPrefix(first, "# ")), strings.TrimSpace(rest)
+}
+
+func formatEntry(key, content string) string {
+	return fmt.Sprintf("# %s\n\n%s\n", key, content)
+}
+
+func writeFileAtomic(path, data string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".memory-*")
+	if err != nil {
+		return fmt.Errorf("memory: create temp: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("memory: write: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("memory: close: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("memory: chmod: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("memory: rename: %w", err)
+	}
+	return nil
+}
+
+func rememberKey(fact string) string {
+	fields := strings.Fields(fact)
+	if len(fields) > 6 {
+		fields = fields[:6]
+	}
+	return strings.Join(fields, " ")
+}
+
+func normalizeKey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", errors.New("memory: key is required")
+	}
+	if strings.ContainsAny(key, "\r\n") {
+		return "", errors.New("memory: key must be a single line")
+	}
+	if len(key) > maxKeyLength {
+		return "", fmt.Errorf("memory: key exceeds %d characters", maxKeyLength)
+	}
+	return key, nil
+}
+
+func fileName(key string) string {
+	mapped := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		if r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '-'
+	}, key)
+	mapped = strings.Trim(mapped, "-.")
+	if mapped == "" {
+		return "memory"
+	}
+	return mapped
+}
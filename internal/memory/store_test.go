@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mouse/internal/config"
+	"mouse/internal/sqlite"
+)
+
+func TestStoreSetGetDelete(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, err := New(config.MemoryConfig{Store: "markdown", Dir: filepath.Join(dir, "memory"), AutoSync: true}, db, nil)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := store.Set(ctx, "Staging Cluster", "eu-west-2"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	entry, err := store.Get(ctx, "Staging Cluster")
+	if err != nil || entry == nil {
+		t.Fatalf("get: %v", err)
+	}
+	if entry.Content != "eu-west-2" {
+		t.Fatalf("unexpected content: %q", entry.Content)
+	}
+	row, err := db.GetMemory(ctx, "Staging Cluster")
+	if err != nil || row == nil || row.Content != "eu-west-2" {
+		t.Fatalf("expected sqlite mirror, got %+v (%v)", row, err)
+	}
+	if _, err := store.Set(ctx, "staging-cluster", "other"); err == nil {
+		t.Fatalf("expected conflicting key error")
+	}
+
+	if err := store.Delete(ctx, "Staging Cluster"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	row, err = db.GetMemory(ctx, "Staging Cluster")
+	if err != nil || row != nil {
+		t.Fatalf("expected sqlite row removed, got %+v (%v)", row, err)
+	}
+}
+
+func TestStoreSyncPicksUpExternalEdits(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	memDir := filepath.Join(dir, "memory")
+	store, err := New(config.MemoryConfig{Store: "markdown", Dir: memDir, AutoSync: true}, db, nil)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := store.Set(ctx, "editor", "vim"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(memDir, "editor.md"), []byte("# editor\n\nhelix\n"), 0o644); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(memDir, "notes.md"), []byte("plain file\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := db.UpsertMemory(ctx, "stale", "gone"); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	report, err := store.Sync(ctx)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if report.Added != 1 || report.Updated != 1 || report.Removed != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	row, _ := db.GetMemory(ctx, "notes")
+	if row == nil || row.Content != "plain file" {
+		t.Fatalf("expected headerless file keyed by name, got %+v", row)
+	}
+}
@@ -0,0 +1,183 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mouse/internal/indexer"
+	"mouse/internal/llm"
+	"mouse/internal/telegram"
+)
+
+const (
+	defaultUploadLimit = 20 << 20
+	maxImageBytes      = 5 << 20
+)
+
+type upload struct {
+	kind      string
+	fileID    string
+	name      string
+	mediaType string
+	size      int64
+}
+
+func pendingUploads(msg *telegram.Message) []upload {
+	var uploads []upload
+	if n := len(msg.Photo); n > 0 {
+		photo := msg.Photo[n-1]
+		uploads = append(uploads, upload{
+			kind:      "photo",
+			fileID:    photo.FileID,
+			name:      "photo-" + uploadName(photo.FileUniqueID) + ".jpg",
+			mediaType: "image/jpeg",
+			size:      photo.FileSize,
+		})
+	}
+	if doc := msg.Document; doc != nil {
+		name := uploadName(doc.FileName)
+		if name == "" {
+			name = "document-" + uploadName(doc.FileUniqueID)
+		}
+		mediaType := strings.ToLower(strings.TrimSpace(doc.MimeType))
+		if mediaType == "" {
+			mediaType = mime.TypeByExtension(filepath.Ext(name))
+		}
+		if base, _, err := mime.ParseMediaType(mediaType); err == nil {
+			mediaType = base
+		}
+		if indexer.IsText(name) && !strings.HasPrefix(mediaType, "text/") {
+			mediaType = "text/plain"
+		}
+		uploads = append(uploads, upload{
+			kind:      "document",
+			fileID:    doc.FileID,
+			name:      name,
+			mediaType: mediaType,
+			size:      doc.FileSize,
+		})
+	}
+	return uploads
+}
+
+func (o *Orchestrator) ingest(ctx context.Context, msg *telegram.Message, sessionID string) ([]llm.Attachment, []string, error) {
+	uploads := pendingUploads(msg)
+	if len(uploads) == 0 {
+		return nil, nil, nil
+	}
+	limit := o.uploadLimit
+	if limit <= 0 {
+		limit = defaultUploadLimit
+	}
+	var (
+		attachments []llm.Attachment
+		notes       []string
+	)
+	for _, up := range uploads {
+		if up.size > limit {
+			notes = append(notes, fmt.Sprintf("Attachment %s skipped: larger than %d bytes.", up.name, limit))
+			continue
+		}
+		if o.sender == nil {
+			notes = append(notes, fmt.Sprintf("Attachment %s skipped: downloads are not available.", up.name))
+			continue
+		}
+		data, _, err := o.sender.DownloadFile(ctx, up.fileID, limit)
+		if errors.Is(err, telegram.ErrFileTooLarge) {
+			notes = append(notes, fmt.Sprintf("Attachment %s skipped: larger than %d bytes.", up.name, limit))
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("download %s: %w", up.kind, err)
+		}
+		path, err := o.saveUpload(sessionID, up.name, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		oversized := strings.HasPrefix(up.mediaType, "image/") && base64.StdEncoding.EncodedLen(len(data)) > maxImageBytes
+		if oversized {
+			notes = append(notes, fmt.Sprintf("Image %s is too large for the model to view; saved to [%s](%s).", up.name, up.name, o.sessionLink(path)))
+		} else {
+			notes = append(notes, fmt.Sprintf("Attached %s: [%s](%s)", up.kind, up.name, o.sessionLink(path)))
+		}
+		if o.indexer != nil && indexer.IsText(up.name) {
+			if err := o.indexer.IndexFile(ctx, path); err != nil && o.logger != nil {
+				o.logger.Warn("upload index failed", map[string]string{
+					"path":  path,
+					"error": err.Error(),
+				})
+			}
+		}
+		if !oversized {
+			attachments = append(attachments, llm.Attachment{Name: up.name, MediaType: up.mediaType, Data: data})
+		}
+		if o.logger != nil {
+			o.logger.Info("telegram upload saved", map[string]string{
+				"session_id": sessionID,
+				"path":       path,
+				"media_type": up.mediaType,
+			})
+		}
+	}
+	return attachments, notes, nil
+}
+
+func (o *Orchestrator) saveUpload(sessionID, name string, data []byte) (string, error) {
+	dir := filepath.Join(o.uploadsDir, uploadName(sessionID))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create upload dir: %w", err)
+	}
+	stamp := time.Now().UTC().Format("20060102T150405.000Z")
+	path := filepath.Join(dir, stamp+"-"+name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("write upload: %w", err)
+	}
+	return path, nil
+}
+
+func (o *Orchestrator) sessionLink(path string) string {
+	target := path
+	if rel, err := filepath.Rel(o.sessions.Dir(), path); err == nil {
+		target = rel
+	}
+	return filepath.ToSlash(target)
+}
+
+func uploadName(name string) string {
+	name = filepath.Base(strings.TrimSpace(name))
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '.' || r == '-' || r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	mapped = strings.Trim(mapped, "._")
+	if len(mapped) > 100 {
+		mapped = mapped[len(mapped)-100:]
+	}
+	return mapped
+}
+
+func attachToLastUser(req llm.Request, attachments []llm.Attachment) llm.Request {
+	if len(attachments) == 0 {
+		return req
+	}
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			req.Messages[i].Attachments = append(req.Messages[i].Attachments, attachments...)
+			return req
+		}
+	}
+	req.Messages = append(req.Messages, llm.Message{Role: "user", Attachments: attachments})
+	return req
+}
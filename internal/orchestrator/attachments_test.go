@@ -0,0 +1,100 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mouse/internal/llm"
+	"mouse/internal/sessions"
+	"mouse/internal/telegram"
+)
+
+func TestPendingUploads(t *testing.T) {
+	msg := &telegram.Message{
+		Photo: []telegram.PhotoSize{
+			{FileID: "small", FileUniqueID: "u1", FileSize: 100},
+			{FileID: "large", FileUniqueID: "u2", FileSize: 900},
+		},
+		Document: &telegram.Document{FileID: "doc", FileName: "../Q3 report.csv", MimeType: "application/octet-stream"},
+	}
+	uploads := pendingUploads(msg)
+	if len(uploads) != 2 {
+		t.Fatalf("expected 2 uploads, got %d", len(uploads))
+	}
+	if uploads[0].fileID != "large" || uploads[0].mediaType != "image/jpeg" {
+		t.Fatalf("expected largest photo, got %+v", uploads[0])
+	}
+	if uploads[1].name != "Q3_report.csv" || uploads[1].mediaType != "text/plain" {
+		t.Fatalf("unexpected document upload %+v", uploads[1])
+	}
+}
+
+func TestAttachToLastUser(t *testing.T) {
+	req := llm.Request{Messages: []llm.Message{
+		{Role: "user", Content: "earlier"},
+		{Role: "assistant", Content: "reply"},
+		{Role: "user", Content: "see attached"},
+		{Role: "assistant", Content: "trailing"},
+	}}
+	req = attachToLastUser(req, []llm.Attachment{{Name: "a.png", MediaType: "image/png", Data: []byte{1}}})
+	if len(req.Messages[2].Attachments) != 1 || len(req.Messages[0].Attachments) != 0 {
+		t.Fatalf("attachment not placed on latest user turn: %+v", req.Messages)
+	}
+}
+
+func TestIngestReplacesOversizedImageWithNote(t *testing.T) {
+	files := map[string][]byte{
+		"big":   make([]byte, 4<<20),
+		"small": []byte("png"),
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getFile") {
+			var req struct {
+				FileID string `json:"file_id"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": map[string]any{
+				"file_id": req.FileID, "file_size": len(files[req.FileID]), "file_path": "photos/" + req.FileID,
+			}})
+			return
+		}
+		_, _ = w.Write(files[path.Base(r.URL.Path)])
+	}))
+	defer api.Close()
+	sender, err := telegram.NewSender(telegram.SenderConfig{APIBase: api.URL, BotToken: "t", AllowFrom: []string{"9"}}, nil)
+	if err != nil {
+		t.Fatalf("sender: %v", err)
+	}
+	dir := t.TempDir()
+	store, _ := sessions.NewStore(filepath.Join(dir, "sessions"))
+	o := &Orchestrator{sessions: store, sender: sender, uploadsDir: filepath.Join(dir, "uploads")}
+
+	for _, tc := range []struct {
+		fileID   string
+		attached int
+		note     string
+	}{
+		{"small", 1, "Attached photo"},
+		{"big", 0, "too large for the model"},
+	} {
+		msg := &telegram.Message{Photo: []telegram.PhotoSize{{FileID: tc.fileID, FileUniqueID: tc.fileID, FileSize: int64(len(files[tc.fileID]))}}}
+		attachments, notes, err := o.ingest(context.Background(), msg, "9")
+		if err != nil {
+			t.Fatalf("%s: ingest: %v", tc.fileID, err)
+		}
+		if len(attachments) != tc.attached || len(notes) != 1 || !strings.Contains(notes[0], tc.note) {
+			t.Fatalf("%s: got %d attachments, notes %v", tc.fileID, len(attachments), notes)
+		}
+	}
+	saved, _ := os.ReadDir(filepath.Join(dir, "uploads", "9"))
+	if len(saved) != 2 {
+		t.Fatalf("expected both images saved, got %d", len(saved))
+	}
+}
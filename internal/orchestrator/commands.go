@@ -0,0 +1,488 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"mouse/internal/config"
+	"mouse/internal/llm"
+	"mouse/internal/sessions"
+	"mouse/internal/telegram"
+	"mouse/internal/usage"
+)
+
+const (
+	searchLimit  = 5
+	outputLimit  = 3500
+	unknownReply = "Unknown command. Try /help."
+	budgetHelp   = "Usage: /budget, /budget override <user|chat|job|global> [id] [duration], /budget clear <user|chat|job|global> [id]"
+	usageHelp    = "Usage: /usage [today|month|7d|24h|YYYY-MM-DD] [model|session|user|job|day]"
+)
+
+type command struct {
+	name        string
+	description string
+	hidden      bool
+	privileged  bool
+	run         func(o *Orchestrator, ctx context.Context, update telegram.Update, sessionID, args string) (string, error)
+}
+
+func builtinCommands() []command {
+	return []command{
+		{name: "start", description: "Introduce the assistant", hidden: true, run: (*Orchestrator).cmdStart},
+		{name: "help", description: "List available commands", run: (*Orchestrator).cmdHelp},
+		{name: "new", description: "Archive this session and start fresh", run: (*Orchestrator).cmdReset},
+		{name: "reset", description: "Archive this session and start fresh", hidden: true, run: (*Orchestrator).cmdReset},
+		{name: "status", description: "Show session and assistant status", run: (*Orchestrator).cmdStatus},
+		{name: "search", description: "Search indexed files", run: (*Orchestrator).cmdSearch},
+		{name: "remember", description: "Save a fact to long-term memory", run: (*Orchestrator).cmdRemember},
+		{name: "run", description: "Run an allowed tool in the sandbox", privileged: true, run: (*Orchestrator).cmdRun},
+		{name: "cron", description: "List scheduled jobs", run: (*Orchestrator).cmdCron},
+		{name: "model", description: "Show or switch the model for this chat", run: (*Orchestrator).cmdModel},
+		{name: "usage", description: "Show LLM token usage and cost", run: (*Orchestrator).cmdUsage},
+		{name: "budget", description: "Show spend budgets; admins can grant overrides", run: (*Orchestrator).cmdBudget},
+	}
+}
+
+func (o *Orchestrator) Commands() []telegram.BotCommand {
+	out := make([]telegram.BotCommand, 0, len(o.commands))
+	for _, cmd := range o.commands {
+		if cmd.hidden {
+			continue
+		}
+		out = append(out, telegram.BotCommand{Command: cmd.name, Description: cmd.description})
+	}
+	return out
+}
+
+func parseCommand(text string) (string, string, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", "", false
+	}
+	head, args := text, ""
+	if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+		head, args = text[:i], text[i:]
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(head, "/"), "@")
+	name = strings.ToLower(name)
+	if name == "" {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(args), true
+}
+
+func (o *Orchestrator) matchCommand(text string) (string, string, bool) {
+	name, args, ok := parseCommand(text)
+	if !ok {
+		return "", "", false
+	}
+	if _, known := o.lookupCommand(name); !known {
+		return "", "", false
+	}
+	return name, args, true
+}
+
+func (o *Orchestrator) lookupCommand(name string) (command, bool) {
+	for _, cmd := range o.commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func (o *Orchestrator) commandAllowed(cmd command, user *telegram.User) bool {
+	allow, ok := o.commandAllow[cmd.name]
+	if !ok {
+		return !cmd.privileged
+	}
+	return telegram.IsAllowedUser(allow, user)
+}
+
+func (o *Orchestrator) runCommand(ctx context.Context, update telegram.Update, sessionID, name, args string) (string, error) {
+	cmd, ok := o.lookupCommand(name)
+	if !ok {
+		return unknownReply, nil
+	}
+	if !o.commandAllowed(cmd, update.Message.From) {
+		if o.logger != nil {
+			o.logger.Warn("telegram command denied", map[string]string{
+				"command":    cmd.name,
+				"session_id": sessionID,
+			})
+		}
+		return fmt.Sprintf("You are not allowed to use /%s.", cmd.name), nil
+	}
+	return cmd.run(o, ctx, update, sessionID, args)
+}
+
+func (o *Orchestrator) cmdHelp(ctx context.Context, update telegram.Update, sessionID, args string) (string, error) {
+	var b strings.Builder
+	b.WriteString("Commands:\n")
+	for _, cmd := range o.commands {
+		if cmd.hidden || !o.commandAllowed(cmd, update.Message.From) {
+			continue
+		}
+		fmt.Fprintf(&b, "/%s - %s\n", cmd.name, cmd.description)
+	}
+	b.WriteString("\nAnything else is sent to the assistant.")
+	return b.String(), nil
+}
+
+func (o *Orchestrator) cmdStart(ctx context.Context, update telegram.Update, sessionID, args string) (string, error) {
+	help, err := o.cmdHelp(ctx, update, sessionID, args)
+	if err != nil {
+		return "", err
+	}
+	return "Hi! Send me a message and I will pass it to the assistant.\n\n" + help, nil
+}
+
+func (o *Orchestrator) cmdReset(ctx context.Context, update telegram.Update, sessionID, args string) (string, error) {
+	if o.lifecycle == nil {
+		return "Session reset is not available.", nil
+	}
+	archivedID, err := o.lifecycle.Reset(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, sessions.ErrNotFound) {
+			return "Already on a fresh session.", nil
+		}
+		return "", fmt.Errorf("reset session: %w", err)
+	}
+	return fmt.Sprintf("Started a new session. Previous transcript archived as %s.", archivedID), nil
+}
+
+func (o *Orchestrator) cmdStatus(ctx context.Context, update telegram.Update, sessionID, args string) (string, error) {
+	entries := 0
+	transcript, err := o.sessions.Load(sessionID)
+	switch {
+	case err == nil:
+		entries = len(transcript.Entries)
+	case !errors.Is(err, sessions.ErrNotFound):
+		return "", fmt.Errorf("load session: %w", err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Session: %s (%d entries)\n", sessionID, entries)
+	fmt.Fprintf(&b, "Model: %s\n", o.activeModel(ctx, sessionID))
+	if o.memory != nil {
+		memories, err := o.memory.List(ctx)
+		if err != nil {
+			return "", fmt.Errorf("list memory: %w", err)
+		}
+		fmt.Fprintf(&b, "Memories: %d\n", len(memories))
+	}
+	fmt.Fprintf(&b, "Search: %s\n", enabled(o.indexer != nil))
+	fmt.Fprintf(&b, "Tools: %s\n", enabled(o.runner != nil && o.policy != nil))
+	fmt.Fprintf(&b, "Cron jobs: %d", len(o.scheduler.Jobs()))
+	return b.String(), nil
+}
+
+func (o *Orchestrator) cmdSearch(ctx context.Context, update telegram.Update, sessionID, args string) (string, error) {
+	if o.indexer == nil {
+		return "Search is not available.", nil
+	}
+	if args == "" {
+		return "Usage: /search <query>", nil
+	}
+	matches, err := o.indexer.Search(ctx, args, searchLimit)
+	if err != nil {
+		return "", fmt.Errorf("search: %w", err)
+	}
+	if len(matches) == 0 {
+		return "No matches.", nil
+	}
+	var b strings.Builder
+	for i, match := range matches {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "%s (%.2f)\n%s", match.Path, match.Score, match.Snippet)
+	}
+	return b.String(), nil
+}
+
+func (o *Orchestrator) cmdRemember(ctx context.Context, update telegram.Update, sessionID, args string) (string, error) {
+	if o.memory == nil {
+		return "Memory is not available.", nil
+	}
+	if args == "" {
+		return "Usage: /remember <fact>", nil
+	}
+	entry, err := o.memory.Remember(ctx, args, o.sessions, sessionID)
+	if err != nil {
+		return "", fmt.Errorf("remember: %w", err)
+	}
+	return fmt.Sprintf("Remembered as %q.", entry.Key), nil
+}
+
+func (o *Orchestrator) cmdRun(ctx context.Context, update telegram.Update, sessionID, args string) (string, error) {
+	if o.runner == nil || o.policy == nil {
+		return "Tool runner is not available.", nil
+	}
+	argv := strings.Fields(args)
+	if len(argv) == 0 {
+		return "Usage: /run <tool> [args...]", nil
+	}
+	tool := argv[0]
+	if !o.policy.Allowed(tool) {
+		if o.logger != nil {
+			o.logger.Warn("tool denied", map[string]string{
+				"tool":       tool,
+				"session_id": sessionID,
+			})
+		}
+		return fmt.Sprintf("Tool %s is not allowed.", tool), nil
+	}
+	result, err := o.runner.Run(ctx, argv)
+	if err != nil {
+		return fmt.Sprintf("%s failed: %s", tool, err.Error()), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s exited %d in %s", tool, result.ExitCode, result.Duration.Round(time.Millisecond))
+	if out := strings.TrimSpace(result.Stdout); out != "" {
+		b.WriteString("\n\n" + out)
+	}
+	if errOut := strings.TrimSpace(result.Stderr); errOut != "" {
+		b.WriteString("\n\nstderr:\n" + errOut)
+	}
+	return truncate(b.String(), outputLimit), nil
+}
+
+func (o *Orchestrator) cmdCron(ctx context.Context, update telegram.Update, sessionID, args string) (string, error) {
+	if o.scheduler == nil {
+		return "Cron is not enabled.", nil
+	}
+	jobs := o.scheduler.Jobs()
+	if len(jobs) == 0 {
+		return "No cron jobs configured.", nil
+	}
+	var b strings.Builder
+	for i, job := range jobs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s [%s] session %s, next %s", job.ID, job.Schedule, job.Session, job.Next.Format(time.RFC3339))
+	}
+	return b.String(), nil
+}
+
+func (o *Orchestrator) cmdModel(ctx context.Context, update telegram.Update, sessionID, args string) (string, error) {
+	if args == "" {
+		var b strings.Builder
+		b.WriteString("Model: " + o.activeModel(ctx, sessionID))
+		if aliases := o.modelAliases(); len(aliases) > 0 {
+			b.WriteString("\nAvailable: " + strings.Join(aliases, ", "))
+		}
+		b.WriteString("\nUse /model <name> to switch, /model default to reset.")
+		return b.String(), nil
+	}
+	if o.db == nil {
+		return "Model switching is not available.", nil
+	}
+	if name := strings.ToLower(args); name == "default" || name == "reset" {
+		if err := o.db.SetSessionModel(ctx, sessionID, ""); err != nil {
+			return "", fmt.Errorf("clear session model: %w", err)
+		}
+		return "Model reset to " + o.activeModel(ctx, sessionID) + ".", nil
+	}
+	model, ok := o.llmConfig.ResolveModel(args)
+	if !ok {
+		return fmt.Sprintf("Unknown model %q. Available: %s", args, strings.Join(o.modelAliases(), ", ")), nil
+	}
+	if err := o.db.SetSessionModel(ctx, sessionID, model); err != nil {
+		return "", fmt.Errorf("set session model: %w", err)
+	}
+	return "Model for this chat set to " + model + ".", nil
+}
+
+func (o *Orchestrator) activeModel(ctx context.Context, sessionID string) string {
+	if model := o.sessionModel(ctx, sessionID); model != "" {
+		return model + " (chat override)"
+	}
+	if len(o.llmConfig.Routes) > 0 {
+		return o.model + " (default, routing rules active)"
+	}
+	return o.model + " (default)"
+}
+
+func routeModels(routes []config.LLMRoute) []string {
+	models := make([]string, 0, len(routes))
+	for _, route := range routes {
+		models = append(models, route.Model)
+	}
+	return models
+}
+
+func (o *Orchestrator) modelAliases() []string {
+	aliases := make([]string, 0, len(o.llmConfig.Models))
+	for alias, model := range o.llmConfig.Models {
+		aliases = append(aliases, alias+" ("+model+")")
+	}
+	if len(aliases) == 0 {
+		seen := map[string]bool{}
+		for _, name := range append([]string{o.llmConfig.Model}, routeModels(o.llmConfig.Routes)...) {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				aliases = append(aliases, name)
+			}
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+func (o *Orchestrator) cmdUsage(ctx context.Context, update telegram.Update, sessionID, args string) (string, error) {
+	if o.usage == nil {
+		return "Usage tracking is not available.", nil
+	}
+	fields := strings.Fields(args)
+	period, by := "today", "model"
+	if len(fields) > 0 {
+		period = fields[0]
+	}
+	if len(fields) > 1 {
+		by = strings.ToLower(fields[1])
+	}
+	since, err := usage.ParseSince(period, time.Now())
+	if err != nil {
+		return usageHelp, nil
+	}
+	scope := sessionID
+	if telegram.IsAllowedUser(o.budgetAdmins, update.Message.From) {
+		scope = ""
+	}
+	report, err := o.usage.SessionReport(ctx, since, by, scope)
+	if errors.Is(err, usage.ErrInvalidGroup) {
+		return usageHelp, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("usage report: %w", err)
+	}
+	text := usage.Format(report)
+	if scope != "" {
+		text = "This chat only.\n" + text
+	}
+	return truncate(text, outputLimit), nil
+}
+
+func (o *Orchestrator) cmdBudget(ctx context.Context, update telegram.Update, sessionID, args string) (string, error) {
+	if !o.usage.BudgetsEnabled() {
+		return "Budgets are not enabled.", nil
+	}
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return o.budgetStatus(ctx, update, sessionID)
+	}
+	action := strings.ToLower(fields[0])
+	if action != "override" && action != "clear" {
+		return budgetHelp, nil
+	}
+	if !telegram.IsAllowedUser(o.budgetAdmins, update.Message.From) {
+		return "Only budget admins can change overrides.", nil
+	}
+	if len(fields) < 2 {
+		return budgetHelp, nil
+	}
+	scope, rest := strings.ToLower(fields[1]), fields[2:]
+	subject := ""
+	if scope != "global" && len(rest) > 0 {
+		subject, rest = rest[0], rest[1:]
+	}
+	if action == "clear" {
+		if err := o.usage.ClearOverride(ctx, scope, subject); err != nil {
+			return budgetHelp, nil
+		}
+		return fmt.Sprintf("Cleared %s budget override.", strings.TrimSpace(scope+" "+subject)), nil
+	}
+	duration := 24 * time.Hour
+	if len(rest) > 0 {
+		parsed, err := parseDuration(rest[0])
+		if err != nil {
+			return budgetHelp, nil
+		}
+		duration = parsed
+	}
+	by := ""
+	if update.Message.From != nil {
+		by = strconv.FormatInt(update.Message.From.ID, 10)
+	}
+	if err := o.usage.Override(ctx, scope, subject, duration, by); err != nil {
+		return budgetHelp, nil
+	}
+	return fmt.Sprintf("Budget override for %s active for %s.", strings.TrimSpace(scope+" "+subject), duration), nil
+}
+
+func (o *Orchestrator) budgetStatus(ctx context.Context, update telegram.Update, sessionID string) (string, error) {
+	tags := llm.Tags{Session: sessionID}
+	if update.Message.From != nil {
+		tags.User = strconv.FormatInt(update.Message.From.ID, 10)
+	}
+	statuses, err := o.usage.Status(ctx, tags)
+	if err != nil {
+		return "", fmt.Errorf("budget status: %w", err)
+	}
+	if len(statuses) == 0 {
+		return "No budgets apply to this chat.", nil
+	}
+	var b strings.Builder
+	for i, status := range statuses {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		tokenLimit, usdLimit := status.Limits.DailyTokens, status.Limits.DailyUSD
+		if status.Period == "monthly" {
+			tokenLimit, usdLimit = status.Limits.MonthlyTokens, status.Limits.MonthlyUSD
+		}
+		fmt.Fprintf(&b, "%s %s: %d tokens", status.Period, strings.TrimSpace(status.Scope+" "+status.Subject), status.Tokens)
+		if tokenLimit > 0 {
+			fmt.Fprintf(&b, " of %d", tokenLimit)
+		}
+		fmt.Fprintf(&b, ", $%.2f", status.CostUSD)
+		if usdLimit > 0 {
+			fmt.Fprintf(&b, " of $%.2f", usdLimit)
+		}
+		if status.Overridden {
+			b.WriteString(" (override active)")
+		}
+	}
+	return b.String(), nil
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
+
+func enabled(ok bool) string {
+	if ok {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+func truncate(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit] + "\n… (truncated, " + strconv.Itoa(len(text)-limit) + " bytes omitted)"
+}
@@ -0,0 +1,207 @@
+package orchestrator
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mouse/internal/config"
+	"mouse/internal/memory"
+	"mouse/internal/sessions"
+	"mouse/internal/sqlite"
+	"mouse/internal/telegram"
+	"mouse/internal/usage"
+)
+
+func TestParseCommand(t *testing.T) {
+	cases := []struct {
+		text string
+		name string
+		args string
+		ok   bool
+	}{
+		{text: "/help", name: "help", ok: true},
+		{text: "/Search@mouse_bot  deploy notes ", name: "search", args: "deploy notes", ok: true},
+		{text: "/remember\nthe cluster is eu-west-2", name: "remember", args: "the cluster is eu-west-2", ok: true},
+		{text: "hello /help", ok: false},
+		{text: "/", ok: false},
+	}
+	for _, tc := range cases {
+		name, args, ok := parseCommand(tc.text)
+		if name != tc.name || args != tc.args || ok != tc.ok {
+			t.Fatalf("parseCommand(%q) = %q, %q, %v", tc.text, name, args, ok)
+		}
+	}
+}
+
+func TestRunCommandAllowListAndRemember(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	sessionStore, err := sessions.NewStore(filepath.Join(dir, "sessions"))
+	if err != nil {
+		t.Fatalf("session store: %v", err)
+	}
+	memoryStore, err := memory.New(config.MemoryConfig{Store: "markdown", Dir: filepath.Join(dir, "memory"), AutoSync: true}, db, nil)
+	if err != nil {
+		t.Fatalf("memory store: %v", err)
+	}
+	o := &Orchestrator{
+		sessions:     sessionStore,
+		db:           db,
+		memory:       memoryStore,
+		model:        "test-model",
+		commands:     builtinCommands(),
+		commandAllow: map[string][]string{"run": {"admin"}},
+	}
+	ctx := context.Background()
+	update := telegram.Update{Message: &telegram.Message{From: &telegram.User{ID: 7, Username: "guest"}}}
+
+	reply, err := o.runCommand(ctx, update, "7", "run", "ls")
+	if err != nil || !strings.Contains(reply, "not allowed") {
+		t.Fatalf("expected /run denied, got %q (%v)", reply, err)
+	}
+	reply, err = o.runCommand(ctx, update, "7", "help", "")
+	if err != nil || strings.Contains(reply, "/run") || !strings.Contains(reply, "/remember") {
+		t.Fatalf("help should hide denied commands, got %q (%v)", reply, err)
+	}
+	if reply, _ := o.runCommand(ctx, update, "7", "bogus", ""); reply != unknownReply {
+		t.Fatalf("unexpected unknown reply %q", reply)
+	}
+	if reply, _ := o.runCommand(ctx, update, "7", "model", ""); !strings.Contains(reply, "test-model") {
+		t.Fatalf("unexpected model reply %q", reply)
+	}
+
+	reply, err = o.runCommand(ctx, update, "7", "remember", "The staging cluster lives in eu-west-2")
+	if err != nil {
+		t.Fatalf("remember: %v", err)
+	}
+	entries, err := memoryStore.List(ctx)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one memory, got %d (%v)", len(entries), err)
+	}
+	if !strings.Contains(reply, entries[0].Key) || !strings.Contains(entries[0].Content, "Source: [session 7]") {
+		t.Fatalf("unexpected memory %+v for reply %q", entries[0], reply)
+	}
+
+	for _, cmd := range o.Commands() {
+		if cmd.Command == "reset" {
+			t.Fatalf("hidden alias should not be registered")
+		}
+	}
+}
+
+func TestBudgetCommandRequiresAdmin(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	tracker, err := usage.NewTracker(nil, config.BudgetsConfig{Enabled: true, Chat: config.BudgetLimits{DailyUSD: 5}, Admins: []string{"1"}}, db, nil)
+	if err != nil {
+		t.Fatalf("tracker: %v", err)
+	}
+	o := &Orchestrator{usage: tracker, commands: builtinCommands(), budgetAdmins: []string{"1"}}
+	ctx := context.Background()
+	guest := telegram.Update{Message: &telegram.Message{From: &telegram.User{ID: 7}}}
+	admin := telegram.Update{Message: &telegram.Message{From: &telegram.User{ID: 1}}}
+
+	if reply, _ := o.runCommand(ctx, guest, "42", "budget", ""); !strings.Contains(reply, "daily chat 42: 0 tokens, $0.00 of $5.00") {
+		t.Fatalf("unexpected status %q", reply)
+	}
+	if reply, _ := o.runCommand(ctx, guest, "42", "budget", "override chat 42"); !strings.Contains(reply, "Only budget admins") {
+		t.Fatalf("expected guest override denied, got %q", reply)
+	}
+	if reply, _ := o.runCommand(ctx, admin, "42", "budget", "override chat 42 2d"); !strings.Contains(reply, "active for 48h0m0s") {
+		t.Fatalf("unexpected override reply %q", reply)
+	}
+	if reply, _ := o.runCommand(ctx, guest, "42", "budget", ""); !strings.Contains(reply, "override active") {
+		t.Fatalf("expected override in status, got %q", reply)
+	}
+}
+
+func TestModelCommandSwitchesPerChat(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	o := &Orchestrator{
+		db:        db,
+		model:     "big-model",
+		llmConfig: config.LLMConfig{Model: "big-model", Models: map[string]string{"fast": "small-model", "smart": "big-model"}},
+		commands:  builtinCommands(),
+	}
+	ctx := context.Background()
+	update := telegram.Update{Message: &telegram.Message{From: &telegram.User{ID: 7}}}
+
+	if reply, _ := o.runCommand(ctx, update, "7", "model", "fast"); !strings.Contains(reply, "small-model") {
+		t.Fatalf("unexpected switch reply %q", reply)
+	}
+	if got := o.sessionModel(ctx, "7"); got != "small-model" {
+		t.Fatalf("expected session override, got %q", got)
+	}
+	if got := o.sessionModel(ctx, "8"); got != "" {
+		t.Fatalf("other chats should keep the default, got %q", got)
+	}
+	if reply, _ := o.runCommand(ctx, update, "7", "model", "gpt-9"); !strings.Contains(reply, "Unknown model") {
+		t.Fatalf("expected unknown model reply, got %q", reply)
+	}
+	if reply, _ := o.runCommand(ctx, update, "7", "model", "default"); !strings.Contains(reply, "big-model (default)") {
+		t.Fatalf("unexpected reset reply %q", reply)
+	}
+}
+
+func TestUnknownCommandsReachAssistantAndRunDefaultsToDeny(t *testing.T) {
+	o := &Orchestrator{model: "test-model", commands: builtinCommands()}
+	for _, text := range []string{"/etc/hosts is broken", "/bogus please", "plain text"} {
+		if _, _, ok := o.matchCommand(text); ok {
+			t.Fatalf("expected %q to go to the assistant", text)
+		}
+	}
+	if name, _, ok := o.matchCommand("/start"); !ok || name != "start" {
+		t.Fatalf("expected /start to be handled, got %q %v", name, ok)
+	}
+	ctx := context.Background()
+	update := telegram.Update{Message: &telegram.Message{From: &telegram.User{ID: 7}}}
+	if reply, err := o.runCommand(ctx, update, "7", "start", ""); err != nil || !strings.Contains(reply, "/help") {
+		t.Fatalf("unexpected start reply %q (%v)", reply, err)
+	}
+	if reply, _ := o.runCommand(ctx, update, "7", "run", "ls"); !strings.Contains(reply, "not allowed") {
+		t.Fatalf("expected /run denied without telegram.commands entry, got %q", reply)
+	}
+}
+
+func TestUsageCommandScopedToChatForNonAdmins(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	tracker, err := usage.NewTracker(nil, config.BudgetsConfig{}, db, nil)
+	if err != nil {
+		t.Fatalf("tracker: %v", err)
+	}
+	ctx := context.Background()
+	for _, session := range []string{"42", "99"} {
+		if err := db.InsertLLMUsage(ctx, sqlite.LLMUsage{SessionID: session, UserID: session, Model: "m", InputTokens: 10}); err != nil {
+			t.Fatalf("insert usage: %v", err)
+		}
+	}
+	o := &Orchestrator{usage: tracker, commands: builtinCommands(), budgetAdmins: []string{"1"}}
+	guest := telegram.Update{Message: &telegram.Message{From: &telegram.User{ID: 42}}}
+	admin := telegram.Update{Message: &telegram.Message{From: &telegram.User{ID: 1}}}
+
+	reply, _ := o.runCommand(ctx, guest, "42", "usage", "today session")
+	if !strings.Contains(reply, "42:") || strings.Contains(reply, "99") {
+		t.Fatalf("expected guest to see only their chat, got %q", reply)
+	}
+	reply, _ = o.runCommand(ctx, admin, "1", "usage", "today session")
+	if !strings.Contains(reply, "42:") || !strings.Contains(reply, "99:") {
+		t.Fatalf("expected admin to see every chat, got %q", reply)
+	}
+}
@@ -0,0 +1,72 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mouse/internal/config"
+	"mouse/internal/fakellm"
+	"mouse/internal/sessions"
+	"mouse/internal/sqlite"
+	"mouse/internal/telegram"
+)
+
+func TestProcessAgainstFakeLLM(t *testing.T) {
+	fake := fakellm.New(
+		fakellm.Step{Match: "weather", ToolUse: []fakellm.ToolUse{{ID: "toolu_1", Name: "lookup_weather", Input: map[string]any{"city": "Leeds"}}}},
+		fakellm.Step{Text: "It is raining in Leeds."},
+	)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, err := sessions.NewStore(filepath.Join(dir, "sessions"))
+	if err != nil {
+		t.Fatalf("session store: %v", err)
+	}
+	cfg := &config.Config{}
+	cfg.App.Workspace = dir
+	cfg.Telegram.BotToken = "test-token"
+	cfg.LLM = config.LLMConfig{Provider: "anthropic", APIKey: "test-key", Model: "claude-test", MaxTokens: 256, BaseURL: server.URL}
+	o, err := New(cfg, db, Deps{Sessions: store}, nil)
+	if err != nil {
+		t.Fatalf("new orchestrator: %v", err)
+	}
+	o.sender = nil
+
+	update := telegram.Update{Message: &telegram.Message{Chat: &telegram.Chat{ID: 5}, From: &telegram.User{ID: 5}, Text: "what is the weather?"}}
+	if _, err := o.Process(context.Background(), update); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 2 || fake.Remaining() != 0 {
+		t.Fatalf("expected both steps consumed, got %d requests, %d left", len(requests), fake.Remaining())
+	}
+	if requests[0].Model != "claude-test" || requests[0].LastUser != "what is the weather?" {
+		t.Fatalf("unexpected first request %+v", requests[0])
+	}
+	if !strings.Contains(string(requests[1].Body), `"tool_use_id":"toolu_1"`) {
+		t.Fatalf("expected tool result in follow-up, got %s", requests[1].Body)
+	}
+	transcript, err := store.Load("5")
+	if err != nil {
+		t.Fatalf("load transcript: %v", err)
+	}
+	last := transcript.Entries[len(transcript.Entries)-1]
+	if last.Role != "assistant" || last.Content != "It is raining in Leeds." {
+		t.Fatalf("unexpected final entry %+v", last)
+	}
+	messages, err := db.ListSessionMessages(context.Background(), "5", 10)
+	if err != nil || len(messages) == 0 || messages[0].Model != "claude-test" {
+		t.Fatalf("expected assistant model recorded, got %+v (%v)", messages, err)
+	}
+}
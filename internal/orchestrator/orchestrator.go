@@ -4,60 +4,147 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"mouse/internal/config"
+	"mouse/internal/cron"
+	"mouse/internal/history"
+	"mouse/internal/indexer"
 	"mouse/internal/llm"
 	"mouse/internal/logging"
+	"mouse/internal/memory"
+	"mouse/internal/sandbox"
 	"mouse/internal/sessions"
 	"mouse/internal/sqlite"
 	"mouse/internal/telegram"
+	"mouse/internal/tools"
+	"mouse/internal/usage"
 )
 
+const failureReply = "Sorry, something went wrong while answering. Please try again."
+
 type Orchestrator struct {
-	sessions *sessions.Store
-	llm      llm.Client
-	db       *sqlite.DB
-	sender   *telegram.Sender
-	logger   *logging.Logger
+	sessions       *sessions.Store
+	history        *history.Manager
+	llm            llm.Client
+	db             *sqlite.DB
+	sender         *telegram.Sender
+	lifecycle      *sessions.Lifecycle
+	memory         *memory.Store
+	extractor      *memory.Extractor
+	indexer        *indexer.Indexer
+	runner         *sandbox.Runner
+	policy         *tools.Policy
+	scheduler      *cron.Scheduler
+	usage          *usage.Tracker
+	model          string
+	llmConfig      config.LLMConfig
+	workspace      string
+	privateDirs    []string
+	uploadsDir     string
+	uploadLimit    int64
+	sandboxWorkdir string
+	tools          []assistantTool
+	wrapTools      func(ToolRunner) ToolRunner
+	commands       []command
+	commandAllow   map[string][]string
+	budgetAdmins   []string
+	logger         *logging.Logger
 }
 
-func New(cfg *config.Config, db *sqlite.DB, logger *logging.Logger) (*Orchestrator, error) {
-	store, err := sessions.NewStore(cfg.Sessions.Dir)
-	if err != nil {
-		return nil, err
+type Deps struct {
+	Sessions  *sessions.Store
+	Lifecycle *sessions.Lifecycle
+	Memory    *memory.Store
+	Extractor *memory.Extractor
+	Indexer   *indexer.Indexer
+	Runner    *sandbox.Runner
+	Policy    *tools.Policy
+	Scheduler *cron.Scheduler
+	Usage     *usage.Tracker
+	WrapLLM   func(llm.Client) llm.Client
+	WrapTools func(ToolRunner) ToolRunner
+}
+
+func New(cfg *config.Config, db *sqlite.DB, deps Deps, logger *logging.Logger) (*Orchestrator, error) {
+	store := deps.Sessions
+	if store == nil {
+		var err error
+		store, err = sessions.NewStore(cfg.Sessions.Dir)
+		if err != nil {
+			return nil, err
+		}
 	}
-	client, llmErr := llm.New(llm.Config{
-		Provider:  cfg.LLM.Provider,
-		APIKey:    cfg.LLM.APIKey,
-		Model:     cfg.LLM.Model,
-		MaxTokens: cfg.LLM.MaxTokens,
-	}, logging.New("llm"))
+	client, llmErr := llm.New(llm.FromConfig(cfg.LLM), logging.New("llm"))
 	if llmErr != nil && logger != nil {
 		logger.Warn("llm client initialized with warnings", map[string]string{
 			"error": llmErr.Error(),
 		})
 	}
+	if deps.WrapLLM != nil {
+		client = deps.WrapLLM(client)
+	}
+	client = deps.Usage.Wrap(client)
 	if db == nil {
 		return nil, errors.New("sqlite db is required")
 	}
+	manager, err := history.New(cfg.Sessions, db, store, client, logging.New("history"))
+	if err != nil {
+		return nil, err
+	}
 	sender, err := telegram.NewSender(telegram.SenderConfig{
+		APIBase:   cfg.Telegram.APIBase,
 		BotToken:  cfg.Telegram.BotToken,
 		AllowFrom: cfg.Telegram.AllowFrom,
+		Workspace: cfg.App.Workspace,
 	}, logging.New("telegram-outbound"))
 	if err != nil {
 		return nil, err
 	}
+	commandAllow := make(map[string][]string, len(cfg.Telegram.Commands))
+	for name, allow := range cfg.Telegram.Commands {
+		commandAllow[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "/"))] = allow
+	}
 	return &Orchestrator{
-		sessions: store,
-		llm:      client,
-		db:       db,
-		sender:   sender,
-		logger:   logger,
+		sessions:       store,
+		history:        manager,
+		llm:            client,
+		db:             db,
+		sender:         sender,
+		lifecycle:      deps.Lifecycle,
+		memory:         deps.Memory,
+		extractor:      deps.Extractor,
+		indexer:        deps.Indexer,
+		runner:         deps.Runner,
+		policy:         deps.Policy,
+		scheduler:      deps.Scheduler,
+		usage:          deps.Usage,
+		model:          cfg.LLM.Model,
+		llmConfig:      cfg.LLM,
+		workspace:      cfg.App.Workspace,
+		privateDirs:    privateDirs(cfg),
+		uploadsDir:     cfg.UploadsDir(),
+		uploadLimit:    cfg.Telegram.Uploads.MaxBytes,
+		sandboxWorkdir: cfg.Sandbox.Docker.Workdir,
+		tools:          builtinTools(),
+		wrapTools:      deps.WrapTools,
+		commands:       builtinCommands(),
+		commandAllow:   commandAllow,
+		budgetAdmins:   cfg.Budgets.Admins,
+		logger:         logger,
 	}, nil
 }
 
+func privateDirs(cfg *config.Config) []string {
+	dirs := []string{cfg.Sessions.Dir, cfg.Memory.Dir, cfg.UploadsDir(), filepath.Join(cfg.App.Workspace, "logs")}
+	if cfg.Index.SQLitePath != "" {
+		dirs = append(dirs, filepath.Dir(cfg.Index.SQLitePath))
+	}
+	return dirs
+}
+
 func (o *Orchestrator) Process(ctx context.Context, update telegram.Update) (string, error) {
 	if update.Message == nil {
 		return "", errors.New("missing message")
@@ -66,34 +153,143 @@ func (o *Orchestrator) Process(ctx context.Context, update telegram.Update) (str
 		return "", errors.New("missing chat")
 	}
 	sessionID := strconv.FormatInt(update.Message.Chat.ID, 10)
+	tags := llm.Tags{Session: sessionID}
+	if update.Message.From != nil {
+		tags.User = strconv.FormatInt(update.Message.From.ID, 10)
+	}
+	ctx = llm.WithTags(ctx, tags)
 	text := strings.TrimSpace(update.Message.Text)
 	if text == "" {
-		return sessionID, errors.New("empty message")
+		text = strings.TrimSpace(update.Message.Caption)
 	}
-	if _, err := o.sessions.Append(sessionID, "user", text); err != nil {
-		return sessionID, fmt.Errorf("append user message: %w", err)
+	if text == "" && len(pendingUploads(update.Message)) == 0 {
+		return sessionID, errors.New("empty message")
 	}
-	if o.db != nil {
-		if _, err := o.db.AppendSessionMessage(ctx, sessionID, "user", text); err != nil {
-			return sessionID, fmt.Errorf("sqlite append user message: %w", err)
+	if name, args, ok := o.matchCommand(update.Message.Text); ok {
+		response, err := o.runCommand(ctx, update, sessionID, name, args)
+		if err != nil {
+			return sessionID, err
 		}
+		return sessionID, o.reply(ctx, update, response)
+	}
+	if err := o.usage.Allow(ctx); err != nil {
+		return sessionID, o.budgetReply(ctx, update, sessionID, err)
+	}
+	attachments, notes, err := o.ingest(ctx, update.Message, sessionID)
+	if err != nil {
+		return sessionID, fmt.Errorf("ingest attachments: %w", err)
 	}
-	response, err := o.llm.Complete(ctx, text)
+	if len(notes) > 0 {
+		text = strings.TrimSpace(text + "\n\n" + strings.Join(notes, "\n"))
+	}
+	if err := o.record(ctx, sessionID, "user", text); err != nil {
+		return sessionID, err
+	}
+	req, err := o.history.Build(ctx, sessionID)
 	if err != nil {
-		return sessionID, fmt.Errorf("llm completion: %w", err)
+		return sessionID, fmt.Errorf("build context: %w", err)
+	}
+	req = attachToLastUser(req, attachments)
+	req.Model = o.sessionModel(ctx, sessionID)
+	prog := o.startProgress(ctx, update)
+	defer prog.Close()
+	reply, err := o.complete(ctx, update, sessionID, req, prog)
+	var exceeded *usage.ExceededError
+	if errors.As(err, &exceeded) {
+		o.logBudget(sessionID, exceeded)
+		return sessionID, prog.Finish(ctx, exceeded.Friendly())
+	}
+	if err != nil {
+		return sessionID, o.fail(ctx, sessionID, prog, fmt.Errorf("llm completion: %w", err))
+	}
+	response := strings.TrimSpace(reply.Text)
+	if response == "" {
+		response = "Done."
+	}
+	if err := o.recordModel(ctx, sessionID, "assistant", response, reply.Model); err != nil {
+		return sessionID, o.fail(ctx, sessionID, prog, err)
 	}
-	if _, err := o.sessions.Append(sessionID, "assistant", response); err != nil {
-		return sessionID, fmt.Errorf("append assistant message: %w", err)
+	o.extractor.Touch(sessionID)
+	if err := prog.Finish(ctx, response); err != nil {
+		return sessionID, fmt.Errorf("telegram send: %w", err)
+	}
+	if _, err := o.history.Compact(ctx, sessionID); err != nil && o.logger != nil {
+		o.logger.Warn("session compaction failed", map[string]string{
+			"session_id": sessionID,
+			"error":      err.Error(),
+		})
+	}
+	return sessionID, nil
+}
+
+func (o *Orchestrator) fail(ctx context.Context, sessionID string, prog *progress, err error) error {
+	if finishErr := prog.Finish(context.WithoutCancel(ctx), failureReply); finishErr != nil && o.logger != nil {
+		o.logger.Warn("failure reply not delivered", map[string]string{
+			"session_id": sessionID,
+			"error":      finishErr.Error(),
+		})
+	}
+	return err
+}
+
+func (o *Orchestrator) record(ctx context.Context, sessionID, role, content string) error {
+	return o.recordModel(ctx, sessionID, role, content, "")
+}
+
+func (o *Orchestrator) recordModel(ctx context.Context, sessionID, role, content, model string) error {
+	if _, err := o.sessions.AppendModel(sessionID, role, content, model); err != nil {
+		return fmt.Errorf("append %s message: %w", role, err)
 	}
 	if o.db != nil {
-		if _, err := o.db.AppendSessionMessage(ctx, sessionID, "assistant", response); err != nil {
-			return sessionID, fmt.Errorf("sqlite append assistant message: %w", err)
+		if _, err := o.db.AppendSessionMessageModel(ctx, sessionID, role, content, model); err != nil {
+			return fmt.Errorf("sqlite append %s message: %w", role, err)
 		}
 	}
-	if o.sender != nil {
-		if err := o.sender.SendMessage(ctx, update.Message.Chat.ID, update.Message.From, response); err != nil {
-			return sessionID, fmt.Errorf("telegram send: %w", err)
-		}
+	return nil
+}
+
+func (o *Orchestrator) budgetReply(ctx context.Context, update telegram.Update, sessionID string, err error) error {
+	var exceeded *usage.ExceededError
+	if !errors.As(err, &exceeded) {
+		return fmt.Errorf("budget check: %w", err)
 	}
-	return sessionID, nil
+	o.logBudget(sessionID, exceeded)
+	return o.reply(ctx, update, exceeded.Friendly())
+}
+
+func (o *Orchestrator) logBudget(sessionID string, exceeded *usage.ExceededError) {
+	if o.logger == nil {
+		return
+	}
+	o.logger.Warn("llm budget exceeded", map[string]string{
+		"session_id": sessionID,
+		"scope":      exceeded.Scope,
+		"subject":    exceeded.Subject,
+		"period":     exceeded.Period,
+		"unit":       exceeded.Unit,
+	})
+}
+
+func (o *Orchestrator) sessionModel(ctx context.Context, sessionID string) string {
+	if o.db == nil {
+		return ""
+	}
+	model, err := o.db.SessionModel(ctx, sessionID)
+	if err != nil && o.logger != nil {
+		o.logger.Warn("session model lookup failed", map[string]string{
+			"session_id": sessionID,
+			"error":      err.Error(),
+		})
+	}
+	return model
+}
+
+func (o *Orchestrator) reply(ctx context.Context, update telegram.Update, text string) error {
+	if o.sender == nil {
+		return nil
+	}
+	if err := o.sender.SendMessage(ctx, update.Message.Chat.ID, update.Message.From, text); err != nil {
+		return fmt.Errorf("telegram send: %w", err)
+	}
+	return nil
 }
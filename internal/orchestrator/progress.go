@@ -0,0 +1,191 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"mouse/internal/telegram"
+)
+
+const (
+	typingInterval   = 4 * time.Second
+	placeholderDelay = 8 * time.Second
+	editInterval     = time.Second
+	placeholderText  = "Working on it…"
+)
+
+type progressSender interface {
+	SendChatAction(ctx context.Context, chatID int64, action string) error
+	PostMessage(ctx context.Context, chatID int64, user *telegram.User, text string) (int64, error)
+	EditMessageText(ctx context.Context, chatID, messageID int64, text string) error
+	SendMessage(ctx context.Context, chatID int64, user *telegram.User, text string) error
+}
+
+type progress struct {
+	sender    progressSender
+	chatID    int64
+	user      *telegram.User
+	mu        sync.Mutex
+	messageID int64
+	steps     []string
+	partial   string
+	lastEdit  time.Time
+	finished  bool
+	stop      chan struct{}
+	stopOnce  sync.Once
+	done      sync.WaitGroup
+}
+
+func (o *Orchestrator) startProgress(ctx context.Context, update telegram.Update) *progress {
+	if o.sender == nil {
+		return nil
+	}
+	return startProgress(ctx, o.sender, update.Message.Chat.ID, update.Message.From, typingInterval, placeholderDelay)
+}
+
+func startProgress(ctx context.Context, sender progressSender, chatID int64, user *telegram.User, pulse, delay time.Duration) *progress {
+	p := &progress{sender: sender, chatID: chatID, user: user, stop: make(chan struct{})}
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		_ = sender.SendChatAction(ctx, chatID, telegram.ActionTyping)
+		ticker := time.NewTicker(pulse)
+		defer ticker.Stop()
+		placeholder := time.NewTimer(delay)
+		defer placeholder.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				_ = sender.SendChatAction(ctx, chatID, telegram.ActionTyping)
+			case <-placeholder.C:
+				p.mu.Lock()
+				p.ensurePlaceholder(ctx)
+				p.mu.Unlock()
+			}
+		}
+	}()
+	return p
+}
+
+func (p *progress) Step(ctx context.Context, status string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		return
+	}
+	p.steps = append(p.steps, status)
+	if !p.ensurePlaceholder(ctx) {
+		return
+	}
+	p.edit(ctx, true)
+}
+
+func (p *progress) Partial(ctx context.Context, text string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		return
+	}
+	p.partial = text
+	if p.messageID == 0 {
+		return
+	}
+	p.edit(ctx, false)
+}
+
+func (p *progress) Finish(ctx context.Context, text string) error {
+	if p == nil {
+		return nil
+	}
+	p.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finished = true
+	if p.messageID == 0 {
+		return p.sender.SendMessage(ctx, p.chatID, p.user, text)
+	}
+	if parts := telegram.SplitMessage(text, telegram.MaxMessageLength); len(parts) > 0 {
+		if err := p.sender.EditMessageText(ctx, p.chatID, p.messageID, parts[0]); err == nil {
+			for _, part := range parts[1:] {
+				if err := p.sender.SendMessage(ctx, p.chatID, p.user, part); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	p.partial = ""
+	p.steps = append(p.steps, "Done.")
+	p.edit(ctx, true)
+	return p.sender.SendMessage(ctx, p.chatID, p.user, text)
+}
+
+func (p *progress) Close() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	p.done.Wait()
+}
+
+func (p *progress) ensurePlaceholder(ctx context.Context) bool {
+	if p.messageID != 0 {
+		return true
+	}
+	if p.finished {
+		return false
+	}
+	id, err := p.sender.PostMessage(ctx, p.chatID, p.user, p.render())
+	if err != nil || id == 0 {
+		return false
+	}
+	p.messageID = id
+	p.lastEdit = time.Now()
+	return true
+}
+
+func (p *progress) edit(ctx context.Context, force bool) {
+	if !force && time.Since(p.lastEdit) < editInterval {
+		return
+	}
+	if err := p.sender.EditMessageText(ctx, p.chatID, p.messageID, p.render()); err == nil {
+		p.lastEdit = time.Now()
+	}
+}
+
+func (p *progress) render() string {
+	var b strings.Builder
+	b.WriteString(placeholderText)
+	for _, step := range p.steps {
+		b.WriteString("\n• " + step)
+	}
+	if partial := strings.TrimSpace(p.partial); partial != "" {
+		b.WriteString("\n\n" + partial)
+	}
+	text := b.String()
+	if telegram.MessageLength(text) <= telegram.MaxMessageLength {
+		return text
+	}
+	runes := []rune(text)
+	start, units := len(runes), telegram.MessageLength("…")
+	for start > 0 && units+utf16.RuneLen(runes[start-1]) <= telegram.MaxMessageLength {
+		units += utf16.RuneLen(runes[start-1])
+		start--
+	}
+	return "…" + string(runes[start:])
+}
@@ -0,0 +1,115 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mouse/internal/telegram"
+)
+
+type fakeProgressSender struct {
+	mu      sync.Mutex
+	actions int
+	posts   []string
+	edits   []string
+	sends   []string
+}
+
+func (f *fakeProgressSender) SendChatAction(ctx context.Context, chatID int64, action string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.actions++
+	return nil
+}
+
+func (f *fakeProgressSender) PostMessage(ctx context.Context, chatID int64, user *telegram.User, text string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.posts = append(f.posts, text)
+	return 100, nil
+}
+
+func (f *fakeProgressSender) EditMessageText(ctx context.Context, chatID, messageID int64, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.edits = append(f.edits, text)
+	return nil
+}
+
+func (f *fakeProgressSender) SendMessage(ctx context.Context, chatID int64, user *telegram.User, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sends = append(f.sends, text)
+	return nil
+}
+
+func TestProgressQuickTurnSendsPlainReply(t *testing.T) {
+	sender := &fakeProgressSender{}
+	p := startProgress(context.Background(), sender, 1, nil, time.Hour, time.Hour)
+	if err := p.Finish(context.Background(), "hi"); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if sender.actions != 1 || len(sender.posts) != 0 || len(sender.sends) != 1 {
+		t.Fatalf("unexpected calls %+v", sender)
+	}
+}
+
+func TestProgressToolStepsEditPlaceholder(t *testing.T) {
+	sender := &fakeProgressSender{}
+	ctx := context.Background()
+	p := startProgress(ctx, sender, 1, nil, time.Hour, time.Hour)
+	p.Step(ctx, "Running send_file…")
+	p.Step(ctx, "send_file done")
+	if err := p.Finish(ctx, "Report sent."); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if len(sender.posts) != 1 || !strings.Contains(sender.posts[0], "Running send_file") {
+		t.Fatalf("expected placeholder with first step, got %+v", sender.posts)
+	}
+	if len(sender.sends) != 0 || sender.edits[len(sender.edits)-1] != "Report sent." {
+		t.Fatalf("expected final edit, got edits %+v sends %+v", sender.edits, sender.sends)
+	}
+}
+
+func TestProgressPlaceholderAfterDelay(t *testing.T) {
+	sender := &fakeProgressSender{}
+	p := startProgress(context.Background(), sender, 1, nil, 5*time.Millisecond, 20*time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+	if err := p.Finish(context.Background(), "slow answer"); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if sender.actions < 2 || len(sender.posts) != 1 || sender.posts[0] != placeholderText {
+		t.Fatalf("expected typing pulses and placeholder, got %+v", sender)
+	}
+}
+
+func TestProgressFinishSplitsLongReply(t *testing.T) {
+	sender := &fakeProgressSender{}
+	p := startProgress(context.Background(), sender, 1, nil, time.Hour, time.Hour)
+	p.Step(context.Background(), "Running send_file…")
+	reply := strings.Repeat("é", 5000)
+	if err := p.Finish(context.Background(), reply); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	last := sender.edits[len(sender.edits)-1]
+	if len(sender.sends) != 1 || last+sender.sends[0] != reply {
+		t.Fatalf("expected placeholder edited with the first part and the rest sent, got %d sends", len(sender.sends))
+	}
+}
+
+func TestProcessFailureFinishesPlaceholder(t *testing.T) {
+	sender := &fakeProgressSender{}
+	p := startProgress(context.Background(), sender, 1, nil, time.Hour, time.Hour)
+	p.Step(context.Background(), "Running send_file…")
+	o := &Orchestrator{}
+	err := o.fail(context.Background(), "1", p, context.DeadlineExceeded)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected original error, got %v", err)
+	}
+	if last := sender.edits[len(sender.edits)-1]; last != failureReply {
+		t.Fatalf("expected placeholder replaced by failure reply, got %q", last)
+	}
+}
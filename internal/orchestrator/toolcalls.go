@@ -0,0 +1,215 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"mouse/internal/llm"
+	"mouse/internal/telegram"
+)
+
+const maxToolRounds = 5
+
+type toolFunc func(o *Orchestrator, ctx context.Context, update telegram.Update, sessionID string, input json.RawMessage) (string, error)
+
+type ToolRunner func(ctx context.Context, call llm.ToolCall) llm.ToolResult
+
+type assistantTool struct {
+	spec llm.Tool
+	run  toolFunc
+}
+
+var photoExtensions = map[string]struct{}{
+	".jpg":  {},
+	".jpeg": {},
+	".png":  {},
+	".gif":  {},
+	".webp": {},
+}
+
+func builtinTools() []assistantTool {
+	return []assistantTool{
+		{
+			spec: llm.Tool{
+				Name:        "send_file",
+				Description: "Send a file from the workspace to the user in Telegram. Use it to deliver reports, charts or logs produced by tools. Paths are relative to the workspace root. Session transcripts, memory, logs, the database and other chats' uploads cannot be sent.",
+				InputSchema: json.RawMessage(`{"type":"object","properties":{"path":{"type":"string","description":"Workspace-relative path of the file to send."},"caption":{"type":"string","description":"Optional caption shown with the file."}},"required":["path"]}`),
+			},
+			run: (*Orchestrator).toolSendFile,
+		},
+	}
+}
+
+func (o *Orchestrator) toolSpecs() []llm.Tool {
+	if o.sender == nil {
+		return nil
+	}
+	specs := make([]llm.Tool, 0, len(o.tools))
+	for _, tool := range o.tools {
+		specs = append(specs, tool.spec)
+	}
+	return specs
+}
+
+func (o *Orchestrator) complete(ctx context.Context, update telegram.Update, sessionID string, req llm.Request, prog *progress) (llm.Response, error) {
+	req.Tools = o.toolSpecs()
+	for round := 0; ; round++ {
+		var partial strings.Builder
+		resp, err := llm.Stream(ctx, o.llm, req, func(event llm.StreamEvent) {
+			if event.Type == llm.EventText {
+				partial.WriteString(event.Text)
+				prog.Partial(ctx, partial.String())
+			}
+		})
+		if err != nil {
+			return resp, err
+		}
+		if len(resp.ToolCalls) == 0 {
+			return resp, nil
+		}
+		if round >= maxToolRounds {
+			if o.logger != nil {
+				o.logger.Warn("tool rounds exhausted", map[string]string{
+					"session_id": sessionID,
+					"rounds":     strconv.Itoa(maxToolRounds),
+				})
+			}
+			resp.Text = strings.TrimSpace(resp.Text + fmt.Sprintf("\n\nStopped after %d tool rounds without finishing.", maxToolRounds))
+			return resp, nil
+		}
+		results := make([]llm.ToolResult, 0, len(resp.ToolCalls))
+		for _, call := range resp.ToolCalls {
+			prog.Step(ctx, "Running "+call.Name+"…")
+			result := o.runTool(ctx, update, sessionID, call)
+			if result.IsError {
+				prog.Step(ctx, call.Name+" failed")
+			} else {
+				prog.Step(ctx, call.Name+" done")
+			}
+			results = append(results, result)
+		}
+		req.Messages = append(req.Messages,
+			llm.Message{Role: "assistant", Content: resp.Text, ToolCalls: resp.ToolCalls},
+			llm.Message{Role: "user", ToolResults: results},
+		)
+	}
+}
+
+func (o *Orchestrator) runTool(ctx context.Context, update telegram.Update, sessionID string, call llm.ToolCall) llm.ToolResult {
+	var run toolFunc
+	for _, tool := range o.tools {
+		if tool.spec.Name == call.Name {
+			run = tool.run
+			break
+		}
+	}
+	if run == nil {
+		return llm.ToolResult{ToolUseID: call.ID, Content: fmt.Sprintf("unknown tool %q", call.Name), IsError: true}
+	}
+	exec := ToolRunner(func(ctx context.Context, call llm.ToolCall) llm.ToolResult {
+		result := llm.ToolResult{ToolUseID: call.ID}
+		output, err := run(o, ctx, update, sessionID, call.Input)
+		if err != nil {
+			result.Content = err.Error()
+			result.IsError = true
+		} else {
+			result.Content = output
+		}
+		return result
+	})
+	if o.wrapTools != nil {
+		exec = o.wrapTools(exec)
+	}
+	result := exec(ctx, call)
+	if err := o.record(ctx, sessionID, "tool", fmt.Sprintf("%s: %s", call.Name, result.Content)); err != nil && o.logger != nil {
+		o.logger.Warn("tool call record failed", map[string]string{
+			"session_id": sessionID,
+			"tool":       call.Name,
+			"error":      err.Error(),
+		})
+	}
+	if o.logger != nil {
+		o.logger.Info("assistant tool call", map[string]string{
+			"session_id": sessionID,
+			"tool":       call.Name,
+			"is_error":   strconv.FormatBool(result.IsError),
+		})
+	}
+	return result
+}
+
+type sendFileInput struct {
+	Path    string `json:"path"`
+	Caption string `json:"caption"`
+}
+
+func (o *Orchestrator) toolSendFile(ctx context.Context, update telegram.Update, sessionID string, input json.RawMessage) (string, error) {
+	if o.sender == nil {
+		return "", errors.New("telegram sender is not configured")
+	}
+	var args sendFileInput
+	if err := json.Unmarshal(input, &args); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	target := o.workspacePath(args.Path)
+	if target == "" {
+		return "", errors.New("path is required")
+	}
+	if err := o.checkShareable(sessionID, target); err != nil {
+		return "", err
+	}
+	send := o.sender.SendDocument
+	if _, ok := photoExtensions[strings.ToLower(filepath.Ext(target))]; ok {
+		send = o.sender.SendPhoto
+	}
+	if err := send(ctx, update.Message.Chat.ID, update.Message.From, target, args.Caption); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sent %s", target), nil
+}
+
+func (o *Orchestrator) checkShareable(sessionID, target string) error {
+	if o.workspace == "" {
+		return nil
+	}
+	resolved, err := telegram.ResolveWorkspacePath(o.workspace, target)
+	if err != nil {
+		return err
+	}
+	if o.uploadsDir != "" && within(filepath.Join(o.uploadsDir, uploadName(sessionID)), resolved) {
+		return nil
+	}
+	for _, dir := range o.privateDirs {
+		if within(dir, resolved) {
+			return fmt.Errorf("%s is in a private directory and cannot be sent", target)
+		}
+	}
+	return nil
+}
+
+func within(dir, resolved string) bool {
+	if strings.TrimSpace(dir) == "" {
+		return false
+	}
+	_, err := telegram.ResolveWorkspacePath(dir, resolved)
+	return err == nil
+}
+
+func (o *Orchestrator) workspacePath(p string) string {
+	p = strings.TrimSpace(p)
+	workdir := strings.TrimRight(o.sandboxWorkdir, "/")
+	if workdir != "" && (p == workdir || strings.HasPrefix(p, workdir+"/")) {
+		p = strings.TrimPrefix(strings.TrimPrefix(p, workdir), "/")
+		if p == "" {
+			p = "."
+		}
+		return filepath.FromSlash(path.Clean(p))
+	}
+	return p
+}
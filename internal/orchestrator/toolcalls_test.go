@@ -0,0 +1,139 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mouse/internal/config"
+	"mouse/internal/llm"
+	"mouse/internal/sessions"
+	"mouse/internal/sqlite"
+	"mouse/internal/telegram"
+)
+
+type scriptedLLM struct {
+	responses []llm.Response
+	requests  []llm.Request
+}
+
+func (s *scriptedLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	return "", nil
+}
+
+func (s *scriptedLLM) Chat(ctx context.Context, req llm.Request) (llm.Response, error) {
+	s.requests = append(s.requests, req)
+	resp := s.responses[0]
+	if len(s.responses) > 1 {
+		s.responses = s.responses[1:]
+	}
+	return resp, nil
+}
+
+func TestCompleteRunsToolLoop(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, err := sessions.NewStore(filepath.Join(dir, "sessions"))
+	if err != nil {
+		t.Fatalf("session store: %v", err)
+	}
+	client := &scriptedLLM{responses: []llm.Response{
+		{StopReason: "tool_use", ToolCalls: []llm.ToolCall{{ID: "call-1", Name: "send_file", Input: json.RawMessage(`{"path":"report.pdf"}`)}}},
+		{Text: "Could not send it.", StopReason: "end_turn"},
+	}}
+	o := &Orchestrator{sessions: store, db: db, llm: client, tools: builtinTools()}
+	update := telegram.Update{Message: &telegram.Message{Chat: &telegram.Chat{ID: 9}, From: &telegram.User{ID: 9}}}
+
+	resp, err := o.complete(context.Background(), update, "9", llm.Request{Messages: []llm.Message{{Role: "user", Content: "send the report"}}}, nil)
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if resp.Text != "Could not send it." || len(client.requests) != 2 {
+		t.Fatalf("unexpected loop result %+v after %d requests", resp, len(client.requests))
+	}
+	followUp := client.requests[1].Messages
+	if len(followUp) != 3 || len(followUp[1].ToolCalls) != 1 {
+		t.Fatalf("expected tool call echoed back, got %+v", followUp)
+	}
+	results := followUp[2].ToolResults
+	if len(results) != 1 || results[0].ToolUseID != "call-1" || !results[0].IsError {
+		t.Fatalf("expected failed tool result without sender, got %+v", results)
+	}
+	transcript, err := store.Load("9")
+	if err != nil || len(transcript.Entries) != 1 || transcript.Entries[0].Role != "tool" {
+		t.Fatalf("expected tool entry in transcript, got %+v (%v)", transcript, err)
+	}
+}
+
+func TestWorkspacePathStripsSandboxWorkdir(t *testing.T) {
+	o := &Orchestrator{sandboxWorkdir: "/workspace"}
+	if got := o.workspacePath("/workspace/out/chart.png"); got != filepath.FromSlash("out/chart.png") {
+		t.Fatalf("unexpected path %q", got)
+	}
+	if got := o.workspacePath("notes/log.txt"); got != "notes/log.txt" {
+		t.Fatalf("unexpected path %q", got)
+	}
+}
+
+func TestSendFileRejectsPrivateDirs(t *testing.T) {
+	workspace := t.TempDir()
+	files := []string{"reports/q3.pdf", "sessions/42.md", "sqlite/mouse.db", "memory/fact.md", "logs/mouse.log", "uploads/9/photo.jpg", "uploads/42/photo.jpg"}
+	for _, name := range files {
+		path := filepath.Join(workspace, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	cfg := &config.Config{App: config.AppConfig{Workspace: workspace}}
+	cfg.Sessions.Dir = filepath.Join(workspace, "sessions")
+	cfg.Memory.Dir = filepath.Join(workspace, "memory")
+	cfg.Index.SQLitePath = filepath.Join(workspace, "sqlite", "mouse.db")
+	o := &Orchestrator{workspace: workspace, uploadsDir: cfg.UploadsDir(), privateDirs: privateDirs(cfg), sandboxWorkdir: "/workspace"}
+
+	for _, name := range []string{"reports/q3.pdf", "uploads/9/photo.jpg"} {
+		if err := o.checkShareable("9", o.workspacePath(name)); err != nil {
+			t.Fatalf("expected %s to be shareable: %v", name, err)
+		}
+	}
+	for _, name := range []string{"sessions/42.md", "/workspace/sqlite/mouse.db", "memory/fact.md", "logs/mouse.log", "uploads/42/photo.jpg"} {
+		if err := o.checkShareable("9", o.workspacePath(name)); err == nil {
+			t.Fatalf("expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestCompleteStopsAfterMaxToolRounds(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, err := sessions.NewStore(filepath.Join(dir, "sessions"))
+	if err != nil {
+		t.Fatalf("session store: %v", err)
+	}
+	client := &scriptedLLM{responses: []llm.Response{
+		{StopReason: "tool_use", ToolCalls: []llm.ToolCall{{ID: "call", Name: "send_file", Input: json.RawMessage(`{"path":"report.pdf"}`)}}},
+	}}
+	o := &Orchestrator{sessions: store, db: db, llm: client, tools: builtinTools()}
+	update := telegram.Update{Message: &telegram.Message{Chat: &telegram.Chat{ID: 9}, From: &telegram.User{ID: 9}}}
+
+	resp, err := o.complete(context.Background(), update, "9", llm.Request{Messages: []llm.Message{{Role: "user", Content: "loop"}}}, nil)
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if !strings.Contains(resp.Text, "Stopped after 5 tool rounds") || len(client.requests) != maxToolRounds+1 {
+		t.Fatalf("unexpected result %q after %d requests", resp.Text, len(client.requests))
+	}
+}
@@ -0,0 +1,202 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mouse/internal/llm"
+	"mouse/internal/sessions"
+	"mouse/internal/telegram"
+)
+
+const FixtureVersion = 1
+
+type Fixture struct {
+	Version    int       `json:"version"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Turns      []Turn    `json:"turns"`
+}
+
+type Turn struct {
+	Update    telegram.Update `json:"update"`
+	SessionID string          `json:"session_id"`
+	Error     string          `json:"error,omitempty"`
+	LLM       []Exchange      `json:"llm"`
+	Outbound  []Outbound      `json:"outbound"`
+	Session   []Entry         `json:"session"`
+}
+
+type Exchange struct {
+	Kind        string       `json:"kind"`
+	ToolResults []ToolResult `json:"tool_results,omitempty"`
+	Completion  string       `json:"completion,omitempty"`
+	Response    *Response    `json:"response,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+type ToolResult struct {
+	ToolUseID string `json:"tool_use_id"`
+	Content   string `json:"content"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+type Response struct {
+	Text       string     `json:"text"`
+	StopReason string     `json:"stop_reason"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	Model      string     `json:"model"`
+	Usage      llm.Usage  `json:"usage"`
+}
+
+type ToolCall struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+}
+
+type Outbound struct {
+	Method string `json:"method"`
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+type Entry struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay: read fixture: %w", err)
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("replay: parse fixture: %w", err)
+	}
+	if fixture.Version != FixtureVersion {
+		return nil, fmt.Errorf("replay: unsupported fixture version %d", fixture.Version)
+	}
+	return &fixture, nil
+}
+
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("replay: marshal fixture: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("replay: create fixture dir: %w", err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("replay: write fixture: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replay: write fixture: %w", err)
+	}
+	return nil
+}
+
+func toolResults(req llm.Request) []ToolResult {
+	if len(req.Messages) == 0 {
+		return nil
+	}
+	var out []ToolResult
+	for _, result := range req.Messages[len(req.Messages)-1].ToolResults {
+		out = append(out, ToolResult{ToolUseID: result.ToolUseID, Content: result.Content, IsError: result.IsError})
+	}
+	return out
+}
+
+func fromResponse(resp llm.Response) *Response {
+	out := &Response{Text: resp.Text, StopReason: resp.StopReason, Model: resp.Model, Usage: resp.Usage}
+	for _, call := range resp.ToolCalls {
+		out.ToolCalls = append(out.ToolCalls, ToolCall{ID: call.ID, Name: call.Name, Input: call.Input})
+	}
+	return out
+}
+
+func (r *Response) response() llm.Response {
+	if r == nil {
+		return llm.Response{}
+	}
+	out := llm.Response{Text: r.Text, StopReason: r.StopReason, Model: r.Model, Usage: r.Usage}
+	for _, call := range r.ToolCalls {
+		out.ToolCalls = append(out.ToolCalls, llm.ToolCall{ID: call.ID, Name: call.Name, Input: call.Input})
+	}
+	return out
+}
+
+func entriesSince(store *sessions.Store, sessionID string, from int) []Entry {
+	transcript, err := store.Load(sessionID)
+	if err != nil {
+		return nil
+	}
+	if from > len(transcript.Entries) {
+		from = 0
+	}
+	var out []Entry
+	for _, entry := range transcript.Entries[from:] {
+		out = append(out, Entry{Role: entry.Role, Content: entry.Content})
+	}
+	return out
+}
+
+func entryCount(store *sessions.Store, sessionID string) int {
+	transcript, err := store.Load(sessionID)
+	if err != nil {
+		return 0
+	}
+	return len(transcript.Entries)
+}
+
+type call struct {
+	method    string
+	params    map[string]any
+	messageID int64
+}
+
+func finalMessages(calls []call) []Outbound {
+	var out []Outbound
+	index := make(map[int64]int)
+	for _, c := range calls {
+		chatID := int64Param(c.params, "chat_id")
+		switch c.method {
+		case "sendMessage":
+			if c.messageID != 0 {
+				index[c.messageID] = len(out)
+			}
+			out = append(out, Outbound{Method: c.method, ChatID: chatID, Text: stringParam(c.params, "text")})
+		case "editMessageText":
+			if i, ok := index[int64Param(c.params, "message_id")]; ok {
+				out[i].Text = stringParam(c.params, "text")
+			}
+		case "sendPhoto", "sendDocument":
+			out = append(out, Outbound{Method: c.method, ChatID: chatID, Text: stringParam(c.params, "caption")})
+		}
+	}
+	return out
+}
+
+func int64Param(params map[string]any, key string) int64 {
+	switch v := params[key].(type) {
+	case float64:
+		return int64(v)
+	case string:
+		var n int64
+		_, _ = fmt.Sscan(v, &n)
+		return n
+	}
+	return 0
+}
+
+func stringParam(params map[string]any, key string) string {
+	v, _ := params[key].(string)
+	return v
+}
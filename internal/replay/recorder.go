@@ -0,0 +1,259 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mouse/internal/llm"
+	"mouse/internal/logging"
+	"mouse/internal/sessions"
+	"mouse/internal/telegram"
+)
+
+type Recorder struct {
+	path     string
+	sessions *sessions.Store
+	logger   *logging.Logger
+	turnMu   sync.Mutex
+	mu       sync.Mutex
+	fixture  Fixture
+	current  *Turn
+	calls    []call
+}
+
+func NewRecorder(path string, store *sessions.Store, logger *logging.Logger) (*Recorder, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, errors.New("replay: fixture path is required")
+	}
+	if store == nil {
+		return nil, errors.New("replay: sessions store is required")
+	}
+	return &Recorder{
+		path:     path,
+		sessions: store,
+		logger:   logger,
+		fixture:  Fixture{Version: FixtureVersion, RecordedAt: time.Now().UTC()},
+	}, nil
+}
+
+func (r *Recorder) WrapProcessor(proc telegram.Processor) telegram.Processor {
+	return &recordingProcessor{recorder: r, proc: proc}
+}
+
+func (r *Recorder) WrapLLM(client llm.Client) llm.Client {
+	return &recordingLLM{recorder: r, client: client}
+}
+
+type recordingProcessor struct {
+	recorder *Recorder
+	proc     telegram.Processor
+}
+
+func (p *recordingProcessor) Process(ctx context.Context, update telegram.Update) (string, error) {
+	r := p.recorder
+	r.turnMu.Lock()
+	defer r.turnMu.Unlock()
+
+	guess := chatSession(update)
+	before := entryCount(r.sessions, guess)
+	turn := &Turn{Update: update}
+	r.mu.Lock()
+	r.current = turn
+	r.calls = nil
+	r.mu.Unlock()
+
+	sessionID, err := p.proc.Process(ctx, update)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = nil
+	turn.SessionID = sessionID
+	if err != nil {
+		turn.Error = err.Error()
+	}
+	turn.Outbound = finalMessages(r.calls)
+	if sessionID != "" {
+		if sessionID != guess {
+			before = 0
+		}
+		turn.Session = entriesSince(r.sessions, sessionID, before)
+	}
+	r.fixture.Turns = append(r.fixture.Turns, *turn)
+	if saveErr := r.fixture.Save(r.path); saveErr != nil && r.logger != nil {
+		r.logger.Warn("replay fixture save failed", map[string]string{
+			"path":  r.path,
+			"error": saveErr.Error(),
+		})
+	}
+	return sessionID, err
+}
+
+func (r *Recorder) exchange(ex Exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current != nil {
+		r.current.LLM = append(r.current.LLM, ex)
+	}
+}
+
+type recordingLLM struct {
+	recorder *Recorder
+	client   llm.Client
+}
+
+func (c *recordingLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	text, err := c.client.Complete(ctx, prompt)
+	ex := Exchange{Kind: "complete", Completion: text}
+	if err != nil {
+		ex.Error = err.Error()
+	}
+	c.recorder.exchange(ex)
+	return text, err
+}
+
+func (c *recordingLLM) Chat(ctx context.Context, req llm.Request) (llm.Response, error) {
+	resp, err := c.client.Chat(ctx, req)
+	c.record(req, resp, err)
+	return resp, err
+}
+
+func (c *recordingLLM) Stream(ctx context.Context, req llm.Request, fn func(llm.StreamEvent)) (llm.Response, error) {
+	resp, err := llm.Stream(ctx, c.client, req, fn)
+	c.record(req, resp, err)
+	return resp, err
+}
+
+func (c *recordingLLM) record(req llm.Request, resp llm.Response, err error) {
+	ex := Exchange{Kind: "chat", ToolResults: toolResults(req)}
+	if err != nil {
+		ex.Error = err.Error()
+	} else {
+		ex.Response = fromResponse(resp)
+	}
+	c.recorder.exchange(ex)
+}
+
+type callKey struct{}
+
+func (r *Recorder) Proxy(upstream string) (http.Handler, error) {
+	target, err := url.Parse(upstream)
+	if err != nil {
+		return nil, fmt.Errorf("replay: parse upstream: %w", err)
+	}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(req *httputil.ProxyRequest) {
+			req.SetURL(target)
+			req.Out.Host = target.Host
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			c, ok := resp.Request.Context().Value(callKey{}).(*call)
+			if !ok {
+				return nil
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				return err
+			}
+			var parsed struct {
+				Result struct {
+					MessageID int64 `json:"message_id"`
+				} `json:"result"`
+			}
+			if json.Unmarshal(body, &parsed) == nil {
+				c.messageID = parsed.Result.MessageID
+			}
+			r.mu.Lock()
+			if r.current != nil {
+				r.calls = append(r.calls, *c)
+			}
+			r.mu.Unlock()
+			return nil
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		method := botMethod(req.URL.Path)
+		if method == "" {
+			proxy.ServeHTTP(w, req)
+			return
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, "unreadable body", http.StatusBadRequest)
+			return
+		}
+		c := &call{method: method, params: requestParams(req, body)}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		proxy.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), callKey{}, c)))
+	}), nil
+}
+
+func (r *Recorder) ListenProxy(upstream string) (string, error) {
+	handler, err := r.Proxy(upstream)
+	if err != nil {
+		return "", err
+	}
+	addr, _, err := serve(handler)
+	return addr, err
+}
+
+func botMethod(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if !strings.HasPrefix(path, "bot") {
+		return ""
+	}
+	_, method, _ := strings.Cut(path, "/")
+	return method
+}
+
+func requestParams(req *http.Request, body []byte) map[string]any {
+	params := map[string]any{}
+	contentType := req.Header.Get("content-type")
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		_ = json.Unmarshal(body, &params)
+	case strings.HasPrefix(contentType, "multipart/form-data"):
+		clone := req.Clone(req.Context())
+		clone.Body = io.NopCloser(bytes.NewReader(body))
+		if err := clone.ParseMultipartForm(32 << 20); err == nil {
+			for key, values := range clone.MultipartForm.Value {
+				if len(values) > 0 {
+					params[key] = values[0]
+				}
+			}
+		}
+	}
+	return params
+}
+
+func chatSession(update telegram.Update) string {
+	if update.Message == nil || update.Message.Chat == nil {
+		return ""
+	}
+	return strconv.FormatInt(update.Message.Chat.ID, 10)
+}
+
+func serve(handler http.Handler) (string, *http.Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, fmt.Errorf("replay: listen: %w", err)
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	return "http://" + listener.Addr().String(), server, nil
+}
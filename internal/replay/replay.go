@@ -0,0 +1,298 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"mouse/internal/config"
+	"mouse/internal/faketelegram"
+	"mouse/internal/gateway"
+	"mouse/internal/llm"
+	"mouse/internal/logging"
+	"mouse/internal/orchestrator"
+	"mouse/internal/sessions"
+	"mouse/internal/telegram"
+)
+
+func Run(ctx context.Context, cfg *config.Config, fixture *Fixture, logger *logging.Logger) (*Report, error) {
+	if !cfg.Telegram.Enabled || cfg.Telegram.Webhook.Path == "" {
+		return nil, errors.New("replay: telegram webhook must be enabled in config")
+	}
+	dir, err := os.MkdirTemp("", "mouse-replay-")
+	if err != nil {
+		return nil, fmt.Errorf("replay: temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	isolated, err := isolate(cfg, dir)
+	if err != nil {
+		return nil, err
+	}
+	tg := faketelegram.New()
+	tgURL, tgServer, err := serve(tg)
+	if err != nil {
+		return nil, err
+	}
+	defer tgServer.Close()
+	isolated.Telegram.APIBase = tgURL
+
+	player := &player{}
+	server, err := gateway.NewServerWithHooks(isolated, logger, gateway.Hooks{WrapLLM: player.wrap, WrapTools: player.wrapTools})
+	if err != nil {
+		return nil, fmt.Errorf("replay: gateway: %w", err)
+	}
+	defer server.Shutdown(context.Background())
+	gwURL, gwServer, err := serve(server.Handler())
+	if err != nil {
+		return nil, err
+	}
+	defer gwServer.Close()
+	if err := telegram.SetWebhook(ctx, tgURL, isolated.Telegram.BotToken, gwURL, isolated.Telegram.Webhook.Path, isolated.Telegram.Webhook.Secret, nil); err != nil {
+		return nil, fmt.Errorf("replay: webhook: %w", err)
+	}
+	store, err := sessions.NewStore(isolated.Sessions.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("replay: sessions: %w", err)
+	}
+
+	report := &Report{Turns: len(fixture.Turns)}
+	for i, want := range fixture.Turns {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		sessionID := want.SessionID
+		if sessionID == "" {
+			sessionID = chatSession(want.Update)
+		}
+		before := entryCount(store, sessionID)
+		callsBefore := len(tg.Calls(""))
+		player.begin(want.LLM)
+		status, err := tg.Deliver(ctx, want.Update)
+		if err != nil {
+			return report, err
+		}
+		got := Turn{LLM: player.end()}
+		got.Outbound = finalMessages(fakeCalls(tg.Calls("")[callsBefore:]))
+		got.Session = entriesSince(store, sessionID, before)
+		if status != http.StatusOK {
+			got.Error = fmt.Sprintf("webhook status %d", status)
+		}
+		if lines := compareTurn(want, got); len(lines) > 0 {
+			report.Diffs = append(report.Diffs, TurnDiff{Turn: i + 1, UpdateID: want.Update.UpdateID, SessionID: sessionID, Lines: lines})
+		}
+	}
+	return report, nil
+}
+
+func isolate(cfg *config.Config, dir string) (*config.Config, error) {
+	c := *cfg
+	c.App.Workspace = dir
+	c.Sessions.Dir = filepath.Join(dir, "sessions")
+	c.Sessions.Reconcile.OnStartup = false
+	c.Sessions.Reconcile.IntervalMinutes = 0
+	c.Memory.Dir = filepath.Join(dir, "memory")
+	c.Memory.AutoSync = false
+	c.Memory.Extract.Enabled = false
+	c.Index.SQLitePath = filepath.Join(dir, "mouse.db")
+	c.Index.Watch.Paths = nil
+	c.Telegram.Uploads.Dir = filepath.Join(dir, "uploads")
+	c.Cron.Enabled = false
+	c.Budgets.Enabled = false
+	c.Sandbox.Enabled = false
+	c.Sandbox.Docker.Binds = nil
+	for _, path := range []string{c.Sessions.Dir, c.Memory.Dir, c.Telegram.Uploads.Dir} {
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			return nil, fmt.Errorf("replay: create %s: %w", path, err)
+		}
+	}
+	return &c, nil
+}
+
+func fakeCalls(calls []faketelegram.Call) []call {
+	out := make([]call, 0, len(calls))
+	for _, c := range calls {
+		out = append(out, call{method: c.Method, params: c.Params, messageID: c.MessageID})
+	}
+	return out
+}
+
+type player struct {
+	mu       sync.Mutex
+	recorded []Exchange
+	used     []bool
+	got      []Exchange
+}
+
+func (p *player) wrap(llm.Client) llm.Client {
+	return p
+}
+
+func (p *player) wrapTools(orchestrator.ToolRunner) orchestrator.ToolRunner {
+	return p.runTool
+}
+
+func (p *player) runTool(ctx context.Context, call llm.ToolCall) llm.ToolResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, ex := range p.recorded {
+		for _, result := range ex.ToolResults {
+			if result.ToolUseID == call.ID {
+				return llm.ToolResult{ToolUseID: call.ID, Content: result.Content, IsError: result.IsError}
+			}
+		}
+	}
+	return llm.ToolResult{ToolUseID: call.ID, Content: fmt.Sprintf("replay: no recorded result for tool call %s", call.ID), IsError: true}
+}
+
+func (p *player) begin(recorded []Exchange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.recorded = recorded
+	p.used = make([]bool, len(recorded))
+	p.got = nil
+}
+
+func (p *player) end() []Exchange {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	got := p.got
+	p.recorded, p.used, p.got = nil, nil, nil
+	return got
+}
+
+func (p *player) take(got Exchange) (Exchange, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.got = append(p.got, got)
+	for i, ex := range p.recorded {
+		if p.used[i] || ex.Kind != got.Kind {
+			continue
+		}
+		p.used[i] = true
+		return ex, true
+	}
+	return Exchange{}, false
+}
+
+func (p *player) Complete(ctx context.Context, prompt string) (string, error) {
+	ex, ok := p.take(Exchange{Kind: "complete"})
+	if !ok {
+		return "", errors.New("replay: no recorded completion left")
+	}
+	if ex.Error != "" {
+		return "", errors.New(ex.Error)
+	}
+	return ex.Completion, nil
+}
+
+func (p *player) Chat(ctx context.Context, req llm.Request) (llm.Response, error) {
+	ex, ok := p.take(Exchange{Kind: "chat", ToolResults: toolResults(req)})
+	if !ok {
+		return llm.Response{}, errors.New("replay: no recorded chat response left")
+	}
+	if ex.Error != "" {
+		return llm.Response{}, errors.New(ex.Error)
+	}
+	return ex.Response.response(), nil
+}
+
+type Report struct {
+	Turns int
+	Diffs []TurnDiff
+}
+
+type TurnDiff struct {
+	Turn      int
+	UpdateID  int64
+	SessionID string
+	Lines     []string
+}
+
+func (r *Report) OK() bool {
+	return len(r.Diffs) == 0
+}
+
+func (r *Report) Format() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "replayed %d turns, %d with differences\n", r.Turns, len(r.Diffs))
+	for _, diff := range r.Diffs {
+		fmt.Fprintf(&b, "\nturn %d (update %d, session %s)\n", diff.Turn, diff.UpdateID, diff.SessionID)
+		for _, line := range diff.Lines {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	return b.String()
+}
+
+func compareTurn(want, got Turn) []string {
+	var lines []string
+	if (want.Error == "") != (got.Error == "") {
+		lines = append(lines, fmt.Sprintf("error: want %q, got %q", want.Error, got.Error))
+	}
+	if len(want.LLM) != len(got.LLM) {
+		lines = append(lines, fmt.Sprintf("llm calls: want %d, got %d", len(want.LLM), len(got.LLM)))
+	}
+	wantTools, gotTools := chatToolResults(want.LLM), chatToolResults(got.LLM)
+	for i := 0; i < max(len(wantTools), len(gotTools)); i++ {
+		w, g := at(wantTools, i), at(gotTools, i)
+		if w != g {
+			lines = append(lines, fmt.Sprintf("tool result %d: want %s, got %s", i+1, w, g))
+		}
+	}
+	for i := 0; i < max(len(want.Outbound), len(got.Outbound)); i++ {
+		w, g := at(outboundLines(want.Outbound), i), at(outboundLines(got.Outbound), i)
+		if w != g {
+			lines = append(lines, fmt.Sprintf("outbound %d: want %s, got %s", i+1, w, g))
+		}
+	}
+	for i := 0; i < max(len(want.Session), len(got.Session)); i++ {
+		w, g := at(entryLines(want.Session), i), at(entryLines(got.Session), i)
+		if w != g {
+			lines = append(lines, fmt.Sprintf("session entry %d: want %s, got %s", i+1, w, g))
+		}
+	}
+	return lines
+}
+
+func chatToolResults(exchanges []Exchange) []string {
+	var out []string
+	for _, ex := range exchanges {
+		for _, result := range ex.ToolResults {
+			line := fmt.Sprintf("%s %q", result.ToolUseID, result.Content)
+			if result.IsError {
+				line += " (error)"
+			}
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+func outboundLines(messages []Outbound) []string {
+	out := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		out = append(out, fmt.Sprintf("%s chat %d %q", msg.Method, msg.ChatID, msg.Text))
+	}
+	return out
+}
+
+func entryLines(entries []Entry) []string {
+	out := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, fmt.Sprintf("%s %q", entry.Role, entry.Content))
+	}
+	return out
+}
+
+func at(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return "(none)"
+}
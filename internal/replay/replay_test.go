@@ -0,0 +1,119 @@
+package replay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mouse/internal/config"
+	"mouse/internal/fakellm"
+	"mouse/internal/faketelegram"
+	"mouse/internal/gateway"
+	"mouse/internal/logging"
+	"mouse/internal/sessions"
+	"mouse/internal/telegram"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	llmServer := httptest.NewServer(fakellm.New(
+		fakellm.Step{Match: "weather", ToolUse: []fakellm.ToolUse{{ID: "toolu_1", Name: "send_file", Input: map[string]any{"path": "forecast.txt"}}}},
+		fakellm.Step{Text: "Rain all day."},
+	))
+	defer llmServer.Close()
+	tg := faketelegram.New()
+	tgServer := httptest.NewServer(tg)
+	defer tgServer.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.App.Workspace = dir
+	cfg.Index.SQLitePath = filepath.Join(dir, "mouse.db")
+	cfg.Sessions.Dir = filepath.Join(dir, "sessions")
+	cfg.Memory.Dir = filepath.Join(dir, "memory")
+	cfg.Telegram = config.TelegramConfig{
+		Enabled:   true,
+		BotToken:  "t0ken",
+		AllowFrom: []string{"42"},
+		Webhook:   config.WebhookConfig{Path: "/telegram-webhook"},
+	}
+	cfg.LLM = config.LLMConfig{Provider: "anthropic", APIKey: "k", Model: "claude-test", MaxTokens: 128, BaseURL: llmServer.URL}
+
+	store, err := sessions.NewStore(cfg.Sessions.Dir)
+	if err != nil {
+		t.Fatalf("sessions: %v", err)
+	}
+	fixturePath := filepath.Join(dir, "fixtures", "weather.json")
+	recorder, err := NewRecorder(fixturePath, store, nil)
+	if err != nil {
+		t.Fatalf("recorder: %v", err)
+	}
+	recorded := *cfg
+	recorded.Telegram.APIBase, err = recorder.ListenProxy(tgServer.URL)
+	if err != nil {
+		t.Fatalf("proxy: %v", err)
+	}
+	logger := logging.New("replay-test")
+	server, err := gateway.NewServerWithHooks(&recorded, logger, gateway.Hooks{WrapLLM: recorder.WrapLLM, WrapProcessor: recorder.WrapProcessor})
+	if err != nil {
+		t.Fatalf("gateway: %v", err)
+	}
+	gw := httptest.NewServer(server.Handler())
+	defer gw.Close()
+	ctx := context.Background()
+	if err := telegram.SetWebhook(ctx, tgServer.URL, "t0ken", gw.URL, "/telegram-webhook", "", nil); err != nil {
+		t.Fatalf("webhook: %v", err)
+	}
+	update := telegram.Update{Message: &telegram.Message{From: &telegram.User{ID: 42}, Chat: &telegram.Chat{ID: 42}, Text: "weather today?"}}
+	if status, err := tg.Deliver(ctx, update); err != nil || status != http.StatusOK {
+		t.Fatalf("deliver: %d %v", status, err)
+	}
+
+	fixture, err := Load(fixturePath)
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	turn := fixture.Turns[0]
+	if len(turn.LLM) != 2 || len(turn.LLM[1].ToolResults) != 1 || !turn.LLM[1].ToolResults[0].IsError {
+		t.Fatalf("unexpected llm exchanges %+v", turn.LLM)
+	}
+	if len(turn.Outbound) != 1 || turn.Outbound[0].Text != "Rain all day." {
+		t.Fatalf("unexpected outbound %+v", turn.Outbound)
+	}
+	if len(turn.Session) != 3 || turn.Session[0].Role != "user" || turn.Session[1].Role != "tool" || turn.Session[2].Content != "Rain all day." {
+		t.Fatalf("unexpected session entries %+v", turn.Session)
+	}
+
+	report, err := Run(ctx, cfg, fixture, logger)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if !report.OK() {
+		t.Fatalf("expected clean replay, got\n%s", report.Format())
+	}
+
+	fixture.Turns[0].LLM[1].ToolResults[0] = ToolResult{ToolUseID: "toolu_1", Content: "sent forecast.txt"}
+	fixture.Turns[0].Session[1].Content = "send_file: sent forecast.txt"
+	report, err = Run(ctx, cfg, fixture, logger)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if !report.OK() {
+		t.Fatalf("expected recorded tool result to be served, got\n%s", report.Format())
+	}
+
+	fixture.Turns[0].LLM[1].Response.Text = "Sunny."
+	report, err = Run(ctx, cfg, fixture, logger)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	out := report.Format()
+	if report.OK() || !strings.Contains(out, `outbound 1: want sendMessage chat 42 "Rain all day.", got sendMessage chat 42 "Sunny."`) {
+		t.Fatalf("expected outbound diff, got\n%s", out)
+	}
+	if !strings.Contains(out, "session entry 3") {
+		t.Fatalf("expected session diff, got\n%s", out)
+	}
+}
@@ -0,0 +1,189 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"mouse/internal/sqlite"
+)
+
+const (
+	ExportJSONL     = "jsonl"
+	ExportHTML      = "html"
+	ExportAnthropic = "anthropic"
+)
+
+var exportFormats = map[string]string{
+	ExportJSONL:     "application/x-ndjson",
+	ExportHTML:      "text/html; charset=utf-8",
+	ExportAnthropic: "application/json",
+}
+
+func ExportContentType(format string) (string, bool) {
+	contentType, ok := exportFormats[format]
+	return contentType, ok
+}
+
+func SummarizedEntries(ctx context.Context, db *sqlite.DB, sessionID string) (int, error) {
+	summary, err := db.LatestSessionSummary(ctx, sessionID)
+	if err != nil || summary == nil {
+		return 0, err
+	}
+	return db.CountSessionMessagesThrough(ctx, sessionID, summary.ThroughID)
+}
+
+func Export(w io.Writer, transcript *Transcript, format string, summarized int) error {
+	switch format {
+	case ExportJSONL:
+		return exportJSONL(w, transcript)
+	case ExportHTML:
+		return exportHTML(w, transcript)
+	case ExportAnthropic:
+		return exportAnthropic(w, transcript, summarized)
+	default:
+		return fmt.Errorf("sessions: unsupported export format %q", format)
+	}
+}
+
+type jsonlLine struct {
+	SessionID string `json:"session_id"`
+	Index     int    `json:"index"`
+	Timestamp string `json:"timestamp"`
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+}
+
+func exportJSONL(w io.Writer, transcript *Transcript) error {
+	enc := json.NewEncoder(w)
+	for _, entry := range transcript.Entries {
+		line := jsonlLine{
+			SessionID: transcript.ID,
+			Index:     entry.Index,
+			Timestamp: entry.Timestamp.UTC().Format(time.RFC3339Nano),
+			Role:      entry.Role,
+			Content:   entry.Content,
+		}
+		if err := enc.Encode(line); err != nil {
+			return fmt.Errorf("sessions: export jsonl: %w", err)
+		}
+	}
+	return nil
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicTranscript struct {
+	System   string             `json:"system,omitempty"`
+	Messages []anthropicMessage `json:"messages"`
+}
+
+func exportAnthropic(w io.Writer, transcript *Transcript, summarized int) error {
+	out := anthropicTranscript{Messages: []anthropicMessage{}}
+	for i, entry := range transcript.Entries {
+		content := strings.TrimSpace(entry.Content)
+		if content == "" {
+			continue
+		}
+		if entry.Role == "summary" {
+			out.System = "Summary of the earlier conversation:\n" + content
+			continue
+		}
+		if i < summarized {
+			continue
+		}
+		var role string
+		switch entry.Role {
+		case "assistant":
+			role = "assistant"
+		case "user", "system":
+			role = "user"
+		default:
+			continue
+		}
+		if len(out.Messages) == 0 && role != "user" {
+			continue
+		}
+		if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == role {
+			out.Messages[n-1].Content += "\n\n" + content
+			continue
+		}
+		out.Messages = append(out.Messages, anthropicMessage{Role: role, Content: content})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("sessions: export anthropic: %w", err)
+	}
+	return nil
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Session {{.ID}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; }
+h1 { font-size: 1.4rem; }
+.entry { border-left: 3px solid #d0d7de; margin: 1rem 0; padding: 0.25rem 0.75rem; }
+.entry.user { border-color: #0969da; }
+.entry.assistant { border-color: #1a7f37; }
+.entry.system, .entry.summary { border-color: #9a6700; background: #fff8c5; }
+.meta { font-size: 0.8rem; color: #656d76; }
+pre { white-space: pre-wrap; word-wrap: break-word; font-family: inherit; margin: 0.25rem 0; }
+</style>
+</head>
+<body>
+<h1>Session {{.ID}}</h1>
+<p class="meta">Exported {{.Exported}} &middot; {{len .Entries}} entries</p>
+{{range .Entries}}<div class="entry {{.Role}}">
+<div class="meta">#{{.Index}} &middot; {{.Role}} &middot; {{.Timestamp}}</div>
+<pre>{{.Content}}</pre>
+</div>
+{{end}}</body>
+</html>
+`))
+
+type htmlEntry struct {
+	Index     int
+	Role      string
+	Timestamp string
+	Content   string
+}
+
+type htmlPage struct {
+	ID       string
+	Exported string
+	Entries  []htmlEntry
+}
+
+func exportHTML(w io.Writer, transcript *Transcript) error {
+	page := htmlPage{
+		ID:       transcript.ID,
+		Exported: time.Now().UTC().Format(time.RFC3339),
+		Entries:  make([]htmlEntry, 0, len(transcript.Entries)),
+	}
+	for _, entry := range transcript.Entries {
+		page.Entries = append(page.Entries, htmlEntry{
+			Index:     entry.Index,
+			Role:      entry.Role,
+			Timestamp: entry.Timestamp.UTC().Format(time.RFC3339),
+			Content:   entry.Content,
+		})
+	}
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, page); err != nil {
+		return fmt.Errorf("sessions: export html: %w", err)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,62 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportFixture() *Transcript {
+	ts := time.Date(2026, 2, 3, 8, 0, 0, 0, time.UTC)
+	return &Transcript{
+		ID: "42",
+		Entries: []Entry{
+			{Index: 0, Timestamp: ts, Role: "assistant", Content: "dangling"},
+			{Index: 1, Timestamp: ts, Role: "user", Content: "<script>alert(1)</script>"},
+			{Index: 2, Timestamp: ts, Role: "system", Content: "cron prompt"},
+			{Index: 3, Timestamp: ts, Role: "assistant", Content: "reply"},
+		},
+	}
+}
+
+func TestExportAnthropicAlternatesRoles(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, exportFixture(), ExportAnthropic, 0); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	var parsed anthropicTranscript
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(parsed.Messages) != 2 {
+		t.Fatalf("expected 2 messages, got %+v", parsed.Messages)
+	}
+	if parsed.Messages[0].Role != "user" || !strings.Contains(parsed.Messages[0].Content, "cron prompt") {
+		t.Fatalf("unexpected first message: %+v", parsed.Messages[0])
+	}
+}
+
+func TestExportHTMLEscapesContent(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, exportFixture(), ExportHTML, 0); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if strings.Contains(buf.String(), "<script>") {
+		t.Fatalf("expected content to be escaped")
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, exportFixture(), ExportJSONL, 0); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 4 {
+		t.Fatalf("expected 4 lines, got %d", lines)
+	}
+	if err := Export(&buf, exportFixture(), "pdf", 0); err == nil {
+		t.Fatalf("expected unsupported format error")
+	}
+}
@@ -0,0 +1,123 @@
+package sessions
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	FormatVersion = 2
+	versionMarker = "<!-- mouse:session v2 -->"
+	modelPrefix   = "<!-- mouse:model "
+	commentSuffix = " -->"
+)
+
+func formatHeader(sessionID string) string {
+	id := strings.Join(strings.Fields(sessionID), " ")
+	return fmt.Sprintf("# Session %s\n\n%s\n\n", id, versionMarker)
+}
+
+func formatEntry(ts time.Time, role, content string) string {
+	return formatEntryModel(ts, role, content, "")
+}
+
+func formatEntryModel(ts time.Time, role, content, model string) string {
+	content = strings.TrimSpace(content)
+	fence := fenceFor(content)
+	meta := ""
+	if model = strings.Join(strings.Fields(model), ""); model != "" && !strings.Contains(model, "--") {
+		meta = modelPrefix + model + commentSuffix + "\n"
+	}
+	return fmt.Sprintf("## %s %s\n\n%s%s\n%s\n%s\n\n", ts.UTC().Format(time.RFC3339Nano), normalizeRole(role), meta, fence, content, fence)
+}
+
+func parseModel(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, modelPrefix) || !strings.HasSuffix(line, commentSuffix) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, modelPrefix), commentSuffix)), true
+}
+
+func fenceFor(content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+			continue
+		}
+		run = 0
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+func normalizeRole(role string) string {
+	fields := strings.Fields(strings.ToLower(role))
+	if len(fields) == 0 {
+		return "user"
+	}
+	return strings.Join(fields, "-")
+}
+
+func writeTranscript(path string, transcript *Transcript) error {
+	var b strings.Builder
+	b.WriteString(formatHeader(transcript.ID))
+	for _, entry := range transcript.Entries {
+		b.WriteString(formatEntryModel(entry.Timestamp, entry.Role, entry.Content, entry.Model))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("sessions: create dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".session-*")
+	if err != nil {
+		return fmt.Errorf("sessions: create temp: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.WriteString(tmp, b.String()); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("sessions: write: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("sessions: close: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("sessions: chmod: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("sessions: rename: %w", err)
+	}
+	return nil
+}
+
+func fileVersion(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, fmt.Errorf("sessions: read header: %w", err)
+	}
+	for _, line := range strings.Split(string(head[:n]), "\n") {
+		line = strings.TrimSpace(line)
+		if line == versionMarker {
+			return FormatVersion, nil
+		}
+		if strings.HasPrefix(line, "## ") {
+			break
+		}
+	}
+	return 1, nil
+}
@@ -0,0 +1,300 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mouse/internal/logging"
+	"mouse/internal/sqlite"
+)
+
+type Handler struct {
+	store      *Store
+	db         *sqlite.DB
+	reconciler *Reconciler
+	lifecycle  *Lifecycle
+	logger     *logging.Logger
+}
+
+type resetRequest struct {
+	ID string `json:"id"`
+}
+
+type forkRequest struct {
+	ID    string `json:"id"`
+	Index int    `json:"index"`
+	NewID string `json:"new_id"`
+}
+
+type lifecycleResponse struct {
+	OK         bool   `json:"ok"`
+	ArchivedID string `json:"archived_id,omitempty"`
+	ForkID     string `json:"fork_id,omitempty"`
+}
+
+type listResponse struct {
+	Sessions []Info `json:"sessions"`
+}
+
+type entriesResponse struct {
+	ID      string  `json:"id"`
+	Total   int     `json:"total"`
+	Entries []Entry `json:"entries"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewHandler(store *Store, db *sqlite.DB, reconciler *Reconciler, lifecycle *Lifecycle, logger *logging.Logger) *Handler {
+	return &Handler{store: store, db: db, reconciler: reconciler, lifecycle: lifecycle, logger: logger}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		writeError(w, http.StatusServiceUnavailable, "sessions store not configured")
+		return
+	}
+	switch strings.TrimPrefix(r.URL.Path, "/sessions/") {
+	case "list":
+		h.handleList(w, r)
+	case "show":
+		h.handleShow(w, r)
+	case "tail":
+		h.handleTail(w, r)
+	case "reconcile":
+		h.handleReconcile(w, r)
+	case "export":
+		h.handleExport(w, r)
+	case "reset":
+		h.handleReset(w, r)
+	case "fork":
+		h.handleFork(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	list := h.store.List
+	if archived, _ := strconv.ParseBool(r.URL.Query().Get("archived")); archived {
+		list = h.store.ListArchived
+	}
+	infos, err := list()
+	if err != nil {
+		h.logError("sessions list failed", err)
+		writeError(w, http.StatusInternalServerError, "list failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, listResponse{Sessions: infos})
+}
+
+func (h *Handler) handleShow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if strings.TrimSpace(id) == "" {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+	rng := Range{
+		Offset: queryInt(r, "offset", 0),
+		Limit:  queryInt(r, "limit", 0),
+	}
+	entries, total, err := h.store.Read(id, rng)
+	if err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entriesResponse{ID: id, Total: total, Entries: entries})
+}
+
+func (h *Handler) handleTail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if strings.TrimSpace(id) == "" {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+	entries, err := h.store.Tail(id, queryInt(r, "n", 10))
+	if err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entriesResponse{ID: id, Total: len(entries), Entries: entries})
+}
+
+func (h *Handler) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.reconciler == nil {
+		writeError(w, http.StatusServiceUnavailable, "reconciler not configured")
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	report, err := h.reconciler.Reconcile(r.Context(), dryRun)
+	if err != nil {
+		h.logError("sessions reconcile failed", err)
+		writeError(w, http.StatusInternalServerError, "reconcile failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if strings.TrimSpace(id) == "" {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ExportJSONL
+	}
+	contentType, ok := ExportContentType(format)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "unsupported format")
+		return
+	}
+	transcript, err := h.store.Load(id)
+	if errors.Is(err, ErrNotFound) {
+		transcript, err = h.store.LoadArchived(id)
+	}
+	if err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	summarized := 0
+	if h.db != nil {
+		summarized, err = SummarizedEntries(r.Context(), h.db, id)
+		if err != nil {
+			h.logError("sessions export failed", err)
+			writeError(w, http.StatusInternalServerError, "export failed")
+			return
+		}
+	}
+	var buf bytes.Buffer
+	if err := Export(&buf, transcript, format, summarized); err != nil {
+		h.logError("sessions export failed", err)
+		writeError(w, http.StatusInternalServerError, "export failed")
+		return
+	}
+	w.Header().Set("content-type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (h *Handler) handleReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.lifecycle == nil {
+		writeError(w, http.StatusServiceUnavailable, "session lifecycle not configured")
+		return
+	}
+	var req resetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if strings.TrimSpace(req.ID) == "" {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+	archivedID, err := h.lifecycle.Reset(r.Context(), req.ID)
+	if err != nil {
+		h.writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, lifecycleResponse{OK: true, ArchivedID: archivedID})
+}
+
+func (h *Handler) handleFork(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.lifecycle == nil {
+		writeError(w, http.StatusServiceUnavailable, "session lifecycle not configured")
+		return
+	}
+	var req forkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if strings.TrimSpace(req.ID) == "" {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+	forkID, err := h.lifecycle.Fork(r.Context(), req.ID, req.Index, strings.TrimSpace(req.NewID))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			h.writeStoreError(w, err)
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, lifecycleResponse{OK: true, ForkID: forkID})
+}
+
+func (h *Handler) writeStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	h.logError("sessions read failed", err)
+	writeError(w, http.StatusInternalServerError, "read failed")
+}
+
+func (h *Handler) logError(msg string, err error) {
+	if h.logger == nil {
+		return
+	}
+	h.logger.Error(msg, map[string]string{
+		"error": err.Error(),
+	})
+}
+
+func queryInt(r *http.Request, name string, fallback int) int {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return fallback
+	}
+	val, err := strconv.Atoi(raw)
+	if err != nil || val < 0 {
+		return fallback
+	}
+	return val
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,211 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mouse/internal/config"
+	"mouse/internal/logging"
+	"mouse/internal/sqlite"
+)
+
+type Lifecycle struct {
+	store        *Store
+	db           *sqlite.DB
+	logger       *logging.Logger
+	archiveAfter time.Duration
+	deleteAfter  time.Duration
+	interval     time.Duration
+	started      atomic.Bool
+	running      sync.WaitGroup
+}
+
+type RetentionReport struct {
+	Archived []string `json:"archived"`
+	Deleted  []string `json:"deleted"`
+}
+
+func NewLifecycle(cfg config.RetentionConfig, store *Store, db *sqlite.DB, logger *logging.Logger) (*Lifecycle, error) {
+	if store == nil {
+		return nil, errors.New("sessions: store is required")
+	}
+	if db == nil {
+		return nil, errors.New("sessions: db is required")
+	}
+	return &Lifecycle{
+		store:        store,
+		db:           db,
+		logger:       logger,
+		archiveAfter: time.Duration(cfg.ArchiveAfterDays) * 24 * time.Hour,
+		deleteAfter:  time.Duration(cfg.DeleteAfterDays) * 24 * time.Hour,
+		interval:     time.Hour,
+	}, nil
+}
+
+func (l *Lifecycle) Start(ctx context.Context) {
+	if l == nil || (l.archiveAfter <= 0 && l.deleteAfter <= 0) {
+		return
+	}
+	if !l.started.CompareAndSwap(false, true) {
+		return
+	}
+	l.running.Add(1)
+	go func() {
+		defer l.running.Done()
+		l.retainAndLog(ctx)
+		ticker := time.NewTicker(l.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				l.retainAndLog(ctx)
+			}
+		}
+	}()
+}
+
+func (l *Lifecycle) Wait() {
+	if l == nil {
+		return
+	}
+	l.running.Wait()
+}
+
+func (l *Lifecycle) Reset(ctx context.Context, sessionID string) (string, error) {
+	l.store.lifecycle.Lock()
+	defer l.store.lifecycle.Unlock()
+	return l.reset(ctx, sessionID)
+}
+
+func (l *Lifecycle) reset(ctx context.Context, sessionID string) (string, error) {
+	archivedID := archiveID(sessionID, time.Now())
+	moved, err := l.db.RenameSession(ctx, sessionID, archivedID)
+	if err != nil {
+		return "", err
+	}
+	if err := l.store.Archive(sessionID, archivedID); err != nil && !(errors.Is(err, ErrNotFound) && moved > 0) {
+		if _, undoErr := l.db.RenameSession(ctx, archivedID, sessionID); undoErr != nil {
+			return "", errors.Join(err, undoErr)
+		}
+		return "", err
+	}
+	if l.logger != nil {
+		l.logger.Info("session archived", map[string]string{
+			"session_id":  sessionID,
+			"archived_id": archivedID,
+		})
+	}
+	return archivedID, nil
+}
+
+func (l *Lifecycle) Fork(ctx context.Context, sessionID string, index int, newID string) (string, error) {
+	if newID == "" {
+		newID = fmt.Sprintf("%s-fork-%s", sessionID, time.Now().UTC().Format("20060102T150405Z"))
+	}
+	l.store.lifecycle.Lock()
+	defer l.store.lifecycle.Unlock()
+	fork, err := l.store.Fork(sessionID, index, newID)
+	if err != nil {
+		return "", err
+	}
+	messages := make([]sqlite.SessionMessage, 0, len(fork.Entries))
+	for _, entry := range fork.Entries {
+		messages = append(messages, sqlite.SessionMessage{
+			SessionID: newID,
+			Role:      entry.Role,
+			Content:   entry.Content,
+			Model:     entry.Model,
+			CreatedAt: entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	if err := l.db.ReplaceSessionMessages(ctx, newID, messages); err != nil {
+		return newID, err
+	}
+	if err := l.db.CopySessionSummaries(ctx, sessionID, newID); err != nil {
+		return newID, err
+	}
+	if l.logger != nil {
+		l.logger.Info("session forked", map[string]string{
+			"session_id": sessionID,
+			"fork_id":    newID,
+			"index":      strconv.Itoa(index),
+		})
+	}
+	return newID, nil
+}
+
+func (l *Lifecycle) ApplyRetention(ctx context.Context, now time.Time) (RetentionReport, error) {
+	l.store.lifecycle.Lock()
+	defer l.store.lifecycle.Unlock()
+	var report RetentionReport
+	if l.archiveAfter > 0 {
+		active, err := l.store.List()
+		if err != nil {
+			return report, err
+		}
+		for _, info := range active {
+			if !olderThan(info.UpdatedAt, now, l.archiveAfter) {
+				continue
+			}
+			archivedID, err := l.reset(ctx, info.ID)
+			if err != nil {
+				return report, err
+			}
+			report.Archived = append(report.Archived, archivedID)
+		}
+	}
+	if l.deleteAfter > 0 {
+		archived, err := l.store.ListArchived()
+		if err != nil {
+			return report, err
+		}
+		for _, info := range archived {
+			if !olderThan(info.UpdatedAt, now, l.deleteAfter) {
+				continue
+			}
+			if err := l.store.RemoveArchived(info.ID); err != nil {
+				return report, err
+			}
+			if err := l.db.DeleteSession(ctx, info.ID); err != nil {
+				return report, err
+			}
+			report.Deleted = append(report.Deleted, info.ID)
+		}
+	}
+	return report, nil
+}
+
+func (l *Lifecycle) retainAndLog(ctx context.Context) {
+	report, err := l.ApplyRetention(ctx, time.Now())
+	if l.logger == nil {
+		return
+	}
+	if err != nil {
+		l.logger.Error("session retention failed", map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	if len(report.Archived)+len(report.Deleted) == 0 {
+		return
+	}
+	l.logger.Info("session retention applied", map[string]string{
+		"archived": strconv.Itoa(len(report.Archived)),
+		"deleted":  strconv.Itoa(len(report.Deleted)),
+	})
+}
+
+func olderThan(updatedAt string, now time.Time, age time.Duration) bool {
+	ts, err := time.Parse(time.RFC3339Nano, updatedAt)
+	if err != nil {
+		return false
+	}
+	return now.Sub(ts) > age
+}
@@ -0,0 +1,172 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"mouse/internal/config"
+	"mouse/internal/sqlite"
+)
+
+func TestLifecycleResetForkAndRetention(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, _ := NewStore(filepath.Join(dir, "sessions"))
+	lifecycle, err := NewLifecycle(config.RetentionConfig{ArchiveAfterDays: 1, DeleteAfterDays: 2}, store, db, nil)
+	if err != nil {
+		t.Fatalf("new lifecycle: %v", err)
+	}
+	ctx := context.Background()
+	for _, text := range []string{"one", "two", "three"} {
+		if _, err := store.Append("5", "user", text); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		if _, err := db.AppendSessionMessage(ctx, "5", "user", text); err != nil {
+			t.Fatalf("sqlite append: %v", err)
+		}
+	}
+
+	forkID, err := lifecycle.Fork(ctx, "5", 1, "5-alt")
+	if err != nil {
+		t.Fatalf("fork: %v", err)
+	}
+	forked, _ := store.Load(forkID)
+	rows, _ := db.ListSessionMessagesAfter(ctx, forkID, 0)
+	if len(forked.Entries) != 2 || len(rows) != 2 {
+		t.Fatalf("expected fork with 2 entries, got %d markdown %d sqlite", len(forked.Entries), len(rows))
+	}
+
+	archivedID, err := lifecycle.Reset(ctx, "5")
+	if err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if _, err := store.Load("5"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected active session gone, got %v", err)
+	}
+	rows, _ = db.ListSessionMessagesAfter(ctx, archivedID, 0)
+	if len(rows) != 3 {
+		t.Fatalf("expected sqlite rows moved to archive id, got %d", len(rows))
+	}
+
+	report, err := lifecycle.ApplyRetention(ctx, time.Now().Add(36*time.Hour))
+	if err != nil {
+		t.Fatalf("retention: %v", err)
+	}
+	if len(report.Archived) != 1 || len(report.Deleted) != 0 {
+		t.Fatalf("expected fork archived only, got %+v", report)
+	}
+	report, err = lifecycle.ApplyRetention(ctx, time.Now().Add(72*time.Hour))
+	if err != nil {
+		t.Fatalf("retention: %v", err)
+	}
+	if len(report.Deleted) != 2 {
+		t.Fatalf("expected both archives deleted, got %+v", report)
+	}
+	if ids, _ := db.ListSessionIDs(ctx); len(ids) != 0 {
+		t.Fatalf("expected sqlite emptied, got %v", ids)
+	}
+}
+
+func TestResetMovesSQLiteOnlyRows(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, _ := NewStore(filepath.Join(dir, "sessions"))
+	lifecycle, err := NewLifecycle(config.RetentionConfig{}, store, db, nil)
+	if err != nil {
+		t.Fatalf("new lifecycle: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := lifecycle.Reset(ctx, "7"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found for empty session, got %v", err)
+	}
+	if _, err := db.AppendSessionMessage(ctx, "7", "user", "only in sqlite"); err != nil {
+		t.Fatalf("sqlite append: %v", err)
+	}
+	archivedID, err := lifecycle.Reset(ctx, "7")
+	if err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	rows, _ := db.ListSessionMessagesAfter(ctx, "7", 0)
+	if len(rows) != 0 {
+		t.Fatalf("expected active rows cleared, got %d", len(rows))
+	}
+	rows, _ = db.ListSessionMessagesAfter(ctx, archivedID, 0)
+	if len(rows) != 1 {
+		t.Fatalf("expected rows moved to %s, got %d", archivedID, len(rows))
+	}
+}
+
+func TestResetAndReconcileDoNotRace(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, _ := NewStore(filepath.Join(dir, "sessions"))
+	lifecycle, _ := NewLifecycle(config.RetentionConfig{}, store, db, nil)
+	reconciler, _ := NewReconciler(store, db, nil)
+	reconciler.settle = 0
+	ctx := context.Background()
+	ids := make([]string, 20)
+	for i := range ids {
+		ids[i] = strconv.Itoa(100 + i)
+		for _, text := range []string{"one", "two"} {
+			if _, err := store.Append(ids[i], "user", text); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+			if _, err := db.AppendSessionMessage(ctx, ids[i], "user", text); err != nil {
+				t.Fatalf("sqlite append: %v", err)
+			}
+		}
+	}
+
+	done := make(chan struct{})
+	reconciled := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-done:
+				reconciled <- nil
+				return
+			default:
+			}
+			if _, err := reconciler.Reconcile(ctx, false); err != nil {
+				reconciled <- err
+				return
+			}
+		}
+	}()
+	archived := make([]string, len(ids))
+	for i, id := range ids {
+		archivedID, err := lifecycle.Reset(ctx, id)
+		if err != nil {
+			t.Fatalf("reset %s: %v", id, err)
+		}
+		archived[i] = archivedID
+	}
+	close(done)
+	if err := <-reconciled; err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	for i, id := range ids {
+		if rows, _ := db.ListSessionMessagesAfter(ctx, id, 0); len(rows) != 0 {
+			t.Fatalf("session %s: expected no live rows, got %d", id, len(rows))
+		}
+		if rows, _ := db.ListSessionMessagesAfter(ctx, archived[i], 0); len(rows) != 2 {
+			t.Fatalf("session %s: expected archived rows kept, got %d", id, len(rows))
+		}
+	}
+}
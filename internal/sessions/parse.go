@@ -0,0 +1,121 @@
+package sessions
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type Entry struct {
+	Index     int       `json:"index"`
+	Timestamp time.Time `json:"timestamp"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Model     string    `json:"model,omitempty"`
+}
+
+type Transcript struct {
+	ID      string  `json:"id"`
+	Version int     `json:"version"`
+	Entries []Entry `json:"entries"`
+}
+
+func Parse(r io.Reader) (*Transcript, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	transcript := &Transcript{Version: 1}
+	var (
+		current *Entry
+		body    []string
+		fence   string
+		inBody  bool
+		fenced  bool
+	)
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Content = strings.TrimSpace(strings.Join(body, "\n"))
+		transcript.Entries = append(transcript.Entries, *current)
+		current = nil
+		body = nil
+		fence = ""
+		inBody = false
+		fenced = false
+	}
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if inBody {
+			if line == fence {
+				inBody = false
+				fenced = true
+				continue
+			}
+			body = append(body, line)
+			continue
+		}
+		if current == nil && len(transcript.Entries) == 0 {
+			if transcript.ID == "" && strings.HasPrefix(line, "# Session ") {
+				transcript.ID = strings.TrimSpace(strings.TrimPrefix(line, "# Session "))
+				continue
+			}
+			if strings.TrimSpace(line) == versionMarker {
+				transcript.Version = FormatVersion
+				continue
+			}
+		}
+		if ts, role, ok := parseHeading(line); ok {
+			flush()
+			current = &Entry{Index: len(transcript.Entries), Timestamp: ts, Role: role}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if transcript.Version >= 2 && !fenced && len(body) == 0 {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if model, ok := parseModel(line); ok {
+				current.Model = model
+				continue
+			}
+			if isFence(line) {
+				fence = line
+				inBody = true
+				continue
+			}
+		}
+		body = append(body, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("sessions: parse: %w", err)
+	}
+	if inBody {
+		return nil, fmt.Errorf("sessions: parse: unterminated entry %d", len(transcript.Entries))
+	}
+	flush()
+	return transcript, nil
+}
+
+func parseHeading(line string) (time.Time, string, bool) {
+	if !strings.HasPrefix(line, "## ") {
+		return time.Time{}, "", false
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, "## "))
+	if len(fields) != 2 {
+		return time.Time{}, "", false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, fields[0])
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return ts, strings.ToLower(fields[1]), true
+}
+
+func isFence(line string) bool {
+	return len(line) >= 3 && strings.Trim(line, "`") == ""
+}
@@ -0,0 +1,241 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mouse/internal/logging"
+	"mouse/internal/sqlite"
+)
+
+const (
+	StatusInSync   = "in_sync"
+	StatusDrift    = "drift"
+	StatusRebuilt  = "rebuilt"
+	StatusOrphaned = "orphaned"
+	StatusRemoved  = "removed"
+	StatusSkipped  = "skipped"
+)
+
+type Reconciler struct {
+	store   *Store
+	db      *sqlite.DB
+	logger  *logging.Logger
+	settle  time.Duration
+	mu      sync.Mutex
+	started atomic.Bool
+	running sync.WaitGroup
+}
+
+type Drift struct {
+	ID       string `json:"id"`
+	Markdown int    `json:"markdown"`
+	SQLite   int    `json:"sqlite"`
+	Status   string `json:"status"`
+}
+
+type ReconcileReport struct {
+	DryRun   bool    `json:"dry_run"`
+	Checked  int     `json:"checked"`
+	Drifted  int     `json:"drifted"`
+	Sessions []Drift `json:"sessions"`
+}
+
+func NewReconciler(store *Store, db *sqlite.DB, logger *logging.Logger) (*Reconciler, error) {
+	if store == nil {
+		return nil, errors.New("sessions: store is required")
+	}
+	if db == nil {
+		return nil, errors.New("sessions: db is required")
+	}
+	return &Reconciler{store: store, db: db, logger: logger, settle: time.Minute}, nil
+}
+
+func (r *Reconciler) Start(ctx context.Context, interval time.Duration) {
+	if r == nil || interval <= 0 {
+		return
+	}
+	if !r.started.CompareAndSwap(false, true) {
+		return
+	}
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.runAndLog(ctx, r.settle)
+			}
+		}
+	}()
+}
+
+func (r *Reconciler) Wait() {
+	if r == nil {
+		return
+	}
+	r.running.Wait()
+}
+
+func (r *Reconciler) RunOnce(ctx context.Context) {
+	r.runAndLog(ctx, 0)
+}
+
+func (r *Reconciler) Reconcile(ctx context.Context, dryRun bool) (ReconcileReport, error) {
+	return r.reconcile(ctx, dryRun, r.settle)
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, dryRun bool, settle time.Duration) (ReconcileReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store.lifecycle.Lock()
+	defer r.store.lifecycle.Unlock()
+
+	report := ReconcileReport{DryRun: dryRun}
+	infos, err := r.store.List()
+	if err != nil {
+		return report, err
+	}
+	archived, err := r.store.ListArchived()
+	if err != nil {
+		return report, err
+	}
+	infos = append(infos, archived...)
+	seen := make(map[string]struct{}, len(infos))
+	now := time.Now()
+	for _, info := range infos {
+		seen[info.ID] = struct{}{}
+		report.Checked++
+		if settle > 0 && recentlyUpdated(info.UpdatedAt, now, settle) {
+			report.Sessions = append(report.Sessions, Drift{ID: info.ID, Markdown: info.Entries, Status: StatusSkipped})
+			continue
+		}
+		drift, err := r.reconcileSession(ctx, info, dryRun)
+		if err != nil {
+			return report, err
+		}
+		if drift.Status != StatusInSync {
+			report.Drifted++
+		}
+		report.Sessions = append(report.Sessions, drift)
+	}
+
+	ids, err := r.db.ListSessionIDs(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		rows, err := r.db.ListSessionMessagesAfter(ctx, id, 0)
+		if err != nil {
+			return report, err
+		}
+		report.Checked++
+		report.Drifted++
+		drift := Drift{ID: id, SQLite: len(rows), Status: StatusOrphaned}
+		if !dryRun {
+			if err := r.db.DeleteSession(ctx, id); err != nil {
+				return report, err
+			}
+			drift.Status = StatusRemoved
+		}
+		report.Sessions = append(report.Sessions, drift)
+	}
+	return report, nil
+}
+
+func (r *Reconciler) reconcileSession(ctx context.Context, info Info, dryRun bool) (Drift, error) {
+	transcript, err := loadFile(info.Path)
+	if err != nil {
+		return Drift{}, err
+	}
+	rows, err := r.db.ListSessionMessagesAfter(ctx, info.ID, 0)
+	if err != nil {
+		return Drift{}, err
+	}
+	drift := Drift{ID: info.ID, Markdown: len(transcript.Entries), SQLite: len(rows), Status: StatusInSync}
+	if matches(transcript.Entries, rows) {
+		return drift, nil
+	}
+	drift.Status = StatusDrift
+	if dryRun {
+		return drift, nil
+	}
+	messages := make([]sqlite.SessionMessage, 0, len(transcript.Entries))
+	for _, entry := range transcript.Entries {
+		messages = append(messages, sqlite.SessionMessage{
+			SessionID: info.ID,
+			Role:      entry.Role,
+			Content:   entry.Content,
+			Model:     entry.Model,
+			CreatedAt: entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	if err := r.db.ReplaceSessionMessages(ctx, info.ID, messages); err != nil {
+		return drift, err
+	}
+	drift.Status = StatusRebuilt
+	return drift, nil
+}
+
+func (r *Reconciler) runAndLog(ctx context.Context, settle time.Duration) {
+	report, err := r.reconcile(ctx, false, settle)
+	if r.logger == nil {
+		return
+	}
+	if err != nil {
+		r.logger.Error("session reconcile failed", map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	for _, drift := range report.Sessions {
+		if drift.Status == StatusInSync || drift.Status == StatusSkipped {
+			continue
+		}
+		r.logger.Warn("session drift repaired", map[string]string{
+			"session_id": drift.ID,
+			"status":     drift.Status,
+			"markdown":   strconv.Itoa(drift.Markdown),
+			"sqlite":     strconv.Itoa(drift.SQLite),
+		})
+	}
+	r.logger.Info("session reconcile complete", map[string]string{
+		"checked": strconv.Itoa(report.Checked),
+		"drifted": strconv.Itoa(report.Drifted),
+	})
+}
+
+func matches(entries []Entry, rows []sqlite.SessionMessage) bool {
+	if len(entries) != len(rows) {
+		return false
+	}
+	for i, entry := range entries {
+		if entry.Role != strings.ToLower(rows[i].Role) {
+			return false
+		}
+		if entry.Content != strings.TrimSpace(rows[i].Content) {
+			return false
+		}
+	}
+	return true
+}
+
+func recentlyUpdated(updatedAt string, now time.Time, settle time.Duration) bool {
+	ts, err := time.Parse(time.RFC3339Nano, updatedAt)
+	if err != nil {
+		return false
+	}
+	return now.Sub(ts) < settle
+}
@@ -0,0 +1,147 @@
+package sessions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mouse/internal/sqlite"
+)
+
+func TestReconcileRebuildsFromMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, _ := NewStore(filepath.Join(dir, "sessions"))
+	reconciler, err := NewReconciler(store, db, nil)
+	if err != nil {
+		t.Fatalf("new reconciler: %v", err)
+	}
+	reconciler.settle = 0
+	ctx := context.Background()
+
+	if _, err := store.Append("1", "user", "hello"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if _, err := db.AppendSessionMessage(ctx, "1", "user", "hello"); err != nil {
+		t.Fatalf("sqlite append: %v", err)
+	}
+	if _, err := store.Append("1", "assistant", "hi"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if _, err := db.AppendSessionMessage(ctx, "ghost", "user", "orphan"); err != nil {
+		t.Fatalf("sqlite append: %v", err)
+	}
+
+	report, err := reconciler.Reconcile(ctx, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if report.Drifted != 2 {
+		t.Fatalf("expected 2 drifted sessions, got %+v", report)
+	}
+	rows, _ := db.ListSessionMessagesAfter(ctx, "1", 0)
+	if len(rows) != 1 {
+		t.Fatalf("dry run must not write, got %d rows", len(rows))
+	}
+
+	if _, err := reconciler.Reconcile(ctx, false); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	rows, _ = db.ListSessionMessagesAfter(ctx, "1", 0)
+	if len(rows) != 2 || rows[1].Role != "assistant" || rows[1].Content != "hi" {
+		t.Fatalf("unexpected rebuilt rows: %+v", rows)
+	}
+	ghost, _ := db.ListSessionMessagesAfter(ctx, "ghost", 0)
+	if len(ghost) != 0 {
+		t.Fatalf("expected orphaned session removed")
+	}
+
+	raw, _ := os.ReadFile(store.Path("1"))
+	if err := os.WriteFile(store.Path("1"), append(raw, []byte(formatEntry(time.Now(), "user", "hand edit"))...), 0o644); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	report, err = reconciler.Reconcile(ctx, false)
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if report.Drifted != 1 || report.Sessions[0].Status != StatusRebuilt {
+		t.Fatalf("expected hand edit to be rebuilt, got %+v", report)
+	}
+}
+
+func TestReconcileKeepsSummariesAndSkipsActiveSessions(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlite.Open(filepath.Join(dir, "mouse.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, _ := NewStore(filepath.Join(dir, "sessions"))
+	reconciler, err := NewReconciler(store, db, nil)
+	if err != nil {
+		t.Fatalf("new reconciler: %v", err)
+	}
+	ctx := context.Background()
+
+	var throughID int64
+	for _, text := range []string{"one", "two", "three"} {
+		if _, err := store.Append("1", "user", text); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		id, err := db.AppendSessionMessage(ctx, "1", "user", text)
+		if err != nil {
+			t.Fatalf("sqlite append: %v", err)
+		}
+		if text == "two" {
+			throughID = id
+		}
+	}
+	if _, err := db.InsertSessionSummary(ctx, "1", throughID, "one and two"); err != nil {
+		t.Fatalf("insert summary: %v", err)
+	}
+	if _, err := store.Append("1", "assistant", "lost in sqlite"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	report, err := reconciler.Reconcile(ctx, false)
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if report.Sessions[0].Status != StatusSkipped {
+		t.Fatalf("expected recently written session to be skipped, got %+v", report)
+	}
+
+	reconciler.settle = 0
+	if _, err := reconciler.Reconcile(ctx, false); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	rows, _ := db.ListSessionMessagesAfter(ctx, "1", 0)
+	if len(rows) != 4 || rows[1].ID != throughID {
+		t.Fatalf("expected matching rows to keep their ids, got %+v", rows)
+	}
+	summary, err := db.LatestSessionSummary(ctx, "1")
+	if err != nil || summary == nil || summary.ThroughID != throughID {
+		t.Fatalf("expected summary to survive reconcile, got %+v %v", summary, err)
+	}
+
+	raw, _ := os.ReadFile(store.Path("1"))
+	edited := strings.Replace(string(raw), "one", "uno", 1)
+	if err := os.WriteFile(store.Path("1"), []byte(edited), 0o644); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if _, err := reconciler.Reconcile(ctx, false); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	rows, _ = db.ListSessionMessagesAfter(ctx, "1", 0)
+	summary, err = db.LatestSessionSummary(ctx, "1")
+	if err != nil || summary == nil || summary.ThroughID != rows[1].ID || rows[1].Content != "two" {
+		t.Fatalf("expected summary remapped onto rebuilt rows, got %+v %+v %v", summary, rows, err)
+	}
+}
@@ -1,15 +1,34 @@
 package sessions
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
+var ErrNotFound = errors.New("session not found")
+
 type Store struct {
-	dir string
+	dir       string
+	mu        sync.Mutex
+	lifecycle sync.Mutex
+}
+
+type Info struct {
+	ID        string `json:"id"`
+	Path      string `json:"path"`
+	Entries   int    `json:"entries"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type Range struct {
+	Offset int
+	Limit  int
 }
 
 func NewStore(dir string) (*Store, error) {
@@ -20,17 +39,23 @@ func NewStore(dir string) (*Store, error) {
 }
 
 func (s *Store) Append(sessionID, role, content string) (string, error) {
+	return s.AppendModel(sessionID, role, content, "")
+}
+
+func (s *Store) AppendModel(sessionID, role, content, model string) (string, error) {
 	if strings.TrimSpace(sessionID) == "" {
 		sessionID = "unknown"
 	}
 	if strings.TrimSpace(role) == "" {
 		role = "user"
 	}
-	cleanID := sanitizeID(sessionID)
-	path := filepath.Join(s.dir, cleanID+".md")
+	path := s.Path(sessionID)
 	if err := os.MkdirAll(s.dir, 0o755); err != nil {
 		return path, fmt.Errorf("sessions: create dir: %w", err)
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	newFile := false
 	if _, err := os.Stat(path); err != nil {
 		if os.IsNotExist(err) {
@@ -39,6 +64,11 @@ func (s *Store) Append(sessionID, role, content string) (string, error) {
 			return path, fmt.Errorf("sessions: stat: %w", err)
 		}
 	}
+	if !newFile {
+		if _, err := migrateFile(path); err != nil {
+			return path, err
+		}
+	}
 
 	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
 	if err != nil {
@@ -47,20 +77,257 @@ func (s *Store) Append(sessionID, role, content string) (string, error) {
 	defer file.Close()
 
 	if newFile {
-		header := fmt.Sprintf("# Session %s\n\n", sessionID)
-		if _, err := file.WriteString(header); err != nil {
+		if _, err := file.WriteString(formatHeader(sessionID)); err != nil {
 			return path, fmt.Errorf("sessions: write header: %w", err)
 		}
 	}
 
-	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
-	entry := fmt.Sprintf("## %s %s\n\n%s\n\n", timestamp, strings.ToLower(role), strings.TrimSpace(content))
-	if _, err := file.WriteString(entry); err != nil {
+	if _, err := file.WriteString(formatEntryModel(time.Now(), role, content, model)); err != nil {
 		return path, fmt.Errorf("sessions: write entry: %w", err)
 	}
 	return path, nil
 }
 
+func (s *Store) Migrate() (int, error) {
+	items, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("sessions: read dir: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	migrated := 0
+	for _, item := range items {
+		if item.IsDir() || !strings.HasSuffix(item.Name(), ".md") {
+			continue
+		}
+		changed, err := migrateFile(filepath.Join(s.dir, item.Name()))
+		if err != nil {
+			return migrated, err
+		}
+		if changed {
+			migrated++
+		}
+	}
+	return migrated, nil
+}
+
+func migrateFile(path string) (bool, error) {
+	version, err := fileVersion(path)
+	if err != nil {
+		return false, err
+	}
+	if version >= FormatVersion {
+		return false, nil
+	}
+	transcript, err := loadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if transcript.ID == "" {
+		transcript.ID = strings.TrimSuffix(filepath.Base(path), ".md")
+	}
+	if err := writeTranscript(path, transcript); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) List() ([]Info, error) {
+	return listDir(s.dir)
+}
+
+func (s *Store) ListArchived() ([]Info, error) {
+	return listDir(s.archiveDir())
+}
+
+func archiveID(sessionID string, now time.Time) string {
+	return fmt.Sprintf("%s-%s", sessionID, now.UTC().Format("20060102T150405.000Z"))
+}
+
+func (s *Store) Archive(sessionID, archivedID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.Path(sessionID)
+	transcript, err := loadFile(path)
+	if err != nil {
+		return err
+	}
+	transcript.ID = archivedID
+	if err := writeTranscript(s.archivePath(archivedID), transcript); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		os.Remove(s.archivePath(archivedID))
+		return fmt.Errorf("sessions: remove archived source: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) LoadArchived(archivedID string) (*Transcript, error) {
+	transcript, err := loadFile(s.archivePath(archivedID))
+	if err != nil {
+		return nil, err
+	}
+	if transcript.ID == "" {
+		transcript.ID = archivedID
+	}
+	return transcript, nil
+}
+
+func (s *Store) RemoveArchived(archivedID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.archivePath(archivedID)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("sessions: %s: %w", archivedID, ErrNotFound)
+		}
+		return fmt.Errorf("sessions: remove archived: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Fork(sessionID string, index int, newID string) (*Transcript, error) {
+	if strings.TrimSpace(newID) == "" {
+		return nil, errors.New("sessions: fork id is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transcript, err := loadFile(s.Path(sessionID))
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(transcript.Entries) {
+		return nil, fmt.Errorf("sessions: fork index %d out of range (0-%d)", index, len(transcript.Entries)-1)
+	}
+	target := s.Path(newID)
+	if _, err := os.Stat(target); err == nil {
+		return nil, fmt.Errorf("sessions: session %q already exists", newID)
+	}
+	fork := &Transcript{ID: newID, Version: FormatVersion, Entries: append([]Entry(nil), transcript.Entries[:index+1]...)}
+	if err := writeTranscript(target, fork); err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
+
+func (s *Store) archiveDir() string {
+	return filepath.Join(s.dir, "archive")
+}
+
+func (s *Store) archivePath(archivedID string) string {
+	return filepath.Join(s.archiveDir(), sanitizeID(archivedID)+".md")
+}
+
+func listDir(dir string) ([]Info, error) {
+	items, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("sessions: read dir: %w", err)
+	}
+	var infos []Info
+	modTimes := make(map[string]time.Time, len(items))
+	for _, item := range items {
+		if item.IsDir() || !strings.HasSuffix(item.Name(), ".md") {
+			continue
+		}
+		path := filepath.Join(dir, item.Name())
+		transcript, err := loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		stat, err := item.Info()
+		if err != nil {
+			return nil, fmt.Errorf("sessions: stat: %w", err)
+		}
+		id := transcript.ID
+		if id == "" {
+			id = strings.TrimSuffix(item.Name(), ".md")
+		}
+		modTimes[path] = stat.ModTime()
+		infos = append(infos, Info{
+			ID:        id,
+			Path:      path,
+			Entries:   len(transcript.Entries),
+			UpdatedAt: stat.ModTime().UTC().Format(time.RFC3339Nano),
+		})
+	}
+	sort.Slice(infos, func(a, b int) bool {
+		return modTimes[infos[a].Path].After(modTimes[infos[b].Path])
+	})
+	return infos, nil
+}
+
+func (s *Store) Load(sessionID string) (*Transcript, error) {
+	transcript, err := loadFile(s.Path(sessionID))
+	if err != nil {
+		return nil, err
+	}
+	if transcript.ID == "" {
+		transcript.ID = sessionID
+	}
+	return transcript, nil
+}
+
+func (s *Store) Read(sessionID string, r Range) ([]Entry, int, error) {
+	transcript, err := s.Load(sessionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := len(transcript.Entries)
+	start := r.Offset
+	if start < 0 {
+		start = 0
+	}
+	if start > total {
+		start = total
+	}
+	end := total
+	if r.Limit > 0 && start+r.Limit < end {
+		end = start + r.Limit
+	}
+	return transcript.Entries[start:end], total, nil
+}
+
+func (s *Store) Tail(sessionID string, n int) ([]Entry, error) {
+	transcript, err := s.Load(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	entries := transcript.Entries
+	if n > 0 && len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return entries, nil
+}
+
+func loadFile(path string) (*Transcript, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("sessions: %s: %w", filepath.Base(path), ErrNotFound)
+		}
+		return nil, fmt.Errorf("sessions: open: %w", err)
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) Path(sessionID string) string {
+	return filepath.Join(s.dir, sanitizeID(sessionID)+".md")
+}
+
 func sanitizeID(id string) string {
 	mapped := strings.Map(func(r rune) rune {
 		if r >= 'a' && r <= 'z' {
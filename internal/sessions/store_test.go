@@ -0,0 +1,90 @@
+package sessions
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	raw := "# Session 42\n\n" +
+		"## 2026-02-03T08:00:00Z user\n\nhello\n\n## not a heading\n\n" +
+		"## 2026-02-03T08:00:01.5Z assistant\n\nhi there\n\n"
+	transcript, err := Parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if transcript.ID != "42" {
+		t.Fatalf("unexpected id: %q", transcript.ID)
+	}
+	if len(transcript.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(transcript.Entries))
+	}
+	if transcript.Entries[0].Content != "hello\n\n## not a heading" {
+		t.Fatalf("unexpected content: %q", transcript.Entries[0].Content)
+	}
+	if transcript.Entries[1].Role != "assistant" || transcript.Entries[1].Index != 1 {
+		t.Fatalf("unexpected entry: %+v", transcript.Entries[1])
+	}
+}
+
+func TestStoreReadAndTail(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	for _, text := range []string{"one", "two", "three"} {
+		if _, err := store.Append("Chat 9", "user", text); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	entries, total, err := store.Read("Chat 9", Range{Offset: 1, Limit: 1})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if total != 3 || len(entries) != 1 || entries[0].Content != "two" {
+		t.Fatalf("unexpected read result: %d %+v", total, entries)
+	}
+	tail, err := store.Tail("Chat 9", 2)
+	if err != nil {
+		t.Fatalf("tail: %v", err)
+	}
+	if len(tail) != 2 || tail[1].Content != "three" {
+		t.Fatalf("unexpected tail: %+v", tail)
+	}
+	infos, err := store.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(infos) != 1 || infos[0].ID != "Chat 9" || infos[0].Entries != 3 {
+		t.Fatalf("unexpected list: %+v", infos)
+	}
+	if _, err := store.Tail("missing", 1); err == nil {
+		t.Fatalf("expected error for missing session")
+	}
+}
+
+func TestListOrdersSameSecondByTime(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	base := time.Date(2026, 2, 3, 8, 0, 5, 0, time.UTC)
+	for i, id := range []string{"whole", "fraction"} {
+		if _, err := store.Append(id, "user", "hi"); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		stamp := base.Add(time.Duration(i) * 100 * time.Millisecond)
+		if err := os.Chtimes(store.Path(id), stamp, stamp); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	infos, err := store.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(infos) != 2 || infos[0].ID != "fraction" || infos[1].ID != "whole" {
+		t.Fatalf("expected newest first, got %+v", infos)
+	}
+}
@@ -23,6 +23,15 @@ type SessionMessage struct {
 	SessionID string
 	Role      string
 	Content   string
+	Model     string
+	CreatedAt string
+}
+
+type SessionSummary struct {
+	ID        int64
+	SessionID string
+	ThroughID int64
+	Content   string
 	CreatedAt string
 }
 
@@ -69,6 +78,14 @@ func migrate(db *sql.DB) error {
 			created_at TEXT NOT NULL
 		);`,
 		"CREATE INDEX IF NOT EXISTS idx_session_messages_session_id ON session_messages(session_id, id);",
+		`CREATE TABLE IF NOT EXISTS session_summaries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			through_id INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			created_at TEXT NOT NULL
+		);`,
+		"CREATE INDEX IF NOT EXISTS idx_session_summaries_session_id ON session_summaries(session_id, id);",
 		`CREATE TABLE IF NOT EXISTS memory_entries (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			key TEXT NOT NULL UNIQUE,
@@ -76,6 +93,11 @@ func migrate(db *sql.DB) error {
 			created_at TEXT NOT NULL,
 			updated_at TEXT NOT NULL
 		);`,
+		`CREATE TABLE IF NOT EXISTS memory_extract_state (
+			session_id TEXT PRIMARY KEY,
+			last_message_id INTEGER NOT NULL DEFAULT 0,
+			pending_since TEXT NOT NULL DEFAULT ''
+		);`,
 		`CREATE TABLE IF NOT EXISTS cron_jobs (
 			id TEXT PRIMARY KEY,
 			schedule TEXT NOT NULL,
@@ -84,6 +106,39 @@ func migrate(db *sql.DB) error {
 			enabled INTEGER NOT NULL,
 			updated_at TEXT NOT NULL
 		);`,
+		`CREATE TABLE IF NOT EXISTS llm_usage (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			job_id TEXT NOT NULL,
+			model TEXT NOT NULL,
+			input_tokens INTEGER NOT NULL,
+			output_tokens INTEGER NOT NULL,
+			cache_creation_tokens INTEGER NOT NULL,
+			cache_read_tokens INTEGER NOT NULL,
+			cost_usd REAL NOT NULL,
+			created_at TEXT NOT NULL
+		);`,
+		"CREATE INDEX IF NOT EXISTS idx_llm_usage_created_at ON llm_usage(created_at);",
+		`CREATE TABLE IF NOT EXISTS session_settings (
+			session_id TEXT PRIMARY KEY,
+			model TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS budget_overrides (
+			scope TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			expires_at TEXT NOT NULL,
+			created_by TEXT NOT NULL,
+			PRIMARY KEY (scope, subject)
+		);`,
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			name TEXT PRIMARY KEY,
+			hash TEXT NOT NULL UNIQUE,
+			scopes TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			last_used_at TEXT NOT NULL DEFAULT ''
+		);`,
 		`CREATE TABLE IF NOT EXISTS index_metadata (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			path TEXT NOT NULL UNIQUE,
@@ -102,10 +157,38 @@ func migrate(db *sql.DB) error {
 			return fmt.Errorf("sqlite: migrate: %w", err)
 		}
 	}
+	return addColumn(db, "session_messages", "model", "TEXT NOT NULL DEFAULT ''")
+}
+
+func addColumn(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return fmt.Errorf("sqlite: migrate: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("sqlite: migrate: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("sqlite: migrate: %w", err)
+	}
+	if _, err := db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition); err != nil {
+		return fmt.Errorf("sqlite: migrate: %w", err)
+	}
 	return nil
 }
 
 func (d *DB) AppendSessionMessage(ctx context.Context, sessionID, role, content string) (int64, error) {
+	return d.AppendSessionMessageModel(ctx, sessionID, role, content, "")
+}
+
+func (d *DB) AppendSessionMessageModel(ctx context.Context, sessionID, role, content, model string) (int64, error) {
 	if d == nil || d.db == nil {
 		return 0, errors.New("sqlite: db not initialized")
 	}
@@ -117,8 +200,8 @@ func (d *DB) AppendSessionMessage(ctx context.Context, sessionID, role, content
 	}
 	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
 	res, err := d.db.ExecContext(ctx,
-		"INSERT INTO session_messages (session_id, role, content, created_at) VALUES (?, ?, ?, ?)",
-		sessionID, strings.ToLower(role), content, timestamp,
+		"INSERT INTO session_messages (session_id, role, content, model, created_at) VALUES (?, ?, ?, ?, ?)",
+		sessionID, strings.ToLower(role), content, model, timestamp,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("sqlite: insert session message: %w", err)
@@ -135,7 +218,7 @@ func (d *DB) ListSessionMessages(ctx context.Context, sessionID string, limit in
 		limit = 100
 	}
 	rows, err := d.db.QueryContext(ctx,
-		"SELECT id, session_id, role, content, created_at FROM session_messages WHERE session_id = ? ORDER BY id DESC LIMIT ?",
+		"SELECT id, session_id, role, content, model, created_at FROM session_messages WHERE session_id = ? ORDER BY id DESC LIMIT ?",
 		sessionID, limit,
 	)
 	if err != nil {
@@ -146,7 +229,90 @@ func (d *DB) ListSessionMessages(ctx context.Context, sessionID string, limit in
 	var messages []SessionMessage
 	for rows.Next() {
 		var msg SessionMessage
-		if err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.CreatedAt); err != nil {
+		if err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.Model, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("sqlite: scan session message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: iterate session messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (d *DB) ListRecentSessionMessages(ctx context.Context, sessionID string, afterID int64, skipRole string, limit int) ([]SessionMessage, error) {
+	if d == nil || d.db == nil {
+		return nil, errors.New("sqlite: db not initialized")
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := d.db.QueryContext(ctx,
+		"SELECT id, session_id, role, content, model, created_at FROM session_messages WHERE session_id = ? AND id > ? AND role != ? ORDER BY id DESC LIMIT ?",
+		sessionID, afterID, skipRole, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: list recent session messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []SessionMessage
+	for rows.Next() {
+		var msg SessionMessage
+		if err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.Model, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("sqlite: scan session message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: iterate session messages: %w", err)
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+func (d *DB) ListSessionMessagesAfter(ctx context.Context, sessionID string, afterID int64) ([]SessionMessage, error) {
+	if d == nil || d.db == nil {
+		return nil, errors.New("sqlite: db not initialized")
+	}
+	return listSessionMessages(ctx, d.db, sessionID, afterID)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (d *DB) CountSessionMessagesThrough(ctx context.Context, sessionID string, throughID int64) (int, error) {
+	if d == nil || d.db == nil {
+		return 0, errors.New("sqlite: db not initialized")
+	}
+	var count int
+	row := d.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM session_messages WHERE session_id = ? AND id <= ?",
+		sessionID, throughID,
+	)
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("sqlite: count session messages: %w", err)
+	}
+	return count, nil
+}
+
+func listSessionMessages(ctx context.Context, q queryer, sessionID string, afterID int64) ([]SessionMessage, error) {
+	rows, err := q.QueryContext(ctx,
+		"SELECT id, session_id, role, content, model, created_at FROM session_messages WHERE session_id = ? AND id > ? ORDER BY id ASC",
+		sessionID, afterID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: list session messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []SessionMessage
+	for rows.Next() {
+		var msg SessionMessage
+		if err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.Model, &msg.CreatedAt); err != nil {
 			return nil, fmt.Errorf("sqlite: scan session message: %w", err)
 		}
 		messages = append(messages, msg)
@@ -157,6 +323,306 @@ func (d *DB) ListSessionMessages(ctx context.Context, sessionID string, limit in
 	return messages, nil
 }
 
+func listSessionSummaries(ctx context.Context, q queryer, sessionID string) ([]SessionSummary, error) {
+	rows, err := q.QueryContext(ctx,
+		"SELECT id, session_id, through_id, content, created_at FROM session_summaries WHERE session_id = ? ORDER BY id ASC",
+		sessionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: list session summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []SessionSummary
+	for rows.Next() {
+		var summary SessionSummary
+		if err := rows.Scan(&summary.ID, &summary.SessionID, &summary.ThroughID, &summary.Content, &summary.CreatedAt); err != nil {
+			return nil, fmt.Errorf("sqlite: scan session summary: %w", err)
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: iterate session summaries: %w", err)
+	}
+	return summaries, nil
+}
+
+func (d *DB) ListSessionIDs(ctx context.Context) ([]string, error) {
+	if d == nil || d.db == nil {
+		return nil, errors.New("sqlite: db not initialized")
+	}
+	rows, err := d.db.QueryContext(ctx, "SELECT DISTINCT session_id FROM session_messages ORDER BY session_id")
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: list session ids: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("sqlite: scan session id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: iterate session ids: %w", err)
+	}
+	return ids, nil
+}
+
+func (d *DB) ReplaceSessionMessages(ctx context.Context, sessionID string, messages []SessionMessage) error {
+	if d == nil || d.db == nil {
+		return errors.New("sqlite: db not initialized")
+	}
+	if strings.TrimSpace(sessionID) == "" {
+		return errors.New("sqlite: session id is required")
+	}
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlite: begin: %w", err)
+	}
+	defer tx.Rollback()
+	existing, err := listSessionMessages(ctx, tx, sessionID, 0)
+	if err != nil {
+		return err
+	}
+	keep := 0
+	for keep < len(existing) && keep < len(messages) && sameMessage(existing[keep], messages[keep]) {
+		keep++
+	}
+	var after int64
+	if keep > 0 {
+		after = existing[keep-1].ID
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM session_messages WHERE session_id = ? AND id > ?", sessionID, after); err != nil {
+		return fmt.Errorf("sqlite: clear session messages: %w", err)
+	}
+	rebuilt := append([]SessionMessage(nil), existing[:keep]...)
+	for _, msg := range messages[keep:] {
+		role := strings.ToLower(strings.TrimSpace(msg.Role))
+		if role == "" {
+			role = "user"
+		}
+		createdAt := msg.CreatedAt
+		if createdAt == "" {
+			createdAt = time.Now().UTC().Format(time.RFC3339Nano)
+		}
+		res, err := tx.ExecContext(ctx,
+			"INSERT INTO session_messages (session_id, role, content, model, created_at) VALUES (?, ?, ?, ?, ?)",
+			sessionID, role, msg.Content, msg.Model, createdAt,
+		)
+		if err != nil {
+			return fmt.Errorf("sqlite: insert session message: %w", err)
+		}
+		id, _ := res.LastInsertId()
+		rebuilt = append(rebuilt, SessionMessage{ID: id, SessionID: sessionID, Role: role, Content: msg.Content})
+	}
+	summaries, err := listSessionSummaries(ctx, tx, sessionID)
+	if err != nil {
+		return err
+	}
+	for _, summary := range summaries {
+		throughID, ok := remapMessageID(existing, summary.ThroughID, rebuilt)
+		if !ok {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM session_summaries WHERE id = ?", summary.ID); err != nil {
+				return fmt.Errorf("sqlite: clear session summary: %w", err)
+			}
+			continue
+		}
+		if throughID == summary.ThroughID {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE session_summaries SET through_id = ? WHERE id = ?", throughID, summary.ID); err != nil {
+			return fmt.Errorf("sqlite: remap session summary: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlite: commit: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) CopySessionSummaries(ctx context.Context, fromID, toID string) error {
+	if d == nil || d.db == nil {
+		return errors.New("sqlite: db not initialized")
+	}
+	if strings.TrimSpace(fromID) == "" || strings.TrimSpace(toID) == "" {
+		return errors.New("sqlite: session ids are required")
+	}
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlite: begin: %w", err)
+	}
+	defer tx.Rollback()
+	source, err := listSessionMessages(ctx, tx, fromID, 0)
+	if err != nil {
+		return err
+	}
+	target, err := listSessionMessages(ctx, tx, toID, 0)
+	if err != nil {
+		return err
+	}
+	summaries, err := listSessionSummaries(ctx, tx, fromID)
+	if err != nil {
+		return err
+	}
+	for _, summary := range summaries {
+		throughID, ok := remapMessageID(source, summary.ThroughID, target)
+		if !ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO session_summaries (session_id, through_id, content, created_at) VALUES (?, ?, ?, ?)",
+			toID, throughID, summary.Content, summary.CreatedAt,
+		); err != nil {
+			return fmt.Errorf("sqlite: copy session summary: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlite: commit: %w", err)
+	}
+	return nil
+}
+
+func sameMessage(row, msg SessionMessage) bool {
+	return strings.EqualFold(strings.TrimSpace(row.Role), strings.TrimSpace(msg.Role)) &&
+		strings.TrimSpace(row.Content) == strings.TrimSpace(msg.Content)
+}
+
+func remapMessageID(from []SessionMessage, id int64, to []SessionMessage) (int64, bool) {
+	index := -1
+	for i, row := range from {
+		if row.ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return 0, false
+	}
+	occurrence := 0
+	for _, row := range from[:index] {
+		if sameMessage(row, from[index]) {
+			occurrence++
+		}
+	}
+	for _, row := range to {
+		if !sameMessage(row, from[index]) {
+			continue
+		}
+		if occurrence == 0 {
+			return row.ID, true
+		}
+		occurrence--
+	}
+	return 0, false
+}
+
+func (d *DB) RenameSession(ctx context.Context, oldID, newID string) (int64, error) {
+	if d == nil || d.db == nil {
+		return 0, errors.New("sqlite: db not initialized")
+	}
+	if strings.TrimSpace(oldID) == "" || strings.TrimSpace(newID) == "" {
+		return 0, errors.New("sqlite: session ids are required")
+	}
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: begin: %w", err)
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, "UPDATE session_messages SET session_id = ? WHERE session_id = ?", newID, oldID)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: rename session messages: %w", err)
+	}
+	moved, _ := res.RowsAffected()
+	if _, err := tx.ExecContext(ctx, "UPDATE session_summaries SET session_id = ? WHERE session_id = ?", newID, oldID); err != nil {
+		return 0, fmt.Errorf("sqlite: rename session summaries: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("sqlite: commit: %w", err)
+	}
+	return moved, nil
+}
+
+func (d *DB) SessionModel(ctx context.Context, sessionID string) (string, error) {
+	if d == nil || d.db == nil {
+		return "", errors.New("sqlite: db not initialized")
+	}
+	row := d.db.QueryRowContext(ctx, "SELECT model FROM session_settings WHERE session_id = ?", sessionID)
+	var model string
+	if err := row.Scan(&model); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("sqlite: session model: %w", err)
+	}
+	return model, nil
+}
+
+func (d *DB) SetSessionModel(ctx context.Context, sessionID, model string) error {
+	if d == nil || d.db == nil {
+		return errors.New("sqlite: db not initialized")
+	}
+	if strings.TrimSpace(sessionID) == "" {
+		return errors.New("sqlite: session id is required")
+	}
+	if model == "" {
+		if _, err := d.db.ExecContext(ctx, "DELETE FROM session_settings WHERE session_id = ?", sessionID); err != nil {
+			return fmt.Errorf("sqlite: clear session model: %w", err)
+		}
+		return nil
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	_, err := d.db.ExecContext(ctx,
+		`INSERT INTO session_settings (session_id, model, updated_at)
+		 VALUES (?, ?, ?)
+		 ON CONFLICT(session_id) DO UPDATE SET model = excluded.model, updated_at = excluded.updated_at`,
+		sessionID, model, now,
+	)
+	if err != nil {
+		return fmt.Errorf("sqlite: set session model: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) InsertSessionSummary(ctx context.Context, sessionID string, throughID int64, content string) (int64, error) {
+	if d == nil || d.db == nil {
+		return 0, errors.New("sqlite: db not initialized")
+	}
+	if strings.TrimSpace(sessionID) == "" {
+		return 0, errors.New("sqlite: session id is required")
+	}
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+	res, err := d.db.ExecContext(ctx,
+		"INSERT INTO session_summaries (session_id, through_id, content, created_at) VALUES (?, ?, ?, ?)",
+		sessionID, throughID, content, timestamp,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: insert session summary: %w", err)
+	}
+	id, _ := res.LastInsertId()
+	return id, nil
+}
+
+func (d *DB) LatestSessionSummary(ctx context.Context, sessionID string) (*SessionSummary, error) {
+	if d == nil || d.db == nil {
+		return nil, errors.New("sqlite: db not initialized")
+	}
+	row := d.db.QueryRowContext(ctx,
+		"SELECT id, session_id, through_id, content, created_at FROM session_summaries WHERE session_id = ? ORDER BY id DESC LIMIT 1",
+		sessionID,
+	)
+	var summary SessionSummary
+	if err := row.Scan(&summary.ID, &summary.SessionID, &summary.ThroughID, &summary.Content, &summary.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("sqlite: latest session summary: %w", err)
+	}
+	return &summary, nil
+}
+
 func (d *DB) DeleteSession(ctx context.Context, sessionID string) error {
 	if d == nil || d.db == nil {
 		return errors.New("sqlite: db not initialized")
@@ -167,6 +633,99 @@ func (d *DB) DeleteSession(ctx context.Context, sessionID string) error {
 	if _, err := d.db.ExecContext(ctx, "DELETE FROM session_messages WHERE session_id = ?", sessionID); err != nil {
 		return fmt.Errorf("sqlite: delete session: %w", err)
 	}
+	if _, err := d.db.ExecContext(ctx, "DELETE FROM session_summaries WHERE session_id = ?", sessionID); err != nil {
+		return fmt.Errorf("sqlite: delete session summaries: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) MarkMemoryExtractPending(ctx context.Context, sessionID string, at time.Time) error {
+	if d == nil || d.db == nil {
+		return errors.New("sqlite: db not initialized")
+	}
+	if strings.TrimSpace(sessionID) == "" {
+		return errors.New("sqlite: session id is required")
+	}
+	_, err := d.db.ExecContext(ctx,
+		`INSERT INTO memory_extract_state (session_id, pending_since)
+		 VALUES (?, ?)
+		 ON CONFLICT(session_id) DO UPDATE SET pending_since = excluded.pending_since`,
+		sessionID, at.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return fmt.Errorf("sqlite: mark memory extract pending: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) ClearMemoryExtractPending(ctx context.Context, sessionID string) error {
+	if d == nil || d.db == nil {
+		return errors.New("sqlite: db not initialized")
+	}
+	if _, err := d.db.ExecContext(ctx, "UPDATE memory_extract_state SET pending_since = '' WHERE session_id = ?", sessionID); err != nil {
+		return fmt.Errorf("sqlite: clear memory extract pending: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) ListMemoryExtractPending(ctx context.Context) (map[string]time.Time, error) {
+	if d == nil || d.db == nil {
+		return nil, errors.New("sqlite: db not initialized")
+	}
+	rows, err := d.db.QueryContext(ctx, "SELECT session_id, pending_since FROM memory_extract_state WHERE pending_since != ''")
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: list memory extract pending: %w", err)
+	}
+	defer rows.Close()
+	pending := make(map[string]time.Time)
+	for rows.Next() {
+		var sessionID, since string
+		if err := rows.Scan(&sessionID, &since); err != nil {
+			return nil, fmt.Errorf("sqlite: scan memory extract pending: %w", err)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			continue
+		}
+		pending[sessionID] = ts
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: iterate memory extract pending: %w", err)
+	}
+	return pending, nil
+}
+
+func (d *DB) MemoryExtractWatermark(ctx context.Context, sessionID string) (int64, error) {
+	if d == nil || d.db == nil {
+		return 0, errors.New("sqlite: db not initialized")
+	}
+	row := d.db.QueryRowContext(ctx, "SELECT last_message_id FROM memory_extract_state WHERE session_id = ?", sessionID)
+	var id int64
+	if err := row.Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("sqlite: memory extract watermark: %w", err)
+	}
+	return id, nil
+}
+
+func (d *DB) SetMemoryExtractWatermark(ctx context.Context, sessionID string, messageID int64) error {
+	if d == nil || d.db == nil {
+		return errors.New("sqlite: db not initialized")
+	}
+	if strings.TrimSpace(sessionID) == "" {
+		return errors.New("sqlite: session id is required")
+	}
+	_, err := d.db.ExecContext(ctx,
+		`INSERT INTO memory_extract_state (session_id, last_message_id)
+		 VALUES (?, ?)
+		 ON CONFLICT(session_id) DO UPDATE SET last_message_id = MAX(last_message_id, excluded.last_message_id)`,
+		sessionID, messageID,
+	)
+	if err != nil {
+		return fmt.Errorf("sqlite: set memory extract watermark: %w", err)
+	}
 	return nil
 }
 
@@ -241,6 +800,32 @@ func (d *DB) ListMemory(ctx context.Context, limit int) ([]MemoryEntry, error) {
 	return entries, nil
 }
 
+func (d *DB) ListAllMemory(ctx context.Context) ([]MemoryEntry, error) {
+	if d == nil || d.db == nil {
+		return nil, errors.New("sqlite: db not initialized")
+	}
+	rows, err := d.db.QueryContext(ctx,
+		"SELECT key, content, created_at, updated_at FROM memory_entries ORDER BY key",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: list all memory: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []MemoryEntry
+	for rows.Next() {
+		var entry MemoryEntry
+		if err := rows.Scan(&entry.Key, &entry.Content, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("sqlite: scan memory: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: iterate memory: %w", err)
+	}
+	return entries, nil
+}
+
 func (d *DB) DeleteMemory(ctx context.Context, key string) error {
 	if d == nil || d.db == nil {
 		return errors.New("sqlite: db not initialized")
@@ -392,3 +977,266 @@ func (d *DB) UpsertCronJob(ctx context.Context, job config.CronJob, enabled bool
 	}
 	return nil
 }
+
+type LLMUsage struct {
+	SessionID           string
+	UserID              string
+	JobID               string
+	Model               string
+	InputTokens         int64
+	OutputTokens        int64
+	CacheCreationTokens int64
+	CacheReadTokens     int64
+	CostUSD             float64
+	CreatedAt           string
+}
+
+type LLMUsageSummary struct {
+	Key                 string  `json:"key"`
+	Calls               int64   `json:"calls"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	CostUSD             float64 `json:"cost_usd"`
+}
+
+const usageTimeLayout = "2006-01-02T15:04:05.000000Z"
+
+var usageGroups = map[string]string{
+	"model":   "model",
+	"session": "session_id",
+	"user":    "user_id",
+	"job":     "job_id",
+	"day":     "substr(created_at, 1, 10)",
+}
+
+func (d *DB) InsertLLMUsage(ctx context.Context, usage LLMUsage) error {
+	if d == nil || d.db == nil {
+		return errors.New("sqlite: db not initialized")
+	}
+	createdAt := usage.CreatedAt
+	if createdAt == "" {
+		createdAt = time.Now().UTC().Format(usageTimeLayout)
+	}
+	_, err := d.db.ExecContext(ctx,
+		`INSERT INTO llm_usage (session_id, user_id, job_id, model, input_tokens, output_tokens,
+		 cache_creation_tokens, cache_read_tokens, cost_usd, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		usage.SessionID, usage.UserID, usage.JobID, usage.Model, usage.InputTokens, usage.OutputTokens,
+		usage.CacheCreationTokens, usage.CacheReadTokens, usage.CostUSD, createdAt,
+	)
+	if err != nil {
+		return fmt.Errorf("sqlite: insert llm usage: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) SummarizeLLMUsage(ctx context.Context, since time.Time, groupBy, sessionID string) ([]LLMUsageSummary, error) {
+	if d == nil || d.db == nil {
+		return nil, errors.New("sqlite: db not initialized")
+	}
+	column, ok := usageGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("sqlite: unsupported usage grouping %q", groupBy)
+	}
+	where := "created_at >= ?"
+	args := []any{since.UTC().Format(usageTimeLayout)}
+	if sessionID != "" {
+		where += " AND session_id = ?"
+		args = append(args, sessionID)
+	}
+	rows, err := d.db.QueryContext(ctx,
+		`SELECT `+column+`, COUNT(*), SUM(input_tokens), SUM(output_tokens), SUM(cache_creation_tokens),
+		 SUM(cache_read_tokens), SUM(cost_usd)
+		 FROM llm_usage WHERE `+where+` GROUP BY 1 ORDER BY SUM(cost_usd) DESC, 1`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: summarize llm usage: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []LLMUsageSummary
+	for rows.Next() {
+		var summary LLMUsageSummary
+		if err := rows.Scan(&summary.Key, &summary.Calls, &summary.InputTokens, &summary.OutputTokens,
+			&summary.CacheCreationTokens, &summary.CacheReadTokens, &summary.CostUSD); err != nil {
+			return nil, fmt.Errorf("sqlite: scan llm usage: %w", err)
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: iterate llm usage: %w", err)
+	}
+	return summaries, nil
+}
+
+func (d *DB) SumLLMUsage(ctx context.Context, since time.Time, groupBy, key string) (int64, float64, error) {
+	if d == nil || d.db == nil {
+		return 0, 0, errors.New("sqlite: db not initialized")
+	}
+	query := `SELECT COALESCE(SUM(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens), 0),
+		 COALESCE(SUM(cost_usd), 0) FROM llm_usage WHERE created_at >= ?`
+	args := []any{since.UTC().Format(usageTimeLayout)}
+	if groupBy != "" {
+		column, ok := usageGroups[groupBy]
+		if !ok {
+			return 0, 0, fmt.Errorf("sqlite: unsupported usage grouping %q", groupBy)
+		}
+		query += " AND " + column + " = ?"
+		args = append(args, key)
+	}
+	var (
+		tokens int64
+		cost   float64
+	)
+	if err := d.db.QueryRowContext(ctx, query, args...).Scan(&tokens, &cost); err != nil {
+		return 0, 0, fmt.Errorf("sqlite: sum llm usage: %w", err)
+	}
+	return tokens, cost, nil
+}
+
+func (d *DB) SetBudgetOverride(ctx context.Context, scope, subject string, expiresAt time.Time, createdBy string) error {
+	if d == nil || d.db == nil {
+		return errors.New("sqlite: db not initialized")
+	}
+	if strings.TrimSpace(scope) == "" {
+		return errors.New("sqlite: budget scope is required")
+	}
+	_, err := d.db.ExecContext(ctx,
+		`INSERT INTO budget_overrides (scope, subject, expires_at, created_by)
+		 VALUES (?, ?, ?, ?)
+		 ON CONFLICT(scope, subject) DO UPDATE SET expires_at = excluded.expires_at, created_by = excluded.created_by`,
+		scope, subject, expiresAt.UTC().Format(usageTimeLayout), createdBy,
+	)
+	if err != nil {
+		return fmt.Errorf("sqlite: set budget override: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) DeleteBudgetOverride(ctx context.Context, scope, subject string) error {
+	if d == nil || d.db == nil {
+		return errors.New("sqlite: db not initialized")
+	}
+	if _, err := d.db.ExecContext(ctx, "DELETE FROM budget_overrides WHERE scope = ? AND subject = ?", scope, subject); err != nil {
+		return fmt.Errorf("sqlite: delete budget override: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) BudgetOverrideActive(ctx context.Context, scope, subject string, now time.Time) (bool, error) {
+	if d == nil || d.db == nil {
+		return false, errors.New("sqlite: db not initialized")
+	}
+	row := d.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM budget_overrides WHERE scope = ? AND subject = ? AND expires_at > ?",
+		scope, subject, now.UTC().Format(usageTimeLayout),
+	)
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, fmt.Errorf("sqlite: budget override: %w", err)
+	}
+	return count > 0, nil
+}
+
+type APIToken struct {
+	Name       string   `json:"name"`
+	Hash       string   `json:"-"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at"`
+}
+
+func (d *DB) CreateAPIToken(ctx context.Context, name, hash string, scopes []string) error {
+	if d == nil || d.db == nil {
+		return errors.New("sqlite: db not initialized")
+	}
+	if strings.TrimSpace(name) == "" || strings.TrimSpace(hash) == "" {
+		return errors.New("sqlite: token name and hash are required")
+	}
+	_, err := d.db.ExecContext(ctx,
+		"INSERT INTO api_tokens (name, hash, scopes, created_at) VALUES (?, ?, ?, ?)",
+		name, hash, strings.Join(scopes, ","), time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("sqlite: create api token: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) APITokenByHash(ctx context.Context, hash string) (APIToken, bool, error) {
+	if d == nil || d.db == nil {
+		return APIToken{}, false, errors.New("sqlite: db not initialized")
+	}
+	row := d.db.QueryRowContext(ctx, "SELECT name, hash, scopes, created_at, last_used_at FROM api_tokens WHERE hash = ?", hash)
+	token, err := scanAPIToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIToken{}, false, nil
+	}
+	if err != nil {
+		return APIToken{}, false, fmt.Errorf("sqlite: api token: %w", err)
+	}
+	return token, true, nil
+}
+
+func (d *DB) TouchAPIToken(ctx context.Context, name string, at time.Time) error {
+	if d == nil || d.db == nil {
+		return errors.New("sqlite: db not initialized")
+	}
+	if _, err := d.db.ExecContext(ctx, "UPDATE api_tokens SET last_used_at = ? WHERE name = ?", at.UTC().Format(time.RFC3339), name); err != nil {
+		return fmt.Errorf("sqlite: touch api token: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) ListAPITokens(ctx context.Context) ([]APIToken, error) {
+	if d == nil || d.db == nil {
+		return nil, errors.New("sqlite: db not initialized")
+	}
+	rows, err := d.db.QueryContext(ctx, "SELECT name, hash, scopes, created_at, last_used_at FROM api_tokens ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: list api tokens: %w", err)
+	}
+	defer rows.Close()
+	var tokens []APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite: scan api token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (d *DB) DeleteAPIToken(ctx context.Context, name string) (bool, error) {
+	if d == nil || d.db == nil {
+		return false, errors.New("sqlite: db not initialized")
+	}
+	res, err := d.db.ExecContext(ctx, "DELETE FROM api_tokens WHERE name = ?", name)
+	if err != nil {
+		return false, fmt.Errorf("sqlite: delete api token: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIToken(row rowScanner) (APIToken, error) {
+	var (
+		token  APIToken
+		scopes string
+	)
+	if err := row.Scan(&token.Name, &token.Hash, &scopes, &token.CreatedAt, &token.LastUsedAt); err != nil {
+		return APIToken{}, err
+	}
+	if scopes != "" {
+		token.Scopes = strings.Split(scopes, ",")
+	}
+	return token, nil
+}
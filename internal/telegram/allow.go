@@ -29,3 +29,7 @@ func isAllowedUpdate(allow []string, update Update) bool {
 	}
 	return isAllowedUser(allow, update.Message.From)
 }
+
+func IsAllowedUser(allow []string, user *User) bool {
+	return isAllowedUser(allow, user)
+}
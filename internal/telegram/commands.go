@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mouse/internal/logging"
+)
+
+type BotCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+func SetMyCommands(ctx context.Context, base, token string, commands []BotCommand, logger *logging.Logger) error {
+	if strings.TrimSpace(token) == "" {
+		return errors.New("telegram: bot token required")
+	}
+	if len(commands) == 0 {
+		return errors.New("telegram: at least one command required")
+	}
+	body, err := json.Marshal(map[string]any{"commands": commands})
+	if err != nil {
+		return fmt.Errorf("telegram: marshal commands: %w", err)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	endpoint := fmt.Sprintf("%s/bot%s/setMyCommands", APIBase(base), token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("telegram: request commands: %w", err)
+	}
+	req.Header.Set("content-type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram: commands call: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("telegram: commands response read: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("telegram: commands status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if logger != nil {
+		logger.Info("telegram commands registered", map[string]string{"count": strconv.Itoa(len(commands))})
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var ErrFileTooLarge = errors.New("telegram: file exceeds size limit")
+
+type File struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileSize     int64  `json:"file_size"`
+	FilePath     string `json:"file_path"`
+}
+
+type getFileResponse struct {
+	OK          bool   `json:"ok"`
+	Result      File   `json:"result"`
+	Description string `json:"description"`
+}
+
+func (s *Sender) DownloadFile(ctx context.Context, fileID string, maxBytes int64) ([]byte, File, error) {
+	if strings.TrimSpace(fileID) == "" {
+		return nil, File{}, errors.New("telegram: file id is required")
+	}
+	body, err := json.Marshal(map[string]string{"file_id": fileID})
+	if err != nil {
+		return nil, File{}, fmt.Errorf("telegram: marshal getFile: %w", err)
+	}
+	url := fmt.Sprintf("%s/bot%s/getFile", s.apiBase, s.botToken)
+	status, respBody, err := s.doRequest(ctx, url, body)
+	if err != nil {
+		return nil, File{}, err
+	}
+	var parsed getFileResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, File{}, fmt.Errorf("telegram: decode getFile: %w", err)
+	}
+	if status < 200 || status >= 300 || !parsed.OK {
+		return nil, File{}, fmt.Errorf("telegram: getFile http %d: %s", status, parsed.Description)
+	}
+	file := parsed.Result
+	if file.FilePath == "" {
+		return nil, file, errors.New("telegram: getFile returned no file path")
+	}
+	if maxBytes > 0 && file.FileSize > maxBytes {
+		return nil, file, ErrFileTooLarge
+	}
+
+	fileURL := fmt.Sprintf("%s/file/bot%s/%s", s.apiBase, s.botToken, file.FilePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, file, fmt.Errorf("telegram: build download: %w", err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, file, fmt.Errorf("telegram: download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, file, fmt.Errorf("telegram: download http %d", resp.StatusCode)
+	}
+	reader := io.Reader(resp.Body)
+	if maxBytes > 0 {
+		reader = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, file, fmt.Errorf("telegram: read download: %w", err)
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return nil, file, ErrFileTooLarge
+	}
+	return data, file, nil
+}
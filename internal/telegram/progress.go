@@ -0,0 +1,64 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const ActionTyping = "typing"
+
+type chatActionRequest struct {
+	ChatID int64  `json:"chat_id"`
+	Action string `json:"action"`
+}
+
+type editMessageRequest struct {
+	ChatID    int64  `json:"chat_id"`
+	MessageID int64  `json:"message_id"`
+	Text      string `json:"text"`
+}
+
+func (s *Sender) SendChatAction(ctx context.Context, chatID int64, action string) error {
+	if chatID == 0 {
+		return errors.New("telegram: chat id is required")
+	}
+	body, err := json.Marshal(chatActionRequest{ChatID: chatID, Action: action})
+	if err != nil {
+		return fmt.Errorf("telegram: marshal chat action: %w", err)
+	}
+	return s.call(ctx, "sendChatAction", body)
+}
+
+func (s *Sender) EditMessageText(ctx context.Context, chatID, messageID int64, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return errors.New("telegram: message text is empty")
+	}
+	if chatID == 0 || messageID == 0 {
+		return errors.New("telegram: chat id and message id are required")
+	}
+	body, err := json.Marshal(editMessageRequest{ChatID: chatID, MessageID: messageID, Text: text})
+	if err != nil {
+		return fmt.Errorf("telegram: marshal edit: %w", err)
+	}
+	err = s.call(ctx, "editMessageText", body)
+	if err != nil && strings.Contains(err.Error(), "message is not modified") {
+		return nil
+	}
+	return err
+}
+
+func (s *Sender) call(ctx context.Context, method string, body []byte) error {
+	url := fmt.Sprintf("%s/bot%s/%s", s.apiBase, s.botToken, method)
+	status, respBody, err := s.doRequest(ctx, url, body)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("telegram: %s http %d: %s", method, status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
@@ -11,20 +11,38 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"mouse/internal/logging"
 )
 
-const telegramAPIBase = "https://api.telegram.org"
+const (
+	DefaultAPIBase   = "https://api.telegram.org"
+	MaxMessageLength = 4096
+)
+
+func APIBase(base string) string {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base == "" {
+		return DefaultAPIBase
+	}
+	return base
+}
 
 type SenderConfig struct {
+	APIBase   string
 	BotToken  string
 	AllowFrom []string
+	Workspace string
 }
 
 type Sender struct {
+	apiBase    string
 	botToken   string
 	allowFrom  []string
+	workspace  string
 	httpClient *http.Client
 	logger     *logging.Logger
 }
@@ -35,8 +53,10 @@ func NewSender(cfg SenderConfig, logger *logging.Logger) (*Sender, error) {
 	}
 	client := &http.Client{Timeout: 15 * time.Second}
 	return &Sender{
+		apiBase:    APIBase(cfg.APIBase),
 		botToken:   cfg.BotToken,
 		allowFrom:  cfg.AllowFrom,
+		workspace:  cfg.Workspace,
 		httpClient: client,
 		logger:     logger,
 	}, nil
@@ -47,30 +67,92 @@ type sendMessageRequest struct {
 	Text   string `json:"text"`
 }
 
+type messageResponse struct {
+	OK     bool `json:"ok"`
+	Result struct {
+		MessageID int64 `json:"message_id"`
+	} `json:"result"`
+}
+
 func (s *Sender) SendMessage(ctx context.Context, chatID int64, user *User, text string) error {
+	parts := SplitMessage(text, MaxMessageLength)
+	if len(parts) == 0 {
+		_, err := s.PostMessage(ctx, chatID, user, text)
+		return err
+	}
+	for _, part := range parts {
+		if _, err := s.PostMessage(ctx, chatID, user, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func MessageLength(text string) int {
+	n := 0
+	for _, r := range text {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+func SplitMessage(text string, limit int) []string {
+	text = strings.TrimSpace(text)
+	var parts []string
+	for MessageLength(text) > limit {
+		cut, units := 0, 0
+		for i, r := range text {
+			if units+utf16.RuneLen(r) > limit {
+				cut = i
+				break
+			}
+			units += utf16.RuneLen(r)
+		}
+		if cut == 0 {
+			_, cut = utf8.DecodeRuneInString(text)
+		}
+		if i := strings.LastIndex(text[:cut], "\n"); i > cut/2 {
+			cut = i
+		} else if i := strings.LastIndexFunc(text[:cut], unicode.IsSpace); i > cut/2 {
+			cut = i
+		}
+		parts = append(parts, strings.TrimSpace(text[:cut]))
+		text = strings.TrimSpace(text[cut:])
+	}
+	if text != "" {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+func (s *Sender) PostMessage(ctx context.Context, chatID int64, user *User, text string) (int64, error) {
 	if !isAllowedUser(s.allowFrom, user) {
-		return errors.New("telegram: user not allowed")
+		return 0, errors.New("telegram: user not allowed")
 	}
 	text = strings.TrimSpace(text)
 	if text == "" {
-		return errors.New("telegram: message text is empty")
+		return 0, errors.New("telegram: message text is empty")
 	}
 	if chatID == 0 {
-		return errors.New("telegram: chat id is required")
+		return 0, errors.New("telegram: chat id is required")
 	}
 
 	payload := sendMessageRequest{ChatID: chatID, Text: text}
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("telegram: marshal request: %w", err)
+		return 0, fmt.Errorf("telegram: marshal request: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/bot%s/sendMessage", telegramAPIBase, s.botToken)
+	url := fmt.Sprintf("%s/bot%s/sendMessage", s.apiBase, s.botToken)
 	var lastErr error
 	for attempt := 1; attempt <= 2; attempt++ {
 		status, respBody, reqErr := s.doRequest(ctx, url, body)
 		if reqErr == nil && status >= 200 && status < 300 {
-			return nil
+			var parsed messageResponse
+			if err := json.Unmarshal(respBody, &parsed); err != nil {
+				return 0, fmt.Errorf("telegram: decode response: %w", err)
+			}
+			return parsed.Result.MessageID, nil
 		}
 		if reqErr != nil {
 			lastErr = reqErr
@@ -95,7 +177,7 @@ func (s *Sender) SendMessage(ctx context.Context, chatID int64, user *User, text
 			time.Sleep(200 * time.Millisecond)
 		}
 	}
-	return lastErr
+	return 0, lastErr
 }
 
 func (s *Sender) doRequest(ctx context.Context, url string, body []byte) (int, []byte, error) {
@@ -1,6 +1,11 @@
 package telegram
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestIsAllowedUser(t *testing.T) {
 	user := &User{ID: 42, Username: "tester"}
@@ -14,3 +19,54 @@ func TestIsAllowedUser(t *testing.T) {
 		t.Fatalf("expected denied")
 	}
 }
+
+func TestResolveWorkspacePath(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspace, "reports"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	report := filepath.Join(workspace, "reports", "q3.pdf")
+	if err := os.WriteFile(report, []byte("%PDF"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(workspace, "link.txt")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	resolved, err := ResolveWorkspacePath(workspace, "reports/q3.pdf")
+	if err != nil {
+		t.Fatalf("resolve relative: %v", err)
+	}
+	if want, _ := filepath.EvalSymlinks(report); resolved != want {
+		t.Fatalf("expected %s, got %s", want, resolved)
+	}
+	for _, path := range []string{"../secret.txt", outside, "link.txt", ""} {
+		if _, err := ResolveWorkspacePath(workspace, path); err == nil {
+			t.Fatalf("expected %q to be rejected", path)
+		}
+	}
+}
+
+func TestSplitMessageCountsUTF16Units(t *testing.T) {
+	if got := MessageLength("a😀é"); got != 4 {
+		t.Fatalf("expected 4 utf-16 units, got %d", got)
+	}
+	text := strings.Repeat("😀", 3000)
+	parts := SplitMessage(text, MaxMessageLength)
+	if len(parts) != 2 || strings.Join(parts, "") != text {
+		t.Fatalf("expected 2 lossless parts, got %d", len(parts))
+	}
+	for _, part := range parts {
+		if MessageLength(part) > MaxMessageLength {
+			t.Fatalf("part exceeds limit: %d units", MessageLength(part))
+		}
+	}
+	parts = SplitMessage("first line\nsecond line", 15)
+	if len(parts) != 2 || parts[0] != "first line" || parts[1] != "second line" {
+		t.Fatalf("expected split at newline, got %q", parts)
+	}
+}
@@ -0,0 +1,148 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxDocumentBytes = 50 << 20
+	maxPhotoBytes    = 10 << 20
+)
+
+func (s *Sender) SendDocument(ctx context.Context, chatID int64, user *User, path, caption string) error {
+	return s.sendFile(ctx, "sendDocument", "document", maxDocumentBytes, chatID, user, path, caption)
+}
+
+func (s *Sender) SendPhoto(ctx context.Context, chatID int64, user *User, path, caption string) error {
+	return s.sendFile(ctx, "sendPhoto", "photo", maxPhotoBytes, chatID, user, path, caption)
+}
+
+func (s *Sender) sendFile(ctx context.Context, method, field string, limit int64, chatID int64, user *User, path, caption string) error {
+	if !isAllowedUser(s.allowFrom, user) {
+		return errors.New("telegram: user not allowed")
+	}
+	if chatID == 0 {
+		return errors.New("telegram: chat id is required")
+	}
+	resolved, err := ResolveWorkspacePath(s.workspace, path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return fmt.Errorf("telegram: stat file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("telegram: %s is not a regular file", path)
+	}
+	if info.Size() > limit {
+		return fmt.Errorf("telegram: %s exceeds %d bytes", path, limit)
+	}
+	body, contentType, err := multipartBody(chatID, field, resolved, caption)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/bot%s/%s", s.apiBase, s.botToken, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("telegram: build request: %w", err)
+	}
+	req.Header.Set("content-type", contentType)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("telegram: read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		trimmed := strings.TrimSpace(string(respBody))
+		if s.logger != nil {
+			s.logger.Error("telegram file send failed", map[string]string{
+				"method": method,
+				"status": strconv.Itoa(resp.StatusCode),
+				"body":   trimmed,
+			})
+		}
+		return fmt.Errorf("telegram: http %d: %s", resp.StatusCode, trimmed)
+	}
+	if s.logger != nil {
+		s.logger.Info("telegram file sent", map[string]string{
+			"method": method,
+			"path":   resolved,
+			"bytes":  strconv.FormatInt(info.Size(), 10),
+		})
+	}
+	return nil
+}
+
+func multipartBody(chatID int64, field, path, caption string) ([]byte, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("telegram: open file: %w", err)
+	}
+	defer file.Close()
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.WriteField("chat_id", strconv.FormatInt(chatID, 10)); err != nil {
+		return nil, "", fmt.Errorf("telegram: write chat id: %w", err)
+	}
+	if caption = strings.TrimSpace(caption); caption != "" {
+		if err := writer.WriteField("caption", caption); err != nil {
+			return nil, "", fmt.Errorf("telegram: write caption: %w", err)
+		}
+	}
+	part, err := writer.CreateFormFile(field, filepath.Base(path))
+	if err != nil {
+		return nil, "", fmt.Errorf("telegram: create form file: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, "", fmt.Errorf("telegram: copy file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("telegram: close multipart: %w", err)
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+func ResolveWorkspacePath(workspace, path string) (string, error) {
+	if strings.TrimSpace(workspace) == "" {
+		return "", errors.New("telegram: workspace is not configured")
+	}
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", errors.New("telegram: file path is required")
+	}
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return "", fmt.Errorf("telegram: resolve workspace: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("telegram: resolve file: %w", err)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("telegram: %s is outside the workspace", path)
+	}
+	return resolved, nil
+}
@@ -14,7 +14,7 @@ import (
 	"mouse/internal/logging"
 )
 
-func SetWebhook(ctx context.Context, token, publicURL, path, secret string, logger *logging.Logger) error {
+func SetWebhook(ctx context.Context, base, token, publicURL, path, secret string, logger *logging.Logger) error {
 	if strings.TrimSpace(token) == "" {
 		return errors.New("telegram: bot token required")
 	}
@@ -36,7 +36,7 @@ func SetWebhook(ctx context.Context, token, publicURL, path, secret string, logg
 		return fmt.Errorf("telegram: marshal webhook: %w", err)
 	}
 	client := &http.Client{Timeout: 10 * time.Second}
-	endpoint := fmt.Sprintf("%s/bot%s/setWebhook", telegramAPIBase, token)
+	endpoint := fmt.Sprintf("%s/bot%s/setWebhook", APIBase(base), token)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("telegram: request webhook: %w", err)
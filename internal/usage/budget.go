@@ -0,0 +1,252 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"mouse/internal/config"
+	"mouse/internal/llm"
+)
+
+var budgetScopes = []string{"user", "chat", "job", "global"}
+
+type ExceededError struct {
+	Scope   string
+	Subject string
+	Period  string
+	Unit    string
+	Used    float64
+	Limit   float64
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("usage: %s %s budget exceeded for %s (%s of %s %s)", e.Period, e.Scope, e.subject(), formatAmount(e.Used, e.Unit), formatAmount(e.Limit, e.Unit), e.Unit)
+}
+
+func (e *ExceededError) Friendly() string {
+	reset := "tomorrow"
+	if e.Period == "monthly" {
+		reset = "next month"
+	}
+	return fmt.Sprintf("The %s %s budget for %s has been reached (%s of %s %s). Try again %s, or ask an admin for an override.",
+		e.Period, limitNoun(e.Unit), scopeLabel(e.Scope), formatAmount(e.Used, e.Unit), formatAmount(e.Limit, e.Unit), e.Unit, reset)
+}
+
+func (e *ExceededError) subject() string {
+	if e.Subject == "" {
+		return e.Scope
+	}
+	return e.Scope + " " + e.Subject
+}
+
+type BudgetStatus struct {
+	Scope      string
+	Subject    string
+	Period     string
+	Tokens     int64
+	CostUSD    float64
+	Limits     config.BudgetLimits
+	Overridden bool
+}
+
+func (t *Tracker) Wrap(client llm.Client) llm.Client {
+	if t == nil {
+		return client
+	}
+	return llm.WithGuard(llm.WithRecorder(client, t), t)
+}
+
+func (t *Tracker) BudgetsEnabled() bool {
+	return t != nil && t.budgets.Enabled
+}
+
+func (t *Tracker) Allow(ctx context.Context) error {
+	if !t.BudgetsEnabled() {
+		return nil
+	}
+	now := time.Now().UTC()
+	for _, scope := range budgetScopes {
+		subject, ok := scopeSubject(scope, llm.TagsFrom(ctx))
+		if !ok {
+			continue
+		}
+		limits := t.limits(scope)
+		if limits == (config.BudgetLimits{}) {
+			continue
+		}
+		overridden, err := t.db.BudgetOverrideActive(ctx, scope, subject, now)
+		if err != nil {
+			return err
+		}
+		if overridden {
+			continue
+		}
+		if err := t.checkPeriod(ctx, scope, subject, "daily", startOfDay(now), limits.DailyTokens, limits.DailyUSD); err != nil {
+			return err
+		}
+		if err := t.checkPeriod(ctx, scope, subject, "monthly", startOfMonth(now), limits.MonthlyTokens, limits.MonthlyUSD); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Tracker) checkPeriod(ctx context.Context, scope, subject, period string, since time.Time, tokenLimit int64, usdLimit float64) error {
+	if tokenLimit <= 0 && usdLimit <= 0 {
+		return nil
+	}
+	tokens, cost, err := t.db.SumLLMUsage(ctx, since, usageGroup(scope), subject)
+	if err != nil {
+		return err
+	}
+	if tokenLimit > 0 && tokens >= tokenLimit {
+		return &ExceededError{Scope: scope, Subject: subject, Period: period, Unit: "tokens", Used: float64(tokens), Limit: float64(tokenLimit)}
+	}
+	if usdLimit > 0 && cost >= usdLimit {
+		return &ExceededError{Scope: scope, Subject: subject, Period: period, Unit: "USD", Used: cost, Limit: usdLimit}
+	}
+	return nil
+}
+
+func (t *Tracker) Status(ctx context.Context, tags llm.Tags) ([]BudgetStatus, error) {
+	if !t.BudgetsEnabled() {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	var out []BudgetStatus
+	for _, scope := range budgetScopes {
+		subject, ok := scopeSubject(scope, tags)
+		if !ok {
+			continue
+		}
+		limits := t.limits(scope)
+		if limits == (config.BudgetLimits{}) {
+			continue
+		}
+		overridden, err := t.db.BudgetOverrideActive(ctx, scope, subject, now)
+		if err != nil {
+			return nil, err
+		}
+		for _, period := range []struct {
+			name  string
+			since time.Time
+			set   bool
+		}{
+			{"daily", startOfDay(now), limits.DailyTokens > 0 || limits.DailyUSD > 0},
+			{"monthly", startOfMonth(now), limits.MonthlyTokens > 0 || limits.MonthlyUSD > 0},
+		} {
+			if !period.set {
+				continue
+			}
+			tokens, cost, err := t.db.SumLLMUsage(ctx, period.since, usageGroup(scope), subject)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, BudgetStatus{Scope: scope, Subject: subject, Period: period.name, Tokens: tokens, CostUSD: cost, Limits: limits, Overridden: overridden})
+		}
+	}
+	return out, nil
+}
+
+func (t *Tracker) Override(ctx context.Context, scope, subject string, d time.Duration, by string) error {
+	if err := validScope(scope, subject); err != nil {
+		return err
+	}
+	return t.db.SetBudgetOverride(ctx, scope, subject, time.Now().Add(d), by)
+}
+
+func (t *Tracker) ClearOverride(ctx context.Context, scope, subject string) error {
+	if err := validScope(scope, subject); err != nil {
+		return err
+	}
+	return t.db.DeleteBudgetOverride(ctx, scope, subject)
+}
+
+func (t *Tracker) limits(scope string) config.BudgetLimits {
+	switch scope {
+	case "user":
+		return t.budgets.User
+	case "chat":
+		return t.budgets.Chat
+	case "job":
+		return t.budgets.Job
+	default:
+		return t.budgets.Global
+	}
+}
+
+func validScope(scope, subject string) error {
+	switch scope {
+	case "global":
+		if subject != "" {
+			return fmt.Errorf("usage: global budget takes no subject")
+		}
+		return nil
+	case "user", "chat", "job":
+		if strings.TrimSpace(subject) == "" {
+			return fmt.Errorf("usage: %s budget override needs an id", scope)
+		}
+		return nil
+	}
+	return fmt.Errorf("usage: unknown budget scope %q", scope)
+}
+
+func scopeSubject(scope string, tags llm.Tags) (string, bool) {
+	switch scope {
+	case "user":
+		return tags.User, tags.User != ""
+	case "chat":
+		return tags.Session, tags.Session != ""
+	case "job":
+		return tags.Job, tags.Job != ""
+	}
+	return "", true
+}
+
+func usageGroup(scope string) string {
+	switch scope {
+	case "chat":
+		return "session"
+	case "global":
+		return ""
+	}
+	return scope
+}
+
+func scopeLabel(scope string) string {
+	switch scope {
+	case "user":
+		return "your account"
+	case "chat":
+		return "this chat"
+	case "job":
+		return "this job"
+	}
+	return "the assistant"
+}
+
+func limitNoun(unit string) string {
+	if unit == "tokens" {
+		return "token"
+	}
+	return "spending"
+}
+
+func formatAmount(value float64, unit string) string {
+	if unit == "USD" {
+		return fmt.Sprintf("%.2f", value)
+	}
+	return fmt.Sprintf("%.0f", value)
+}
+
+func startOfDay(now time.Time) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(now time.Time) time.Time {
+	y, m, _ := now.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,63 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mouse/internal/config"
+	"mouse/internal/llm"
+	"mouse/internal/sqlite"
+)
+
+func TestBudgetBlocksAndOverride(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "mouse.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	tracker, err := NewTracker(nil, config.BudgetsConfig{
+		Enabled: true,
+		User:    config.BudgetLimits{DailyTokens: 2000},
+	}, db, nil)
+	if err != nil {
+		t.Fatalf("tracker: %v", err)
+	}
+	client := tracker.Wrap(fixedClient{})
+	alice := llm.WithTags(context.Background(), llm.Tags{Session: "chat-1", User: "1"})
+	bob := llm.WithTags(context.Background(), llm.Tags{Session: "chat-1", User: "2"})
+
+	if _, err := client.Complete(alice, "hi"); err != nil {
+		t.Fatalf("first call should pass: %v", err)
+	}
+	_, err = client.Complete(alice, "again")
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Scope != "user" || exceeded.Period != "daily" || exceeded.Unit != "tokens" {
+		t.Fatalf("expected user budget error, got %v", err)
+	}
+	if !strings.Contains(exceeded.Friendly(), "your account") {
+		t.Fatalf("unexpected friendly message %q", exceeded.Friendly())
+	}
+	if _, err := client.Complete(bob, "hi"); err != nil {
+		t.Fatalf("other user should not be limited: %v", err)
+	}
+
+	if err := tracker.Override(context.Background(), "user", "1", time.Hour, "admin"); err != nil {
+		t.Fatalf("override: %v", err)
+	}
+	if _, err := client.Complete(alice, "again"); err != nil {
+		t.Fatalf("override should allow call: %v", err)
+	}
+	if err := tracker.ClearOverride(context.Background(), "user", "1"); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if err := tracker.Allow(alice); err == nil {
+		t.Fatalf("expected budget enforced after clearing override")
+	}
+	if err := tracker.Override(context.Background(), "user", "", time.Hour, "admin"); err == nil {
+		t.Fatalf("expected error for user override without id")
+	}
+}
@@ -0,0 +1,64 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"mouse/internal/logging"
+)
+
+type Handler struct {
+	tracker *Tracker
+	logger  *logging.Logger
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewHandler(tracker *Tracker, logger *logging.Logger) *Handler {
+	return &Handler{tracker: tracker, logger: logger}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.tracker == nil {
+		writeError(w, http.StatusServiceUnavailable, "usage tracking not configured")
+		return
+	}
+	since, err := ParseSince(r.URL.Query().Get("since"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	report, err := h.tracker.Report(r.Context(), since, r.URL.Query().Get("by"))
+	if errors.Is(err, ErrInvalidGroup) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		if h.logger != nil {
+			h.logger.Error("usage report failed", map[string]string{
+				"error": err.Error(),
+			})
+		}
+		writeError(w, http.StatusInternalServerError, "usage report failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
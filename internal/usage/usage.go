@@ -0,0 +1,180 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"mouse/internal/config"
+	"mouse/internal/llm"
+	"mouse/internal/logging"
+	"mouse/internal/sqlite"
+)
+
+var ErrInvalidGroup = errors.New("usage: group by must be model, session, user, job or day")
+
+var groups = map[string]bool{"model": true, "session": true, "user": true, "job": true, "day": true}
+
+type Tracker struct {
+	db      *sqlite.DB
+	prices  map[string]config.ModelPrice
+	budgets config.BudgetsConfig
+	logger  *logging.Logger
+}
+
+type Report struct {
+	Since time.Time                `json:"since"`
+	By    string                   `json:"by"`
+	Rows  []sqlite.LLMUsageSummary `json:"rows"`
+	Total sqlite.LLMUsageSummary   `json:"total"`
+}
+
+func NewTracker(prices map[string]config.ModelPrice, budgets config.BudgetsConfig, db *sqlite.DB, logger *logging.Logger) (*Tracker, error) {
+	if db == nil {
+		return nil, errors.New("usage: db required")
+	}
+	return &Tracker{db: db, prices: prices, budgets: budgets, logger: logger}, nil
+}
+
+func (t *Tracker) RecordUsage(ctx context.Context, model string, u llm.Usage) {
+	if t == nil {
+		return
+	}
+	tags := llm.TagsFrom(ctx)
+	err := t.db.InsertLLMUsage(context.WithoutCancel(ctx), sqlite.LLMUsage{
+		SessionID:           tags.Session,
+		UserID:              tags.User,
+		JobID:               tags.Job,
+		Model:               model,
+		InputTokens:         int64(u.InputTokens),
+		OutputTokens:        int64(u.OutputTokens),
+		CacheCreationTokens: int64(u.CacheCreationTokens),
+		CacheReadTokens:     int64(u.CacheReadTokens),
+		CostUSD:             t.Cost(model, u),
+	})
+	if err != nil && t.logger != nil {
+		t.logger.Warn("llm usage record failed", map[string]string{
+			"model": model,
+			"error": err.Error(),
+		})
+	}
+}
+
+func (t *Tracker) Cost(model string, u llm.Usage) float64 {
+	price, ok := t.price(model)
+	if !ok {
+		return 0
+	}
+	cacheWrite := price.CacheWritePerMTok
+	if cacheWrite == 0 {
+		cacheWrite = price.InputPerMTok
+	}
+	cacheRead := price.CacheReadPerMTok
+	if cacheRead == 0 {
+		cacheRead = price.InputPerMTok
+	}
+	cost := float64(u.InputTokens)*price.InputPerMTok +
+		float64(u.OutputTokens)*price.OutputPerMTok +
+		float64(u.CacheCreationTokens)*cacheWrite +
+		float64(u.CacheReadTokens)*cacheRead
+	return cost / 1e6
+}
+
+func (t *Tracker) price(model string) (config.ModelPrice, bool) {
+	if price, ok := t.prices[model]; ok {
+		return price, true
+	}
+	var (
+		best  config.ModelPrice
+		match string
+	)
+	for name, price := range t.prices {
+		if strings.HasPrefix(model, name) && len(name) > len(match) {
+			best, match = price, name
+		}
+	}
+	return best, match != ""
+}
+
+func (t *Tracker) Report(ctx context.Context, since time.Time, by string) (Report, error) {
+	return t.SessionReport(ctx, since, by, "")
+}
+
+func (t *Tracker) SessionReport(ctx context.Context, since time.Time, by, sessionID string) (Report, error) {
+	if t == nil {
+		return Report{}, errors.New("usage: tracker not configured")
+	}
+	if by == "" {
+		by = "model"
+	}
+	if !groups[by] {
+		return Report{}, ErrInvalidGroup
+	}
+	rows, err := t.db.SummarizeLLMUsage(ctx, since, by, sessionID)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{Since: since, By: by, Rows: rows, Total: sqlite.LLMUsageSummary{Key: "total"}}
+	for _, row := range rows {
+		report.Total.Calls += row.Calls
+		report.Total.InputTokens += row.InputTokens
+		report.Total.OutputTokens += row.OutputTokens
+		report.Total.CacheCreationTokens += row.CacheCreationTokens
+		report.Total.CacheReadTokens += row.CacheReadTokens
+		report.Total.CostUSD += row.CostUSD
+	}
+	return report, nil
+}
+
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	switch value {
+	case "", "today":
+		return startOfDay(now.UTC()), nil
+	case "month":
+		return startOfMonth(now.UTC()), nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.Add(-time.Duration(n) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if at, err := time.Parse("2006-01-02", value); err == nil {
+		return at, nil
+	}
+	if at, err := time.Parse(time.RFC3339, strings.ToUpper(value)); err == nil {
+		return at, nil
+	}
+	return time.Time{}, fmt.Errorf("usage: invalid since %q", value)
+}
+
+func Format(report Report) string {
+	if len(report.Rows) == 0 {
+		return fmt.Sprintf("No LLM usage since %s.", report.Since.UTC().Format(time.RFC3339))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage since %s by %s:\n", report.Since.UTC().Format(time.RFC3339), report.By)
+	for _, row := range report.Rows {
+		b.WriteString(formatRow(row) + "\n")
+	}
+	b.WriteString(formatRow(report.Total))
+	return b.String()
+}
+
+func formatRow(row sqlite.LLMUsageSummary) string {
+	key := row.Key
+	if key == "" {
+		key = "(none)"
+	}
+	line := fmt.Sprintf("%s: %d calls, %d in / %d out tokens", key, row.Calls, row.InputTokens, row.OutputTokens)
+	if row.CacheCreationTokens > 0 || row.CacheReadTokens > 0 {
+		line += fmt.Sprintf(", cache %d write / %d read", row.CacheCreationTokens, row.CacheReadTokens)
+	}
+	return line + fmt.Sprintf(", $%.4f", row.CostUSD)
+}
@@ -0,0 +1,87 @@
+package usage
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mouse/internal/config"
+	"mouse/internal/llm"
+	"mouse/internal/sqlite"
+)
+
+type fixedClient struct{}
+
+func (fixedClient) Complete(ctx context.Context, prompt string) (string, error) {
+	return "ok", nil
+}
+
+func (fixedClient) Chat(ctx context.Context, req llm.Request) (llm.Response, error) {
+	return llm.Response{Text: "ok", Model: "claude-opus-4-5", Usage: llm.Usage{InputTokens: 1000, OutputTokens: 500, CacheReadTokens: 2000}}, nil
+}
+
+func TestTrackerRecordsTaggedUsage(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "mouse.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	tracker, err := NewTracker(map[string]config.ModelPrice{
+		"claude-opus-4": {InputPerMTok: 15, OutputPerMTok: 75, CacheReadPerMTok: 1.5},
+	}, config.BudgetsConfig{}, db, nil)
+	if err != nil {
+		t.Fatalf("tracker: %v", err)
+	}
+	client := llm.WithRecorder(fixedClient{}, tracker)
+	ctx := llm.WithTags(context.Background(), llm.Tags{Session: "chat-1", User: "42"})
+	if _, err := client.Complete(ctx, "hi"); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if _, err := llm.Stream(llm.WithTags(ctx, llm.Tags{Job: "daily"}), client, llm.Request{}, nil); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+
+	report, err := tracker.Report(context.Background(), time.Now().Add(-time.Hour), "job")
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if len(report.Rows) != 2 || report.Total.Calls != 2 || report.Total.CacheReadTokens != 4000 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	want := 2 * (1000*15 + 500*75 + 2000*1.5) / 1e6
+	if math.Abs(report.Total.CostUSD-want) > 1e-9 {
+		t.Fatalf("expected cost %f, got %f", want, report.Total.CostUSD)
+	}
+	byUser, err := tracker.Report(context.Background(), time.Now().Add(-time.Hour), "user")
+	if err != nil {
+		t.Fatalf("report by user: %v", err)
+	}
+	if len(byUser.Rows) != 1 || byUser.Rows[0].Key != "42" {
+		t.Fatalf("unexpected user report %+v", byUser.Rows)
+	}
+	if _, err := tracker.Report(context.Background(), time.Now(), "nope"); err != ErrInvalidGroup {
+		t.Fatalf("expected invalid group error, got %v", err)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 15, 10, 30, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"":           time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC),
+		"month":      time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		"7d":         now.Add(-7 * 24 * time.Hour),
+		"90m":        now.Add(-90 * time.Minute),
+		"2026-02-01": time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for input, want := range cases {
+		got, err := ParseSince(input, now)
+		if err != nil || !got.Equal(want) {
+			t.Fatalf("ParseSince(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseSince("yesterday-ish", now); err == nil {
+		t.Fatalf("expected error for invalid input")
+	}
+}